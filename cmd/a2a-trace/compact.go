@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// runCompact vacuums a trace database, printing its size on disk before and
+// after
+func runCompact(cfg *cli.Config) int {
+	// Opening a missing database would create an empty one
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		cli.PrintError("Failed to open database", err)
+		return 1
+	}
+	before := dbFileSize(cfg.DBPath)
+
+	dataStore, err := store.New(cfg.DBPath)
+	if err != nil {
+		cli.PrintError("Failed to open database", err)
+		return 1
+	}
+	defer dataStore.Close()
+
+	if err := dataStore.Compact(); err != nil {
+		cli.PrintError("Failed to compact database", err)
+		return 1
+	}
+
+	after := dbFileSize(cfg.DBPath)
+	cli.PrintSuccess(fmt.Sprintf("Compacted %s: %s -> %s", cfg.DBPath, formatBytes(before), formatBytes(after)))
+	return 0
+}
+
+// dbFileSize returns the size on disk of a database with its WAL file
+func dbFileSize(path string) int64 {
+	var size int64
+	for _, file := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// formatBytes prints a size in the largest whole unit, e.g. "12.5 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
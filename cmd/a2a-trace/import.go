@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// runImport loads a binary trace export into a trace database
+func runImport(cfg *cli.Config) int {
+	data, err := os.ReadFile(cfg.ImportPath)
+	if err != nil {
+		cli.PrintError("Failed to read trace", err)
+		return 1
+	}
+
+	dataStore, err := store.New(cfg.DBPath)
+	if err != nil {
+		cli.PrintError("Failed to open database", err)
+		return 1
+	}
+	defer dataStore.Close()
+
+	trace, err := dataStore.ImportTraceBinary(data)
+	if err != nil {
+		cli.PrintError("Failed to import trace", err)
+		return 1
+	}
+
+	cli.PrintSuccess(fmt.Sprintf("Imported trace %s into %s", trace.ID, cfg.DBPath))
+	return 0
+}
@@ -1,14 +1,12 @@
 package main
 
 import (
-	"embed"
-	"encoding/json"
 	"fmt"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
@@ -21,19 +19,42 @@ import (
 	"github.com/harry-kp/a2a-trace/internal/websocket"
 )
 
-//go:embed ui/out/*
-var uiFS embed.FS
-
 func main() {
 	// Parse CLI arguments
 	cfg, err := cli.ParseArgs()
 	if err != nil {
 		os.Exit(1)
 	}
+	if cfg == nil {
+		// Help or version was printed
+		os.Exit(0)
+	}
+
+	switch cfg.Subcommand {
+	case "mock":
+		os.Exit(runMock(cfg))
+	case "replay":
+		os.Exit(runReplay(cfg))
+	case "compact":
+		os.Exit(runCompact(cfg))
+	case "import":
+		os.Exit(runImport(cfg))
+	}
 
 	// Print banner
 	cli.PrintBanner(cfg)
 
+	// Name a database for --save
+	if cfg.Save {
+		path, err := newDBPath(cfg.DataDir, time.Now())
+		if err != nil {
+			cli.PrintError("Failed to create data directory", err)
+			os.Exit(1)
+		}
+		cfg.DBPath = path
+		cli.PrintInfo(fmt.Sprintf("Saving trace to %s", path))
+	}
+
 	// Initialize store
 	dataStore, err := store.New(cfg.DBPath)
 	if err != nil {
@@ -42,15 +63,131 @@ func main() {
 	}
 	defer dataStore.Close()
 
-	// Create trace session
-	trace, err := dataStore.CreateTrace(fmt.Sprintf("%v", cfg.Command))
+	// A trace still running was left by a session that crashed
+	if _, err := dataStore.MarkInterrupted(); err != nil {
+		cli.PrintError("Failed to initialize database", err)
+		os.Exit(1)
+	}
+
+	// Create trace session, or pick up one a crash interrupted
+	var trace *store.Trace
+	if cfg.Resume != "" {
+		trace, err = dataStore.ResumeTrace(cfg.Resume)
+		if err != nil {
+			cli.PrintError("Failed to resume trace", err)
+			os.Exit(1)
+		}
+		cli.PrintInfo(fmt.Sprintf("Resuming trace %s", trace.ID))
+	} else {
+		traceCommand := fmt.Sprintf("%v", cfg.Command)
+		if len(cfg.Execs) > 0 {
+			traceCommand = fmt.Sprintf("%v", cfg.Commands())
+		}
+		trace, err = dataStore.CreateTrace(traceCommand)
+		if err != nil {
+			cli.PrintError("Failed to create trace", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load custom insight rules
+	var rules []*analyzer.Rule
+	if cfg.RulesPath != "" {
+		rules, err = analyzer.LoadRules(cfg.RulesPath)
+		if err != nil {
+			cli.PrintError("Failed to load rules", err)
+			os.Exit(1)
+		}
+	}
+
+	var methodLabels map[string]string
+	if cfg.LabelsPath != "" {
+		methodLabels, err = proxy.LoadMethodLabels(cfg.LabelsPath)
+		if err != nil {
+			cli.PrintError("Failed to load method labels", err)
+			os.Exit(1)
+		}
+	}
+
+	var assertions []*analyzer.Assertion
+	if cfg.AssertPath != "" {
+		assertions, err = analyzer.LoadAssertions(cfg.AssertPath)
+		if err != nil {
+			cli.PrintError("Failed to load assertions", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load upstream TLS settings
+	tlsConfig, err := proxy.LoadTLSConfig(proxy.TLSOptions{
+		CACert:     cfg.CACert,
+		ClientCert: cfg.ClientCert,
+		ClientKey:  cfg.ClientKey,
+		Insecure:   cfg.Insecure,
+	})
 	if err != nil {
-		cli.PrintError("Failed to create trace", err)
+		cli.PrintError("Failed to load TLS settings", err)
 		os.Exit(1)
 	}
+	if cfg.Insecure {
+		cli.PrintWarning("Upstream TLS certificates are not verified (--insecure)")
+	}
+
+	// Parse fault injection rules
+	var faults []*proxy.FaultRule
+	for _, spec := range cfg.FaultRules {
+		fault, err := proxy.ParseFaultRule(spec)
+		if err != nil {
+			cli.PrintError("Invalid --fault-inject", err)
+			os.Exit(1)
+		}
+		faults = append(faults, fault)
+	}
+	if len(faults) > 0 {
+		cli.PrintWarning(fmt.Sprintf("Injecting faults into matching requests (%d rules)", len(faults)))
+	}
+
+	var upstreamAuth []*proxy.UpstreamAuth
+	for _, spec := range cfg.UpstreamAuth {
+		auth, err := proxy.ParseUpstreamAuth(spec)
+		if err != nil {
+			cli.PrintError("Invalid --upstream-auth", err)
+			os.Exit(1)
+		}
+		upstreamAuth = append(upstreamAuth, auth)
+	}
+
+	// Open the JSONL archive
+	var sinks []store.MessageSink
+	var jsonl *store.JSONLWriter
+	if cfg.JSONLPath != "" {
+		jsonl, err = store.NewJSONLWriter(cfg.JSONLPath, cfg.JSONLMaxSize*1024*1024)
+		if err != nil {
+			cli.PrintError("Failed to open JSONL file", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, jsonl)
+	}
+
+	for host, name := range cfg.AgentAliases {
+		if err := dataStore.SetAgentAlias(host, name); err != nil {
+			cli.PrintError("Failed to set agent alias", err)
+			os.Exit(1)
+		}
+	}
+
+	var blobs *store.BlobStore
+	if cfg.BlobDir != "" {
+		blobs, err = store.NewBlobStore(cfg.BlobDir)
+		if err != nil {
+			cli.PrintError("Failed to open blob directory", err)
+			os.Exit(1)
+		}
+	}
 
 	// Initialize WebSocket hub
 	wsHub := websocket.NewHub()
+	wsHub.SetBatchInterval(time.Duration(cfg.WSBatchMs) * time.Millisecond)
 	go wsHub.Run()
 
 	// Initialize analyzer
@@ -58,6 +195,9 @@ func main() {
 		Store:         dataStore,
 		TraceID:       trace.ID,
 		SlowThreshold: time.Second,
+		SLO:           cfg.SLO,
+		Rules:         rules,
+		Sinks:         sinks,
 		OnInsight: func(insight *store.Insight) {
 			wsHub.BroadcastInsight(insight)
 			if cfg.Verbose {
@@ -65,39 +205,71 @@ func main() {
 			}
 		},
 	})
+	go analyzer.Run()
 
 	// Set up UI handler
 	var uiHandler http.Handler
 	if !cfg.NoUI {
-		uiContent, err := fs.Sub(uiFS, "ui/out")
-		if err != nil {
-			// UI not embedded, serve placeholder
-			uiHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Content-Type", "text/html")
-				w.Write([]byte(placeholderHTML))
-			})
-		} else {
-			uiHandler = http.FileServer(http.FS(uiContent))
-		}
+		uiHandler = newUIHandler()
 	}
 
 	// Initialize proxy with all handlers
 	proxyServer := proxy.New(proxy.Config{
-		Port:            cfg.Port,
-		Store:           dataStore,
-		TraceID:         trace.ID,
-		WSHandler:       wsHub.HandleWebSocket,
-		UIHandler:       uiHandler,
-		SummaryProvider: analyzer,
+		Port:              cfg.Port,
+		ExtraPorts:        cfg.ExtraPorts,
+		Store:             dataStore,
+		TraceID:           trace.ID,
+		WSHandler:         wsHub.HandleWebSocket,
+		StreamHandler:     wsHub.HandleEventStream,
+		UIHandler:         uiHandler,
+		SummaryProvider:   analyzer,
+		HealthProvider:    analyzer,
+		Clients:           wsHub,
+		MaxConcurrency:    cfg.MaxConcurrency,
+		RejectOverLimit:   cfg.RejectOverLimit,
+		OnThrottle:        analyzer.RecordThrottle,
+		UnixSocket:        cfg.UnixSocket,
+		Transparent:       cfg.Transparent,
+		WebhookPort:       cfg.WebhookPort,
+		TLSConfig:         tlsConfig,
+		CorrelationHeader: cfg.CorrelationHeader,
+		CaptureMethods:    cfg.CaptureMethods,
+		NoFollowRedirects: cfg.NoFollowRedirects,
+		AutoDiscover:      cfg.AutoDiscover,
+		Faults:            faults,
+		UpstreamAuth:      upstreamAuth,
+		MethodLabels:      methodLabels,
+		Debug:             cfg.Debug,
+		ReadOnly:          cfg.ReadOnly,
+		Blobs:             blobs,
+		BlobThreshold:     cfg.BlobThreshold * 1024,
+		MaxResponseSize:   cfg.MaxResponseSize * 1024 * 1024,
+		Sinks:             sinks,
 		OnMessage: func(msg *store.Message) {
 			wsHub.BroadcastMessage(msg)
 			analyzer.AnalyzeMessage(msg)
 			if cfg.Verbose {
-				log.Printf("[%s] %s %s (%dms)", msg.Direction, msg.Method, msg.URL, msg.DurationMs)
+				log.Print(cli.FormatMessageLine(msg))
+			}
+		},
+		OnTrace: func(trace *store.Trace) {
+			analyzer.SetTrace(trace.ID)
+			wsHub.BroadcastTraceStatus(trace)
+			cli.PrintInfo(fmt.Sprintf("Started trace %s (%s)", trace.ID, trace.Command))
+		},
+		OnAnnotation: wsHub.BroadcastAnnotation,
+		OnInsightAck: wsHub.BroadcastInsightAck,
+		OnPause: func(paused bool) {
+			wsHub.BroadcastRecording(paused)
+			if paused {
+				cli.PrintInfo("Recording paused; traffic is still forwarded")
+			} else {
+				cli.PrintInfo("Recording resumed")
 			}
 		},
 		OnAgent: func(agent *store.Agent) {
 			wsHub.BroadcastAgent(agent)
+			analyzer.AnalyzeAgent(agent)
 			if cfg.Verbose {
 				log.Printf("Discovered agent: %s (%s)", agent.Name, agent.URL)
 			}
@@ -107,53 +279,9 @@ func main() {
 	// Separate UI server (only used when UI port differs from proxy port)
 	var uiServer *http.Server
 	if cfg.UIPort != cfg.Port && !cfg.NoUI {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/ws", wsHub.HandleWebSocket)
-		mux.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			messages, _ := dataStore.GetMessages(trace.ID)
-			writeJSON(w, messages)
-		})
-		mux.HandleFunc("/api/agents", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			agents, _ := dataStore.GetAgents()
-			writeJSON(w, agents)
-		})
-		mux.HandleFunc("/api/trace", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			t, _ := dataStore.GetTrace(trace.ID)
-			writeJSON(w, t)
-		})
-		mux.HandleFunc("/api/insights", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			insights, _ := dataStore.GetInsights(trace.ID)
-			writeJSON(w, insights)
-		})
-		mux.HandleFunc("/api/summary", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			summary := analyzer.GetSummary()
-			writeJSON(w, summary)
-		})
-		mux.HandleFunc("/api/export", func(w http.ResponseWriter, r *http.Request) {
-			setCORS(w)
-			data, _ := dataStore.ExportTrace(trace.ID)
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%s.json", trace.ID))
-			w.Write(data)
-		})
-		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
-		if uiHandler != nil {
-			mux.Handle("/ui/", http.StripPrefix("/ui/", uiHandler))
-			mux.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
-				http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
-			})
-		}
 		uiServer = &http.Server{
 			Addr:    fmt.Sprintf(":%d", cfg.UIPort),
-			Handler: mux,
+			Handler: proxyServer.LocalHandler(),
 		}
 	}
 
@@ -182,26 +310,70 @@ func main() {
 	// Give servers time to start
 	time.Sleep(100 * time.Millisecond)
 
-	// Initialize process manager
-	procMgr, err := process.New(process.Config{
-		Command:   cfg.Command,
-		ProxyPort: cfg.Port,
-		OutputHandler: func(line string, isStderr bool) {
-			// Output is already printed by the process manager
-		},
-	})
+	if cfg.Open && !cfg.NoUI {
+		cli.OpenBrowser(fmt.Sprintf("http://127.0.0.1:%d/ui", cfg.UIPort))
+	}
+
+	// Open the child output log
+	var childLog *os.File
+	if cfg.ChildLog != "" {
+		if dir := filepath.Dir(cfg.ChildLog); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				cli.PrintError("Failed to create child log directory", err)
+				os.Exit(1)
+			}
+		}
+		childLog, err = os.Create(cfg.ChildLog)
+		if err != nil {
+			cli.PrintError("Failed to open child log", err)
+			os.Exit(1)
+		}
+	}
+
+	// Initialize process supervisor
+	commands := cfg.Commands()
+	procCfgs := make([]process.Config, 0, len(commands))
+	for i, command := range commands {
+		procCfg := process.Config{
+			Command:   command,
+			ProxyPort: cfg.Port,
+			OutputHandler: func(line string, isStderr bool) {
+				// Output is already printed by the process manager
+			},
+			Quiet:    cfg.Quiet,
+			NoInject: cfg.NoInject,
+		}
+		if childLog != nil {
+			procCfg.Log = childLog
+		}
+		// Tag traffic by process only when several share the session
+		if len(commands) > 1 {
+			procCfg.Name = fmt.Sprintf("%s-%d", filepath.Base(command[0]), i+1)
+		}
+		procCfgs = append(procCfgs, procCfg)
+	}
+
+	supervisor, err := process.NewSupervisor(procCfgs)
 	if err != nil {
 		cli.PrintError("Failed to create process manager", err)
 		os.Exit(1)
 	}
 
-	// Start the user's command
-	if err := procMgr.Start(); err != nil {
+	// Start the user's commands
+	if err := supervisor.Start(); err != nil {
 		cli.PrintError("Failed to start command", err)
 		os.Exit(1)
 	}
+	proxyServer.SetProcess(supervisor)
 
-	fmt.Printf("📍 Process started (PID: %d)\n\n", procMgr.PID())
+	for _, m := range supervisor.Managers() {
+		if m.Name() != "" {
+			fmt.Printf("📍 Process %s started (PID: %d)\n", m.Name(), m.PID())
+		} else {
+			fmt.Printf("📍 Process started (PID: %d)\n", m.PID())
+		}
+	}
+	fmt.Println()
 
 	// Set up signal handling
 	sigChan := make(chan os.Signal, 1)
@@ -212,7 +384,7 @@ func main() {
 	done := make(chan struct{})
 
 	go func() {
-		code, err := procMgr.Wait()
+		code, err := supervisor.Wait()
 		if err != nil {
 			cli.PrintError("Process error", err)
 			exitCode = 1
@@ -227,15 +399,31 @@ func main() {
 		// Process exited naturally
 	case sig := <-sigChan:
 		fmt.Printf("\n📍 Received %v, shutting down...\n", sig)
-		_ = procMgr.Stop()
+		_ = supervisor.Stop()
 		<-done
 	}
 
-	// Update trace status
-	_ = dataStore.UpdateTraceStatus(trace.ID, "completed")
+	// Stop watching for hung requests
+	analyzer.Stop()
 
-	// Print summary
+	// Update trace status; traces may have been started via the API since
+	traceID := proxyServer.TraceID()
+	_ = dataStore.UpdateTraceStatus(traceID, "completed")
+
+	// Keep the final numbers with the trace
 	summary := analyzer.GetSummary()
+	if err := dataStore.SaveTraceSummary(traceID, summary); err != nil {
+		cli.PrintError("Failed to save summary", err)
+	}
+
+	// Tell connected UIs the session ended, then close their connections
+	if completed, err := dataStore.GetTrace(traceID); err == nil && completed != nil {
+		wsHub.Shutdown(completed, summary)
+	} else {
+		wsHub.Shutdown(trace, summary)
+	}
+
+	// Print summary
 	fmt.Println()
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("  A2A Trace Summary")
@@ -244,111 +432,63 @@ func main() {
 	fmt.Printf("  Insights:    %v\n", summary["total_insights"])
 	fmt.Printf("  Errors:      %v\n", summary["error_count"])
 	fmt.Printf("  Avg Latency: %vms\n", summary["avg_duration_ms"])
+	fmt.Printf("  Agents:      %v (%v skills)\n", summary["unique_agents"], summary["total_skills"])
+	if breaches, ok := summary["slo_breaches"].(map[string]int); ok && len(breaches) > 0 {
+		compliance := summary["slo_compliance"].(map[string]float64)
+		fmt.Printf("  SLO (%v):\n", cfg.SLO)
+		for _, agent := range sortedKeys(breaches) {
+			fmt.Printf("    %s: %d breaches, %.1f%% within\n", agent, breaches[agent], compliance[agent])
+		}
+	}
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 
+	// Gate on assertions; a command that already failed keeps its exit code
+	if len(assertions) > 0 {
+		results, passed := checkAssertions(assertions, dataStore, traceID)
+		summary["assertions"] = results
+		if !passed && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+
+	if cfg.SummaryOut != "" {
+		if err := writeSummary(cfg.SummaryOut, dataStore, traceID, summary, exitCode); err != nil {
+			cli.PrintError("Failed to write summary", err)
+		} else {
+			cli.PrintInfo(fmt.Sprintf("Summary written to %s", cfg.SummaryOut))
+		}
+	}
+
 	// Stop servers
 	_ = proxyServer.Stop()
-	if cfg.UIPort != cfg.Port {
+	if uiServer != nil {
 		_ = uiServer.Close()
 	}
 
-	os.Exit(exitCode)
-}
+	if childLog != nil {
+		childLog.Close()
+	}
+	if jsonl != nil {
+		jsonl.Close()
+	}
 
-func setCORS(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	os.Exit(exitCode)
 }
 
-func writeJSON(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	jsonData, _ := json.Marshal(data)
-	w.Write(jsonData)
+// newDBPath returns a path in dir for a new database named after the time,
+// e.g. a2a-trace-20240101-120000.db, creating dir if needed. A number is
+// added if a database of that name already exists.
+func newDBPath(dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := "a2a-trace-" + now.Format("20060102-150405")
+	path := filepath.Join(dir, base+".db")
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.db", base, n))
+	}
 }
-
-const placeholderHTML = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>A2A Trace</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
-            background: linear-gradient(135deg, #1a1a2e 0%, #16213e 50%, #0f3460 100%);
-            color: #e4e4e7;
-            min-height: 100vh;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-        }
-        .container {
-            text-align: center;
-            padding: 2rem;
-        }
-        h1 {
-            font-size: 3rem;
-            margin-bottom: 1rem;
-            background: linear-gradient(90deg, #3b82f6, #8b5cf6);
-            -webkit-background-clip: text;
-            -webkit-text-fill-color: transparent;
-        }
-        p {
-            color: #a1a1aa;
-            margin-bottom: 2rem;
-            font-size: 1.125rem;
-        }
-        .status {
-            display: inline-flex;
-            align-items: center;
-            gap: 0.5rem;
-            padding: 0.75rem 1.5rem;
-            background: rgba(34, 197, 94, 0.1);
-            border: 1px solid rgba(34, 197, 94, 0.3);
-            border-radius: 9999px;
-            color: #22c55e;
-        }
-        .dot {
-            width: 8px;
-            height: 8px;
-            background: #22c55e;
-            border-radius: 50%;
-            animation: pulse 2s infinite;
-        }
-        @keyframes pulse {
-            0%, 100% { opacity: 1; }
-            50% { opacity: 0.5; }
-        }
-        .info {
-            margin-top: 2rem;
-            padding: 1rem;
-            background: rgba(255,255,255,0.05);
-            border-radius: 0.5rem;
-            font-family: monospace;
-            font-size: 0.875rem;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>🔍 A2A Trace</h1>
-        <p>Visual debugger for multi-agent systems</p>
-        <div class="status">
-            <span class="dot"></span>
-            Tracing Active
-        </div>
-        <div class="info">
-            <p>UI coming soon! For now, use the API:</p>
-            <p>GET /api/messages - List all messages</p>
-            <p>GET /api/agents - List discovered agents</p>
-            <p>GET /api/insights - List insights</p>
-            <p>GET /api/export - Export trace as JSON</p>
-            <p>WS /ws - WebSocket for real-time updates</p>
-        </div>
-    </div>
-</body>
-</html>`
-
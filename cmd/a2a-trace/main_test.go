@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestNewDBPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "traces", "saved")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	path, err := newDBPath(dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "a2a-trace-20240101-120000.db"); path != want {
+		t.Errorf("got %s, want %s", path, want)
+	}
+	dataStore, err := store.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataStore.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database not created: %v", err)
+	}
+
+	// A second session in the same second gets its own file
+	path, err = newDBPath(dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^a2a-trace-\d{8}-\d{6}-2\.db$`).MatchString(filepath.Base(path)) {
+		t.Errorf("second database named %s, want a2a-trace-20240101-120000-2.db", filepath.Base(path))
+	}
+}
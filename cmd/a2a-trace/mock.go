@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/proxy"
+)
+
+// runMock serves recorded responses from an exported trace until interrupted
+func runMock(cfg *cli.Config) int {
+	data, err := os.ReadFile(cfg.MockTracePath)
+	if err != nil {
+		cli.PrintError("Failed to read trace", err)
+		return 1
+	}
+
+	mock, err := proxy.NewMockServer(data, cfg.Port)
+	if err != nil {
+		cli.PrintError("Failed to load trace", err)
+		return 1
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- mock.Start()
+	}()
+
+	cli.PrintInfo(fmt.Sprintf("Replaying %s on http://127.0.0.1:%d", cfg.MockTracePath, cfg.Port))
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errChan:
+		if err != nil && err != http.ErrServerClosed {
+			cli.PrintError("Mock server error", err)
+			return 1
+		}
+	case sig := <-sigChan:
+		fmt.Printf("\n📍 Received %v, shutting down...\n", sig)
+		_ = mock.Stop()
+	}
+
+	return 0
+}
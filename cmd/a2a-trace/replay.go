@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/proxy"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// runReplay resends a recorded request, printing each response and its
+// differences from the recorded one. It fails if any send does.
+func runReplay(cfg *cli.Config) int {
+	// Opening a missing database would create an empty one
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		cli.PrintError("Failed to open database", err)
+		return 1
+	}
+	dataStore, err := store.New(cfg.DBPath)
+	if err != nil {
+		cli.PrintError("Failed to open database", err)
+		return 1
+	}
+	defer dataStore.Close()
+
+	msg, err := dataStore.GetMessage(cfg.ReplayMessageID)
+	if err != nil {
+		cli.PrintError("Failed to load message", err)
+		return 1
+	}
+	if msg == nil {
+		cli.PrintError("Failed to load message", fmt.Errorf("no message %s in %s", cfg.ReplayMessageID, cfg.DBPath))
+		return 1
+	}
+
+	original, err := dataStore.GetResponse(msg.ID)
+	if err != nil {
+		cli.PrintError("Failed to load recorded response", err)
+		return 1
+	}
+	if original != nil {
+		if original.Body, err = messageBody(original); err != nil {
+			cli.PrintWarning(fmt.Sprintf("Recorded response body unavailable: %v", err))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	label := msg.Method
+	if label == "" {
+		label = msg.URL
+	}
+	cli.PrintInfo(fmt.Sprintf("Replaying %s to %s", label, msg.URL))
+	switch {
+	case original == nil:
+		fmt.Println("   Recorded: no response")
+	case original.StatusCode == 0:
+		fmt.Printf("   Recorded: %s\n", original.Error)
+	default:
+		fmt.Printf("   Recorded: %d %s in %dms\n", original.StatusCode, http.StatusText(original.StatusCode), original.DurationMs)
+	}
+
+	replayer := proxy.NewReplayer(nil)
+	failed := 0
+	for i := 1; i <= cfg.ReplayTimes; i++ {
+		result, err := replayer.Replay(ctx, msg)
+		if ctx.Err() != nil {
+			fmt.Println("\n📍 Interrupted")
+			break
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("\n#%d failed: %v\n", i, err)
+			continue
+		}
+		if result.StatusCode >= 400 {
+			failed++
+		}
+
+		fmt.Printf("\n#%d %d %s in %dms\n", i, result.StatusCode, http.StatusText(result.StatusCode), result.Duration.Milliseconds())
+		if i == 1 {
+			fmt.Println(string(result.Body))
+		}
+		if original == nil {
+			continue
+		}
+		diff := proxy.CompareResponses(original, &store.Message{
+			StatusCode: result.StatusCode,
+			Body:       string(result.Body),
+			DurationMs: result.Duration.Milliseconds(),
+		})
+		if original.StatusCode != 0 && diff.StatusChanged {
+			fmt.Printf("   Status changed: %d -> %d\n", diff.OriginalStatus, diff.ReplayStatus)
+		}
+		if len(diff.Fields) > 0 {
+			fmt.Println("   Body differs from the recorded response:")
+			for _, field := range diff.Fields {
+				fmt.Println("   " + formatFieldDiff(field))
+			}
+		} else if original.StatusCode != 0 {
+			fmt.Println("   Body matches the recorded response")
+		}
+	}
+
+	if failed > 0 {
+		cli.PrintWarning(fmt.Sprintf("%d of %d sends failed", failed, cfg.ReplayTimes))
+		return 1
+	}
+	return 0
+}
+
+// messageBody returns a message's body, reading it from the blob store if it
+// was offloaded there
+func messageBody(msg *store.Message) (string, error) {
+	if msg.BodyPath == "" {
+		return msg.Body, nil
+	}
+	data, err := os.ReadFile(msg.BodyPath)
+	return string(data), err
+}
+
+// formatFieldDiff describes one changed field of a replayed response, e.g.
+// `~ result.status.state: "completed" -> "failed"`
+func formatFieldDiff(field proxy.FieldDiff) string {
+	path := field.Path
+	if path == "" {
+		path = "(body)"
+	}
+	switch field.Change {
+	case "added":
+		return fmt.Sprintf("+ %s: %s", path, formatFieldValue(field.New))
+	case "removed":
+		return fmt.Sprintf("- %s: %s", path, formatFieldValue(field.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", path, formatFieldValue(field.Old), formatFieldValue(field.New))
+	}
+}
+
+// formatFieldValue renders a decoded JSON value as JSON, leaving characters
+// such as < and > unescaped
+func formatFieldValue(v interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/proxy"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// silenceOutput discards what the test prints to stdout and stderr until it
+// ends
+func silenceOutput(t *testing.T) {
+	t.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		devNull.Close()
+	})
+}
+
+// recordCall writes a trace database holding one request to url and its
+// response, returning the database path and the request's ID
+func recordCall(t *testing.T, url string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.db")
+	dataStore, err := store.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataStore.Close()
+	trace, err := dataStore.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, msg := range []*store.Message{
+		{ID: "req-1", Direction: "request", URL: url, HTTPMethod: "POST", Method: "message/send",
+			Headers: `{"Content-Type":"application/json"}`, Body: `{"jsonrpc":"2.0","id":1,"method":"message/send"}`},
+		{ID: "resp-1", Direction: "response", RequestID: "req-1", StatusCode: 200, Body: `{"jsonrpc":"2.0","id":1,"result":{"state":"completed"}}`},
+	} {
+		msg.TraceID = trace.ID
+		msg.Timestamp = now
+		if err := dataStore.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path, "req-1"
+}
+
+func TestReplayResendsRecordedRequest(t *testing.T) {
+	silenceOutput(t)
+	var sends atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || string(body) != `{"jsonrpc":"2.0","id":1,"method":"message/send"}` {
+			t.Errorf("agent got %s %s", r.Method, body)
+		}
+		sends.Add(1)
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{"state":"completed"}}`)
+	}))
+	defer agent.Close()
+	dbPath, id := recordCall(t, agent.URL)
+
+	if code := runReplay(&cli.Config{DBPath: dbPath, ReplayMessageID: id, ReplayTimes: 3}); code != 0 {
+		t.Errorf("exit code %d, want 0", code)
+	}
+	if n := sends.Load(); n != 3 {
+		t.Errorf("agent got %d sends, want 3", n)
+	}
+}
+
+func TestReplayFailsOnErrorResponse(t *testing.T) {
+	silenceOutput(t)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "flaky", http.StatusInternalServerError)
+	}))
+	defer agent.Close()
+	dbPath, id := recordCall(t, agent.URL)
+
+	if code := runReplay(&cli.Config{DBPath: dbPath, ReplayMessageID: id, ReplayTimes: 1}); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	if code := runReplay(&cli.Config{DBPath: dbPath, ReplayMessageID: "missing", ReplayTimes: 1}); code != 1 {
+		t.Errorf("missing message: exit code %d, want 1", code)
+	}
+	if code := runReplay(&cli.Config{DBPath: filepath.Join(t.TempDir(), "none.db"), ReplayMessageID: id, ReplayTimes: 1}); code != 1 {
+		t.Errorf("missing database: exit code %d, want 1", code)
+	}
+}
+
+func TestFormatFieldDiff(t *testing.T) {
+	tests := []struct {
+		field proxy.FieldDiff
+		want  string
+	}{
+		{proxy.FieldDiff{Path: "result.status.state", Change: "changed", Old: "completed", New: "failed"}, `~ result.status.state: "completed" -> "failed"`},
+		{proxy.FieldDiff{Path: "result.artifacts", Change: "added", New: []interface{}{}}, "+ result.artifacts: []"},
+		{proxy.FieldDiff{Path: "error.code", Change: "removed", Old: float64(-32001)}, "- error.code: -32001"},
+		{proxy.FieldDiff{Change: "changed", Old: "ok", New: "Bad Gateway"}, `~ (body): "ok" -> "Bad Gateway"`},
+		{proxy.FieldDiff{Path: "result.text", Change: "changed", Old: "<b>&</b>", New: "a > b"}, `~ result.text: "<b>&</b>" -> "a > b"`},
+	}
+	for _, tt := range tests {
+		if got := formatFieldDiff(tt.field); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/harry-kp/a2a-trace/internal/analyzer"
+	"github.com/harry-kp/a2a-trace/internal/cli"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// summaryFile is the layout written by --summary-out
+type summaryFile struct {
+	TraceID  string                 `json:"trace_id"`
+	ExitCode int                    `json:"exit_code"`
+	Summary  map[string]interface{} `json:"summary"`
+	Insights []*store.Insight       `json:"insights"`
+}
+
+// writeSummary writes the end-of-trace summary and the trace's insights to
+// path as JSON, creating parent directories as needed
+func writeSummary(path string, dataStore *store.Store, traceID string, summary map[string]interface{}, exitCode int) error {
+	insights, err := dataStore.GetInsights(traceID)
+	if err != nil {
+		return fmt.Errorf("failed to load insights: %w", err)
+	}
+	if insights == nil {
+		insights = []*store.Insight{}
+	}
+
+	data, err := json.MarshalIndent(summaryFile{
+		TraceID:  traceID,
+		ExitCode: exitCode,
+		Summary:  summary,
+		Insights: insights,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// checkAssertions evaluates --assert assertions against the finished trace
+// and prints each outcome. It reports whether all of them passed.
+func checkAssertions(assertions []*analyzer.Assertion, dataStore *store.Store, traceID string) ([]analyzer.AssertionResult, bool) {
+	messages, err := dataStore.GetMessages(traceID)
+	if err != nil {
+		cli.PrintError("Failed to check assertions", err)
+		return nil, false
+	}
+	insights, err := dataStore.GetInsights(traceID)
+	if err != nil {
+		cli.PrintError("Failed to check assertions", err)
+		return nil, false
+	}
+
+	results := analyzer.CheckAssertions(assertions, messages, insights)
+	passed := true
+	for _, result := range results {
+		if result.Passed {
+			cli.PrintSuccess(fmt.Sprintf("%s: %s", result.Name, result.Detail))
+		} else {
+			passed = false
+			cli.PrintError("Assertion failed", fmt.Errorf("%s: %s", result.Name, result.Detail))
+		}
+	}
+	fmt.Println()
+	return results, passed
+}
+
+// sortedKeys returns the agents of a per-agent count in order, for printing
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/analyzer"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestWriteSummary(t *testing.T) {
+	dir := t.TempDir()
+	dataStore, err := store.New(filepath.Join(dir, "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataStore.Close()
+	trace, err := dataStore.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A short session with one failed call
+	a := analyzer.New(analyzer.Config{Store: dataStore, TraceID: trace.ID})
+	now := time.Now()
+	for _, msg := range []*store.Message{
+		{ID: "req-1", Direction: "request", Method: "message/send", Timestamp: now},
+		{ID: "resp-1", Direction: "response", RequestID: "req-1", StatusCode: 500, Timestamp: now},
+	} {
+		msg.TraceID = trace.ID
+		if err := dataStore.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		a.AnalyzeMessage(msg)
+	}
+
+	path := filepath.Join(dir, "artifacts", "ci", "summary.json")
+	if err := writeSummary(path, dataStore, trace.ID, a.GetSummary(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"trace_id", "exit_code", "summary", "insights"} {
+		if _, ok := file[key]; !ok {
+			t.Errorf("summary file lacks %q", key)
+		}
+	}
+
+	var summary map[string]interface{}
+	if err := json.Unmarshal(file["summary"], &summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"total_messages", "total_insights", "error_count", "avg_duration_ms", "method_counts"} {
+		if _, ok := summary[key]; !ok {
+			t.Errorf("summary lacks %q", key)
+		}
+	}
+	if summary["total_messages"] != 2.0 || summary["error_count"] != 1.0 {
+		t.Errorf("got %v messages and %v errors, want 2 and 1", summary["total_messages"], summary["error_count"])
+	}
+
+	var insights []*store.Insight
+	if err := json.Unmarshal(file["insights"], &insights); err != nil {
+		t.Fatal(err)
+	}
+	if len(insights) == 0 {
+		t.Error("summary file has no insights for the failed call")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build ui
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The UI is copied into ui/out by scripts/build.sh before building with -tags ui
+//
+//go:embed ui/out/*
+var uiFS embed.FS
+
+// newUIHandler serves the embedded UI
+func newUIHandler() http.Handler {
+	uiContent, err := fs.Sub(uiFS, "ui/out")
+	if err != nil {
+		panic(err) // ui/out is guaranteed to exist by the embed directive
+	}
+	return http.FileServer(http.FS(uiContent))
+}
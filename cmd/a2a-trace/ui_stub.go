@@ -0,0 +1,98 @@
+//go:build !ui
+
+package main
+
+import "net/http"
+
+// newUIHandler serves a placeholder page; build with -tags ui to embed the real UI
+func newUIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(placeholderHTML))
+	})
+}
+
+const placeholderHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>A2A Trace</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
+            background: linear-gradient(135deg, #1a1a2e 0%, #16213e 50%, #0f3460 100%);
+            color: #e4e4e7;
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .container {
+            text-align: center;
+            padding: 2rem;
+        }
+        h1 {
+            font-size: 3rem;
+            margin-bottom: 1rem;
+            background: linear-gradient(90deg, #3b82f6, #8b5cf6);
+            -webkit-background-clip: text;
+            -webkit-text-fill-color: transparent;
+        }
+        p {
+            color: #a1a1aa;
+            margin-bottom: 2rem;
+            font-size: 1.125rem;
+        }
+        .status {
+            display: inline-flex;
+            align-items: center;
+            gap: 0.5rem;
+            padding: 0.75rem 1.5rem;
+            background: rgba(34, 197, 94, 0.1);
+            border: 1px solid rgba(34, 197, 94, 0.3);
+            border-radius: 9999px;
+            color: #22c55e;
+        }
+        .dot {
+            width: 8px;
+            height: 8px;
+            background: #22c55e;
+            border-radius: 50%;
+            animation: pulse 2s infinite;
+        }
+        @keyframes pulse {
+            0%, 100% { opacity: 1; }
+            50% { opacity: 0.5; }
+        }
+        .info {
+            margin-top: 2rem;
+            padding: 1rem;
+            background: rgba(255,255,255,0.05);
+            border-radius: 0.5rem;
+            font-family: monospace;
+            font-size: 0.875rem;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>🔍 A2A Trace</h1>
+        <p>Visual debugger for multi-agent systems</p>
+        <div class="status">
+            <span class="dot"></span>
+            Tracing Active
+        </div>
+        <div class="info">
+            <p>This binary was built without the UI (see README: Building from Source).</p>
+            <p>The API is still available:</p>
+            <p>GET /api/messages - List all messages</p>
+            <p>GET /api/agents - List discovered agents</p>
+            <p>GET /api/insights - List insights</p>
+            <p>GET /api/export - Export trace as JSON</p>
+            <p>WS /ws - WebSocket for real-time updates</p>
+        </div>
+    </div>
+</body>
+</html>`
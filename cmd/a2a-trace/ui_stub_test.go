@@ -0,0 +1,23 @@
+//go:build !ui
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIStubServesPlaceholder(t *testing.T) {
+	for _, path := range []string{"/", "/index.html", "/messages/abc"} {
+		rec := httptest.NewRecorder()
+		newUIHandler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		if rec.Code != 200 || rec.Header().Get("Content-Type") != "text/html" {
+			t.Errorf("%s: got %d %s, want 200 text/html", path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Body.String(), "built without the UI") {
+			t.Errorf("%s: placeholder doesn't say the UI is missing", path)
+		}
+	}
+}
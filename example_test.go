@@ -0,0 +1,52 @@
+package a2atrace_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	a2atrace "github.com/harry-kp/a2a-trace"
+)
+
+func ExampleNewSession() {
+	// A stand-in agent answering every call
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"t1","status":{"state":"completed"}}}`)
+	}))
+	defer agent.Close()
+
+	session, err := a2atrace.NewSession(a2atrace.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := session.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer session.Stop()
+
+	resp, err := session.HTTPClient().Post(agent.URL, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp.Body.Close()
+
+	messages, err := session.Messages()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, msg := range messages {
+		if msg.Direction == "request" {
+			fmt.Println("request", msg.Method)
+		} else {
+			fmt.Println("response", msg.StatusCode)
+		}
+	}
+	// Output:
+	// request message/send
+	// response 200
+}
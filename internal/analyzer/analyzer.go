@@ -2,22 +2,47 @@ package analyzer
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/harry-kp/a2a-trace/internal/clock"
 	"github.com/harry-kp/a2a-trace/internal/store"
 )
 
 // Analyzer detects patterns and issues in A2A traffic
 type Analyzer struct {
-	store          *store.Store
-	traceID        string
-	slowThreshold  time.Duration
-	onInsight      func(*store.Insight)
-	requestTimes   map[string]time.Time
-	methodCounts   map[string]int
-	agentErrors    map[string]int
+	store         *store.Store
+	traceID       string // Guarded by mu, changed by SetTrace
+	slowThreshold time.Duration
+	hungThreshold time.Duration
+	slo           time.Duration
+	onInsight     func(*store.Insight)
+	newID         func() string
+	clock         clock.Clock
+	rules         []*Rule
+	sinks         []store.MessageSink
+	pending       map[string]pendingRequest // Request ID -> request awaiting a response
+	requestIDs    map[string]string         // Pending request ID -> JSON type of its JSON-RPC id
+	methodCounts  map[string]int
+	retryCounts   map[string]int          // Original request ID -> identical retries seen
+	recentCalls   map[string][]recentCall // Agent and method -> calls in the N+1 window
+	agentErrors   map[string]int
+	mu            sync.Mutex
+	done          chan struct{}
+	stopOnce      sync.Once
+}
+
+// pendingRequest is a request still awaiting its response
+type pendingRequest struct {
+	sent    time.Time
+	traceID string // Trace it was recorded in, which may since have been switched from
+	method  string
+	url     string
 }
 
 // Config holds analyzer configuration
@@ -25,7 +50,13 @@ type Config struct {
 	Store         *store.Store
 	TraceID       string
 	SlowThreshold time.Duration
+	HungThreshold time.Duration // Grace period before a pending request is flagged as hung
+	SLO           time.Duration // Target response latency; GetSummary counts breaches per agent (0: off)
 	OnInsight     func(*store.Insight)
+	Rules         []*Rule             // User-defined rules, see LoadRules
+	NewID         func() string       // Insight id generator, must be safe for concurrent use (default: random UUIDs)
+	Clock         clock.Clock         // Time source for insight timestamps and hung-request checks (default: system clock)
+	Sinks         []store.MessageSink // Also receive every insight saved to Store
 }
 
 // New creates a new Analyzer instance
@@ -35,29 +66,156 @@ func New(cfg Config) *Analyzer {
 		threshold = time.Second // Default 1 second
 	}
 
+	hungThreshold := cfg.HungThreshold
+	if hungThreshold == 0 {
+		hungThreshold = 2 * threshold
+	}
+
+	newID := cfg.NewID
+	if newID == nil {
+		newID = func() string { return uuid.New().String() }
+	}
+
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
 	return &Analyzer{
 		store:         cfg.Store,
 		traceID:       cfg.TraceID,
 		slowThreshold: threshold,
+		hungThreshold: hungThreshold,
+		slo:           cfg.SLO,
 		onInsight:     cfg.OnInsight,
-		requestTimes:  make(map[string]time.Time),
+		newID:         newID,
+		clock:         clk,
+		rules:         cfg.Rules,
+		sinks:         cfg.Sinks,
+		pending:       make(map[string]pendingRequest),
+		requestIDs:    make(map[string]string),
 		methodCounts:  make(map[string]int),
+		retryCounts:   make(map[string]int),
+		recentCalls:   make(map[string][]recentCall),
 		agentErrors:   make(map[string]int),
+		done:          make(chan struct{}),
 	}
 }
 
+// Run periodically checks for hung requests until Stop is called
+func (a *Analyzer) Run() {
+	interval := a.hungThreshold / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Check()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// Check runs the periodic checks once at the clock's current time,
+// returning the insights emitted. Run calls it on a real ticker; with a
+// fake clock, call it after advancing the clock to step the checks.
+func (a *Analyzer) Check() []*store.Insight {
+	insights := a.checkHungRequests(a.clock.Now())
+	a.emit(insights)
+	return insights
+}
+
+// Stop stops the hung request watcher
+func (a *Analyzer) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.done)
+	})
+}
+
 // AnalyzeMessage analyzes a message and generates insights
 func (a *Analyzer) AnalyzeMessage(msg *store.Message) []*store.Insight {
+	// Bodies offloaded to the blob store are checked like any other
+	if msg.BodyPath != "" {
+		if body, err := store.LoadBody(msg); err == nil {
+			loaded := *msg
+			loaded.Body, loaded.BodyPath = body, ""
+			msg = &loaded
+		}
+	}
+
+	a.mu.Lock()
+	insights := a.analyzeLocked(msg)
+	a.mu.Unlock()
+
+	a.emit(insights)
+	return insights
+}
+
+// analyzeLocked runs all per-message checks; callers must hold a.mu
+func (a *Analyzer) analyzeLocked(msg *store.Message) []*store.Insight {
 	var insights []*store.Insight
 
+	// CORS preflights carry no A2A call and are answered by the agent's web
+	// server, so they would only skew method counts and latency. Redirect
+	// hops don't answer the request; its final response follows.
+	if msg.Preflight || msg.Redirect {
+		return nil
+	}
+
 	if msg.Direction == "request" {
-		a.requestTimes[msg.ID] = msg.Timestamp
+		// Notifications never get a JSON-RPC response, so don't wait for one
+		if !msg.IsNotification {
+			a.pending[msg.ID] = pendingRequest{
+				sent:    msg.Timestamp,
+				traceID: a.traceID,
+				method:  msg.Method,
+				url:     msg.URL,
+			}
+			if msg.IDType != "" {
+				a.requestIDs[msg.ID] = msg.IDType
+			}
+		}
 		a.methodCounts[msg.Method]++
+
+		// Check for credentials sent in the body
+		if insight := a.checkCredentialLeak(msg); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// Check params against what the method expects
+		if insight := a.checkParams(msg); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// Check for one call per item where a batch would do
+		if insight := a.checkNPlusOne(msg); insight != nil {
+			insights = append(insights, insight)
+		}
 	}
 
 	if msg.Direction == "response" {
-		// Check for slow responses
-		if insight := a.checkSlowResponse(msg); insight != nil {
+		// Check for timing that points at a mismatched request, while it's
+		// still known when the request was sent
+		if insight := a.checkBadTiming(msg); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// The request has been answered, stop watching it
+		requestIDType := a.requestIDs[msg.RequestID]
+		delete(a.pending, msg.RequestID)
+		delete(a.requestIDs, msg.RequestID)
+
+		// Check for slow responses, blaming the TLS handshake instead of the
+		// agent when it took most of the time
+		if insight := a.checkSlowHandshake(msg); insight != nil {
+			insights = append(insights, insight)
+		} else if insight := a.checkSlowResponse(msg); insight != nil {
 			insights = append(insights, insight)
 		}
 
@@ -67,7 +225,22 @@ func (a *Analyzer) AnalyzeMessage(msg *store.Message) []*store.Insight {
 		}
 
 		// Check for protocol violations
-		if insight := a.checkProtocolViolation(msg); insight != nil {
+		if insight := a.checkProtocolViolation(msg, requestIDType); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// Check that JSON responses actually carry JSON
+		if insight := a.checkContentTypeMismatch(msg); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// Check for responses cut off at the size limit
+		if insight := a.checkResponseTooLarge(msg); insight != nil {
+			insights = append(insights, insight)
+		}
+
+		// Check for upstream certificates about to expire
+		if insight := a.checkCertExpiring(msg); insight != nil {
 			insights = append(insights, insight)
 		}
 	}
@@ -77,18 +250,106 @@ func (a *Analyzer) AnalyzeMessage(msg *store.Message) []*store.Insight {
 		insights = append(insights, insight)
 	}
 
-	// Save and broadcast insights
+	// Evaluate user-defined rules
+	insights = append(insights, a.checkRules(msg)...)
+
+	return insights
+}
+
+// emit saves and broadcasts insights. Sinks get them after the store has
+// merged repeats, so they see the same id and occurrence count.
+func (a *Analyzer) emit(insights []*store.Insight) {
 	for _, insight := range insights {
-		if err := a.store.SaveInsight(insight); err == nil {
-			if a.onInsight != nil {
-				a.onInsight(insight)
+		if err := a.store.SaveInsight(insight); err != nil {
+			continue
+		}
+		for _, sink := range a.sinks {
+			if err := sink.SaveInsight(insight); err != nil {
+				log.Printf("Failed to write insight to sink: %v", err)
 			}
 		}
+		if a.onInsight != nil {
+			a.onInsight(insight)
+		}
+	}
+}
+
+// checkHungRequests flags requests that have waited longer than the grace period
+func (a *Analyzer) checkHungRequests(now time.Time) []*store.Insight {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var insights []*store.Insight
+	for id, req := range a.pending {
+		waited := now.Sub(req.sent)
+		if waited < a.hungThreshold {
+			continue
+		}
+
+		// Only report each hung request once
+		delete(a.pending, id)
+		delete(a.requestIDs, id)
+
+		// A request sent before the trace changed belongs to its own trace
+		insights = append(insights, &store.Insight{
+			ID:          a.newID(),
+			TraceID:     req.traceID,
+			MessageID:   id,
+			Type:        "warning",
+			Category:    "hung_request",
+			Severity:    75,
+			Title:       "Request Never Received a Response",
+			Details:     formatHungRequestDetails(waited),
+			Timestamp:   now,
+			Fingerprint: fingerprint("hung_request", req.method, endpointOf(req.url)),
+		})
 	}
 
 	return insights
 }
 
+// SetTrace switches the analyzer to a new trace. Method, error and retry
+// counts start over; requests still awaiting a response keep being watched.
+func (a *Analyzer) SetTrace(traceID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.traceID = traceID
+	a.methodCounts = make(map[string]int)
+	a.retryCounts = make(map[string]int)
+	a.recentCalls = make(map[string][]recentCall)
+	a.agentErrors = make(map[string]int)
+}
+
+// currentTrace returns the trace insights are recorded under
+func (a *Analyzer) currentTrace() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.traceID
+}
+
+// RecordThrottle emits an insight for a request the proxy queued or refused
+// under its concurrency limit
+func (a *Analyzer) RecordThrottle(targetURL string, waited time.Duration, rejected bool) {
+	insight := &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.currentTrace(),
+		Type:        "warning",
+		Category:    "throttled",
+		Severity:    35,
+		Title:       "Request Queued by Concurrency Limit",
+		Details:     formatThrottleDetails(targetURL, waited, rejected),
+		Fingerprint: fingerprint("throttled", "queued"),
+		Timestamp:   a.clock.Now(),
+	}
+	if rejected {
+		insight.Severity = 60
+		insight.Title = "Request Rejected by Concurrency Limit"
+		insight.Fingerprint = fingerprint("throttled", "rejected")
+	}
+	a.emit([]*store.Insight{insight})
+}
+
 // checkSlowResponse checks if a response is slow
 func (a *Analyzer) checkSlowResponse(msg *store.Message) *store.Insight {
 	if msg.DurationMs <= a.slowThreshold.Milliseconds() {
@@ -96,15 +357,30 @@ func (a *Analyzer) checkSlowResponse(msg *store.Message) *store.Insight {
 	}
 
 	return &store.Insight{
-		ID:        uuid.New().String(),
-		TraceID:   a.traceID,
-		MessageID: msg.ID,
-		Type:      "warning",
-		Category:  "slow_response",
-		Title:     "Slow Response Detected",
-		Details:   formatSlowResponseDetails(msg),
-		Timestamp: time.Now(),
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "slow_response",
+		Severity:    a.slowSeverity(msg.DurationMs),
+		Title:       "Slow Response Detected",
+		Details:     formatSlowResponseDetails(msg),
+		Fingerprint: fingerprint("slow_response", msg.Method, endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// slowSeverity scores a slow response from 30 at the threshold up to 60 at 4x it
+func (a *Analyzer) slowSeverity(durationMs int64) int {
+	threshold := a.slowThreshold.Milliseconds()
+	if threshold <= 0 {
+		return 30
 	}
+	severity := 30 + int(10*(durationMs-threshold)/threshold)
+	if severity > 60 {
+		severity = 60
+	}
+	return severity
 }
 
 // checkError checks for errors in responses
@@ -117,26 +393,43 @@ func (a *Analyzer) checkError(msg *store.Message) *store.Insight {
 	a.agentErrors[msg.FromAgent]++
 
 	insightType := "error"
-	if msg.StatusCode >= 400 && msg.StatusCode < 500 {
+	severity := 70 // JSON-RPC error in a successful HTTP response
+	switch {
+	case msg.StatusCode >= 400 && msg.StatusCode < 500:
 		insightType = "warning"
+		severity = 50
+	case msg.StatusCode >= 500:
+		severity = 80
+	case msg.StatusCode == 0:
+		severity = 85 // Transport failure, the agent was unreachable
 	}
 
 	return &store.Insight{
-		ID:        uuid.New().String(),
-		TraceID:   a.traceID,
-		MessageID: msg.ID,
-		Type:      insightType,
-		Category:  "error",
-		Title:     formatErrorTitle(msg),
-		Details:   formatErrorDetails(msg),
-		Timestamp: time.Now(),
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        insightType,
+		Category:    "error",
+		Subcategory: classifyError(msg),
+		Severity:    severity,
+		Title:       formatErrorTitle(msg),
+		Details:     formatErrorDetails(msg),
+		Fingerprint: fingerprint("error", fmt.Sprint(msg.StatusCode), msg.Error, msg.Method, endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
 	}
 }
 
-// checkProtocolViolation checks for A2A protocol violations
-func (a *Analyzer) checkProtocolViolation(msg *store.Message) *store.Insight {
+// checkProtocolViolation checks for A2A protocol violations. requestIDType
+// is the JSON type of the request's JSON-RPC id, if known, which the
+// response must echo unchanged.
+func (a *Analyzer) checkProtocolViolation(msg *store.Message, requestIDType string) *store.Insight {
 	var violations []string
 
+	// Strict clients match ids by value and type, so 1 and "1" differ
+	if requestIDType != "" && msg.IDType != "" && msg.IDType != "null" && msg.IDType != requestIDType {
+		violations = append(violations, fmt.Sprintf("Response 'id' is a %s but the request's was a %s", msg.IDType, requestIDType))
+	}
+
 	// Check response body for JSON-RPC compliance
 	if msg.Body != "" {
 		var resp map[string]interface{}
@@ -145,7 +438,7 @@ func (a *Analyzer) checkProtocolViolation(msg *store.Message) *store.Insight {
 			if _, ok := resp["jsonrpc"]; !ok {
 				violations = append(violations, "Missing 'jsonrpc' field")
 			}
-			if _, ok := resp["id"]; !ok {
+			if _, ok := resp["id"]; !ok && !msg.IsNotification {
 				// id can be null for notifications, but should exist for responses
 				if msg.StatusCode >= 200 && msg.StatusCode < 300 {
 					if _, hasResult := resp["result"]; hasResult {
@@ -161,19 +454,138 @@ func (a *Analyzer) checkProtocolViolation(msg *store.Message) *store.Insight {
 	}
 
 	return &store.Insight{
-		ID:        uuid.New().String(),
-		TraceID:   a.traceID,
-		MessageID: msg.ID,
-		Type:      "warning",
-		Category:  "protocol_violation",
-		Title:     "A2A Protocol Violation",
-		Details:   strings.Join(violations, "; "),
-		Timestamp: time.Now(),
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "protocol_violation",
+		Severity:    45,
+		Title:       "A2A Protocol Violation",
+		Details:     strings.Join(violations, "; "),
+		Fingerprint: fingerprint("protocol_violation", strings.Join(violations, "; "), endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// checkContentTypeMismatch checks for responses declared as JSON whose body
+// isn't, such as an HTML error page from a load balancer
+func (a *Analyzer) checkContentTypeMismatch(msg *store.Message) *store.Insight {
+	if !strings.Contains(strings.ToLower(msg.ContentType), "json") {
+		return nil
+	}
+	// A body cut off at the size limit is never valid JSON
+	body := strings.TrimSpace(msg.Body)
+	if body == "" || msg.Truncated || json.Valid([]byte(body)) {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "content_type_mismatch",
+		Severity:    50,
+		Title:       "Response Body Is Not JSON",
+		Details:     formatContentTypeMismatchDetails(msg),
+		Fingerprint: fingerprint("content_type_mismatch", endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// checkResponseTooLarge checks for responses over the proxy's size limit,
+// which were streamed to the client and recorded truncated
+func (a *Analyzer) checkResponseTooLarge(msg *store.Message) *store.Insight {
+	if !msg.Truncated {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "response_too_large",
+		Severity:    55,
+		Title:       "Response Too Large",
+		Details:     formatResponseTooLargeDetails(msg),
+		Fingerprint: fingerprint("response_too_large", endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// checkCredentialLeak checks request bodies for likely secrets
+func (a *Analyzer) checkCredentialLeak(msg *store.Message) *store.Insight {
+	kinds := scanForSecrets(msg.Body)
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "credential_leak",
+		Severity:    65,
+		Title:       "Possible Credential in Request Body",
+		Details:     formatCredentialLeakDetails(msg, kinds),
+		Fingerprint: fingerprint("credential_leak", formatSecretKinds(kinds), endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// checkParams checks a request's params against its method's expected shape
+func (a *Analyzer) checkParams(msg *store.Message) *store.Insight {
+	if _, ok := requiredParams[msg.Method]; !ok {
+		return nil
+	}
+
+	var req store.A2ARequest
+	if err := json.Unmarshal([]byte(msg.Body), &req); err != nil {
+		return nil
+	}
+
+	problems := validateParams(msg.Method, req.Params)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "protocol_violation",
+		Severity:    45,
+		Title:       "Invalid A2A Params",
+		Details:     strings.Join(problems, "; "),
+		Fingerprint: fingerprint("protocol_violation", msg.Method, strings.Join(problems, "; "), endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
 	}
 }
 
 // checkRetryLoop checks for potential retry loops
 func (a *Analyzer) checkRetryLoop(msg *store.Message) *store.Insight {
+	// Identical retries of one request are a stronger signal than repeated methods
+	if msg.RetryOf != "" {
+		a.retryCounts[msg.RetryOf]++
+		if count := a.retryCounts[msg.RetryOf]; count >= 3 {
+			return &store.Insight{
+				ID:          a.newID(),
+				TraceID:     a.traceID,
+				MessageID:   msg.ID,
+				Type:        "warning",
+				Category:    "retry_loop",
+				Severity:    60,
+				Title:       "Request Retried Repeatedly",
+				Details:     formatRetryDetails(msg, count),
+				Fingerprint: fingerprint("retry_loop", "retry_of", msg.RetryOf),
+				Timestamp:   a.clock.Now(),
+			}
+		}
+	}
+
 	if msg.Method == "" {
 		return nil
 	}
@@ -182,24 +594,62 @@ func (a *Analyzer) checkRetryLoop(msg *store.Message) *store.Insight {
 	count := a.methodCounts[msg.Method]
 	if count > 0 && count%5 == 0 {
 		return &store.Insight{
-			ID:        uuid.New().String(),
-			TraceID:   a.traceID,
-			MessageID: msg.ID,
-			Type:      "warning",
-			Category:  "retry_loop",
-			Title:     "Potential Retry Loop Detected",
-			Details:   formatRetryLoopDetails(msg.Method, count),
-			Timestamp: time.Now(),
+			ID:          a.newID(),
+			TraceID:     a.traceID,
+			MessageID:   msg.ID,
+			Type:        "warning",
+			Category:    "retry_loop",
+			Severity:    55,
+			Title:       "Potential Retry Loop Detected",
+			Details:     formatRetryLoopDetails(msg.Method, count),
+			Fingerprint: fingerprint("retry_loop", msg.Method),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
 	return nil
 }
 
+// checkRules evaluates user-defined rules against a message
+func (a *Analyzer) checkRules(msg *store.Message) []*store.Insight {
+	var insights []*store.Insight
+	for _, rule := range a.rules {
+		if !rule.Matches(msg) {
+			continue
+		}
+		insights = append(insights, &store.Insight{
+			ID:          a.newID(),
+			TraceID:     a.traceID,
+			MessageID:   msg.ID,
+			Type:        rule.Type,
+			Category:    rule.Category,
+			Severity:    rule.Severity,
+			Title:       rule.Title,
+			Details:     formatRuleDetails(rule, msg),
+			Fingerprint: fingerprint(rule.Category, rule.Name, msg.Method, endpointOf(msg.URL)),
+			Timestamp:   a.clock.Now(),
+		})
+	}
+	return insights
+}
+
 // GetSummary returns a summary of the analysis
 func (a *Analyzer) GetSummary() map[string]interface{} {
-	insights, _ := a.store.GetInsights(a.traceID)
-	messages, _ := a.store.GetMessages(a.traceID)
+	traceID := a.currentTrace()
+	insights, _ := a.store.GetInsights(traceID)
+	messages, _ := a.store.GetMessages(traceID)
+	agents, _ := a.store.GetAgents()
+
+	a.mu.Lock()
+	methodCounts := make(map[string]int, len(a.methodCounts))
+	for method, count := range a.methodCounts {
+		methodCounts[method] = count
+	}
+	agentErrors := make(map[string]int, len(a.agentErrors))
+	for agent, count := range a.agentErrors {
+		agentErrors[agent] = count
+	}
+	a.mu.Unlock()
 
 	// Calculate statistics
 	var totalDuration int64
@@ -207,7 +657,7 @@ func (a *Analyzer) GetSummary() map[string]interface{} {
 	var successCount int
 
 	for _, msg := range messages {
-		if msg.Direction == "response" {
+		if msg.Direction == "response" && !msg.Preflight && !msg.Redirect {
 			totalDuration += msg.DurationMs
 			if msg.Error != "" || msg.StatusCode >= 400 {
 				errorCount++
@@ -223,15 +673,88 @@ func (a *Analyzer) GetSummary() map[string]interface{} {
 		avgDuration = totalDuration / int64(responseCount)
 	}
 
-	return map[string]interface{}{
-		"total_messages":    len(messages),
-		"total_insights":    len(insights),
-		"error_count":       errorCount,
-		"success_count":     successCount,
-		"avg_duration_ms":   avgDuration,
-		"method_counts":     a.methodCounts,
-		"agent_error_counts": a.agentErrors,
+	// Agents are shared by every trace in the database, so only those this
+	// trace talked to count
+	hosts := make(map[string]bool)
+	for _, msg := range messages {
+		hosts[msg.ToAgent] = true
+		hosts[msg.FromAgent] = true
+	}
+	var traceAgents []*store.Agent
+	for _, agent := range agents {
+		if u, err := url.Parse(agent.URL); err == nil && u.Host != "" && hosts[u.Host] {
+			traceAgents = append(traceAgents, agent)
+		}
+	}
+
+	// Agents store their card's skills as JSON; skip any that don't parse
+	totalSkills := 0
+	for _, agent := range traceAgents {
+		var skills []store.Skill
+		if agent.Skills != "" && json.Unmarshal([]byte(agent.Skills), &skills) == nil {
+			totalSkills += len(skills)
+		}
+	}
+
+	summary := map[string]interface{}{
+		"total_messages":     len(messages),
+		"total_insights":     len(insights),
+		"error_count":        errorCount,
+		"success_count":      successCount,
+		"avg_duration_ms":    avgDuration,
+		"unique_agents":      len(traceAgents),
+		"total_skills":       totalSkills,
+		"method_counts":      methodCounts,
+		"agent_error_counts": agentErrors,
+	}
+	if a.slo > 0 {
+		breaches, compliance := sloBreaches(messages, a.slo)
+		summary["slo_ms"] = a.slo.Milliseconds()
+		summary["slo_breaches"] = breaches
+		summary["slo_compliance"] = compliance
+	}
+	return summary
+}
+
+// sloBreaches counts, per agent, the responses slower than the SLO, and the
+// percentage of responses within it
+func sloBreaches(messages []*store.Message, slo time.Duration) (map[string]int, map[string]float64) {
+	// Failed requests are answered by the proxy, so take the agent from the
+	// request
+	agents := make(map[string]string)
+	for _, msg := range messages {
+		if msg.Direction == "request" {
+			agents[msg.ID] = msg.ToAgent
+		}
+	}
+
+	type sloStats struct{ responses, breaches int }
+	stats := make(map[string]*sloStats)
+	for _, msg := range messages {
+		if msg.Direction != "response" || msg.Preflight || msg.Redirect {
+			continue
+		}
+		agent := agents[msg.RequestID]
+		if agent == "" {
+			agent = msg.FromAgent
+		}
+		if stats[agent] == nil {
+			stats[agent] = &sloStats{}
+		}
+		stats[agent].responses++
+		if time.Duration(msg.DurationMs)*time.Millisecond > slo {
+			stats[agent].breaches++
+		}
+	}
+
+	breaches := make(map[string]int, len(stats))
+	compliance := make(map[string]float64, len(stats))
+	for agent, s := range stats {
+		within := float64(s.responses-s.breaches) / float64(s.responses) * 100
+		breaches[agent] = s.breaches
+		compliance[agent] = float64(int(within*10+0.5)) / 10 // One decimal place
 	}
+	return breaches, compliance
 }
 
 // Helper functions for formatting
@@ -247,7 +770,7 @@ func formatSlowResponseDetails(msg *store.Message) string {
 
 func formatErrorTitle(msg *store.Message) string {
 	if msg.StatusCode >= 400 {
-		return "HTTP Error " + string(rune(msg.StatusCode))
+		return fmt.Sprintf("HTTP Error %d", msg.StatusCode)
 	}
 	return "A2A Error Response"
 }
@@ -272,6 +795,78 @@ func formatErrorDetails(msg *store.Message) string {
 	return formatDetails(details)
 }
 
+func formatHungRequestDetails(waited time.Duration) string {
+	return formatDetails(map[string]interface{}{
+		"waiting_ms": waited.Milliseconds(),
+		"suggestion": "The agent may be stuck or the connection dropped; check agent logs and add client-side timeouts",
+	})
+}
+
+func formatRuleDetails(rule *Rule, msg *store.Message) string {
+	return formatDetails(map[string]interface{}{
+		"rule":        rule.Name,
+		"condition":   rule.When,
+		"url":         msg.URL,
+		"method":      msg.Method,
+		"status_code": msg.StatusCode,
+	})
+}
+
+func formatThrottleDetails(targetURL string, waited time.Duration, rejected bool) string {
+	details := map[string]interface{}{
+		"url":        targetURL,
+		"suggestion": "Raise --max-concurrency or reduce fan-out",
+	}
+	if rejected {
+		details["status_code"] = 503
+	} else {
+		details["waited_ms"] = waited.Milliseconds()
+	}
+	return formatDetails(details)
+}
+
+func formatContentTypeMismatchDetails(msg *store.Message) string {
+	preview := strings.TrimSpace(msg.Body)
+	if len(preview) > 200 {
+		preview = preview[:200] + "..."
+	}
+	return formatDetails(map[string]interface{}{
+		"content_type": msg.ContentType,
+		"status_code":  msg.StatusCode,
+		"url":          msg.URL,
+		"body_preview": preview,
+		"suggestion":   "The agent, or a proxy in front of it, sent a non-JSON body such as an HTML error page",
+	})
+}
+
+func formatResponseTooLargeDetails(msg *store.Message) string {
+	return formatDetails(map[string]interface{}{
+		"url":        msg.URL,
+		"method":     msg.Method,
+		"size_bytes": msg.Size,
+		"suggestion": "The body was relayed in full but only recorded up to --max-response-size; an agent streaming an ever-growing response may need paging or a size cap",
+	})
+}
+
+func formatCredentialLeakDetails(msg *store.Message, kinds []string) string {
+	return formatDetails(map[string]interface{}{
+		"url":        msg.URL,
+		"method":     msg.Method,
+		"found":      kinds,
+		"suggestion": "Pass credentials in headers or by reference, not in message content",
+	})
+}
+
+func formatRetryDetails(msg *store.Message, retries int) string {
+	return formatDetails(map[string]interface{}{
+		"method":      msg.Method,
+		"url":         msg.URL,
+		"original_id": msg.RetryOf,
+		"retries":     retries,
+		"suggestion":  "Check that the retried call is idempotent and backs off",
+	})
+}
+
 func formatRetryLoopDetails(method string, count int) string {
 	return formatDetails(map[string]interface{}{
 		"method":     method,
@@ -280,8 +875,21 @@ func formatRetryLoopDetails(method string, count int) string {
 	})
 }
 
+// fingerprint identifies repeats of the same finding so the store can
+// collapse them into one insight
+func fingerprint(parts ...string) string {
+	return strings.Join(parts, "|")
+}
+
+// endpointOf strips the query string so repeats against one endpoint match
+func endpointOf(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
 func formatDetails(data map[string]interface{}) string {
 	bytes, _ := json.MarshalIndent(data, "", "  ")
 	return string(bytes)
 }
-
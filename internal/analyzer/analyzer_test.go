@@ -0,0 +1,483 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/clock"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// newTestAnalyzer returns an analyzer over a fresh store and trace, driven
+// by a fake clock
+func newTestAnalyzer(t *testing.T, cfg Config) (*Analyzer, *store.Store, *clock.Fake) {
+	t.Helper()
+
+	st, err := store.New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	trace, err := st.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	cfg.Store = st
+	cfg.TraceID = trace.ID
+	cfg.Clock = clk
+	return New(cfg), st, clk
+}
+
+// insightsOf returns a trace's saved insights in a category
+func insightsOf(t *testing.T, st *store.Store, traceID, category string) []*store.Insight {
+	t.Helper()
+	insights, err := st.GetInsights(traceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matching []*store.Insight
+	for _, insight := range insights {
+		if insight.Category == category {
+			matching = append(matching, insight)
+		}
+	}
+	return matching
+}
+
+func TestHungRequestFlaggedAfterGracePeriod(t *testing.T) {
+	a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	a.AnalyzeMessage(&store.Message{
+		ID:        "req-1",
+		Direction: "request",
+		Method:    "message/send",
+		Timestamp: clk.Now(),
+	})
+
+	clk.Advance(1900 * time.Millisecond)
+	if insights := a.Check(); len(insights) != 0 {
+		t.Fatalf("flagged %d insights before the grace period", len(insights))
+	}
+
+	clk.Advance(200 * time.Millisecond)
+	insights := a.Check()
+	if len(insights) != 1 {
+		t.Fatalf("got %d insights after the grace period, want 1", len(insights))
+	}
+	if insights[0].Category != "hung_request" || insights[0].MessageID != "req-1" {
+		t.Errorf("got %s insight for %q, want hung_request for req-1", insights[0].Category, insights[0].MessageID)
+	}
+
+	// Each hung request is reported once
+	clk.Advance(time.Minute)
+	if insights := a.Check(); len(insights) != 0 {
+		t.Errorf("flagged the request again: %d insights", len(insights))
+	}
+}
+
+func TestHungRequestKeepsItsTrace(t *testing.T) {
+	a, st, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+	first := a.currentTrace()
+	a.AnalyzeMessage(&store.Message{ID: "req-1", Direction: "request", Method: "message/send", URL: "http://agent/rpc", Timestamp: clk.Now()})
+
+	second, err := st.CreateTrace("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetTrace(second.ID)
+
+	clk.Advance(time.Minute)
+	insights := a.Check()
+	if len(insights) != 1 {
+		t.Fatalf("got %d insights, want 1", len(insights))
+	}
+	if insights[0].TraceID != first {
+		t.Errorf("insight recorded in trace %s, want the request's trace %s", insights[0].TraceID, first)
+	}
+	if insights[0].Fingerprint == "" {
+		t.Error("insight has no fingerprint")
+	}
+	if got := insightsOf(t, st, second.ID, "hung_request"); len(got) != 0 {
+		t.Errorf("new trace got %d hung_request insights, want 0", len(got))
+	}
+}
+
+func TestAnsweredRequestNotFlaggedAsHung(t *testing.T) {
+	a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	a.AnalyzeMessage(&store.Message{ID: "req-1", Direction: "request", Timestamp: clk.Now()})
+	clk.Advance(500 * time.Millisecond)
+	a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", RequestID: "req-1", StatusCode: 200, Timestamp: clk.Now()})
+
+	clk.Advance(time.Minute)
+	if insights := a.Check(); len(insights) != 0 {
+		t.Errorf("flagged an answered request: %+v", insights[0])
+	}
+}
+
+func TestNotificationNotFlaggedAsHung(t *testing.T) {
+	a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	a.AnalyzeMessage(&store.Message{
+		ID:             "req-1",
+		Direction:      "request",
+		Method:         "tasks/pushNotification",
+		IsNotification: true,
+		Timestamp:      clk.Now(),
+	})
+
+	clk.Advance(time.Minute)
+	if insights := a.Check(); len(insights) != 0 {
+		t.Errorf("flagged a notification as hung: %+v", insights[0])
+	}
+}
+
+func TestErrorsOutrankWarnings(t *testing.T) {
+	a, st, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	a.AnalyzeMessage(&store.Message{ID: "req-1", Direction: "request", Method: "message/send", Timestamp: clk.Now()})
+	a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", RequestID: "req-1", StatusCode: 200, DurationMs: 3000, Timestamp: clk.Now()})
+	a.AnalyzeMessage(&store.Message{ID: "req-2", Direction: "request", Method: "message/send", Timestamp: clk.Now()})
+	a.AnalyzeMessage(&store.Message{ID: "resp-2", Direction: "response", RequestID: "req-2", StatusCode: 500, Timestamp: clk.Now()})
+
+	insights, err := st.GetInsights(a.currentTrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insights) < 2 {
+		t.Fatalf("got %d insights, want a slow response and an error", len(insights))
+	}
+	if insights[0].Category != "error" {
+		t.Errorf("first insight is %s, want the error", insights[0].Category)
+	}
+	severities := make(map[string]int)
+	for _, insight := range insights {
+		severities[insight.Category] = insight.Severity
+	}
+	if severities["error"] <= severities["slow_response"] {
+		t.Errorf("error severity %d doesn't outrank slow response %d", severities["error"], severities["slow_response"])
+	}
+}
+
+func TestRepeatedSlowResponsesCollapse(t *testing.T) {
+	a, st, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+	first := clk.Now()
+
+	for i := 0; i < 5; i++ {
+		reqID := fmt.Sprintf("req-%d", i)
+		a.AnalyzeMessage(&store.Message{ID: reqID, Direction: "request", Method: "message/send", URL: "http://agent.example/rpc", Timestamp: clk.Now()})
+		a.AnalyzeMessage(&store.Message{
+			ID:         fmt.Sprintf("resp-%d", i),
+			Direction:  "response",
+			RequestID:  reqID,
+			Method:     "message/send",
+			URL:        "http://agent.example/rpc?attempt=" + fmt.Sprint(i),
+			StatusCode: 200,
+			DurationMs: 2500,
+			Timestamp:  clk.Now(),
+		})
+		clk.Advance(time.Second)
+	}
+
+	insights := insightsOf(t, st, a.currentTrace(), "slow_response")
+	if len(insights) != 1 {
+		t.Fatalf("got %d slow_response insights, want 1", len(insights))
+	}
+	insight := insights[0]
+	if insight.Occurrences != 5 {
+		t.Errorf("got %d occurrences, want 5", insight.Occurrences)
+	}
+	if !insight.Timestamp.Equal(first) || !insight.LastSeen.Equal(first.Add(4*time.Second)) {
+		t.Errorf("first seen %v, last seen %v; want %v and 4s later", insight.Timestamp, insight.LastSeen, first)
+	}
+}
+
+func TestRecordThrottle(t *testing.T) {
+	a, st, _ := newTestAnalyzer(t, Config{})
+
+	a.RecordThrottle("http://agent.example/rpc", 30*time.Millisecond, false)
+	a.RecordThrottle("http://agent.example/rpc", 0, true)
+	a.RecordThrottle("http://agent.example/rpc", 0, true)
+
+	insights := insightsOf(t, st, a.currentTrace(), "throttled")
+	if len(insights) != 2 {
+		t.Fatalf("got %d throttled insights, want queued and rejected", len(insights))
+	}
+	// Rejections rank first and repeats collapse
+	if insights[0].Title != "Request Rejected by Concurrency Limit" || insights[0].Occurrences != 2 {
+		t.Errorf("first insight %q with %d occurrences", insights[0].Title, insights[0].Occurrences)
+	}
+}
+
+func TestInjectedInsightIDs(t *testing.T) {
+	n := 0
+	a, _, _ := newTestAnalyzer(t, Config{NewID: func() string {
+		n++
+		return fmt.Sprintf("insight-%d", n)
+	}})
+
+	insights := a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", StatusCode: 500})
+	if len(insights) != 1 || insights[0].ID != "insight-1" {
+		t.Fatalf("got %d insights, want one with id insight-1", len(insights))
+	}
+}
+
+func TestSlowResponseAtThreshold(t *testing.T) {
+	a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	if insights := a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", StatusCode: 200, DurationMs: 1000}); len(insights) != 0 {
+		t.Errorf("flagged a response at the threshold: %s", insights[0].Category)
+	}
+
+	clk.Advance(time.Minute)
+	insights := a.AnalyzeMessage(&store.Message{ID: "resp-2", Direction: "response", StatusCode: 200, DurationMs: 1001})
+	if len(insights) != 1 || insights[0].Category != "slow_response" {
+		t.Fatalf("got %d insights just over the threshold, want a slow_response", len(insights))
+	}
+	if !insights[0].Timestamp.Equal(clk.Now()) {
+		t.Errorf("insight stamped %v, want the clock's %v", insights[0].Timestamp, clk.Now())
+	}
+}
+
+func TestContentTypeMismatch(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		truncated   bool
+		want        bool
+	}{
+		{"HTML claiming JSON", "application/json", "<html><body>502 Bad Gateway</body></html>", false, true},
+		{"valid JSON", "application/json; charset=utf-8", `{"jsonrpc":"2.0","id":1,"result":{}}`, false, false},
+		{"empty body", "application/json", "  ", false, false},
+		{"truncated JSON", "application/json", `{"jsonrpc":"2.0","id":1,"res`, true, false},
+		{"HTML as HTML", "text/html", "<html></html>", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insight := a.checkContentTypeMismatch(&store.Message{
+				ID:          "resp-1",
+				Direction:   "response",
+				ContentType: tt.contentType,
+				Body:        tt.body,
+				Truncated:   tt.truncated,
+			})
+			if got := insight != nil; got != tt.want {
+				t.Fatalf("flagged = %v, want %v", got, tt.want)
+			}
+			if insight != nil && (insight.Category != "content_type_mismatch" || insight.Type != "warning") {
+				t.Errorf("got a %s %s insight", insight.Category, insight.Type)
+			}
+		})
+	}
+}
+
+// insightSink is a store.MessageSink remembering the insights it was given
+type insightSink struct {
+	insights []*store.Insight
+}
+
+func (s *insightSink) SaveMessage(msg *store.Message) error { return nil }
+func (s *insightSink) SaveAgent(agent *store.Agent) error   { return nil }
+func (s *insightSink) SaveInsight(insight *store.Insight) error {
+	s.insights = append(s.insights, insight)
+	return nil
+}
+
+func TestSinksReceiveMergedInsights(t *testing.T) {
+	sink := &insightSink{}
+	a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second, Sinks: []store.MessageSink{sink}})
+
+	for i := 0; i < 2; i++ {
+		clk.Advance(time.Minute)
+		a.AnalyzeMessage(&store.Message{ID: fmt.Sprintf("resp-%d", i), Direction: "response", URL: "http://agent/rpc", StatusCode: 200, DurationMs: 5000})
+	}
+
+	if len(sink.insights) != 2 {
+		t.Fatalf("sink got %d insights, want 2", len(sink.insights))
+	}
+	if sink.insights[1].ID != sink.insights[0].ID || sink.insights[1].Occurrences != 2 {
+		t.Errorf("repeat reached the sink as %s x%d, want the merged insight", sink.insights[1].ID, sink.insights[1].Occurrences)
+	}
+}
+
+func TestPreflightLeftOutOfStats(t *testing.T) {
+	a, st, clk := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+
+	preflight := &store.Message{ID: "preflight", Direction: "response", Preflight: true, StatusCode: 403, DurationMs: 5000}
+	for _, msg := range []*store.Message{
+		{ID: "resp", Direction: "response", StatusCode: 200, DurationMs: 100},
+		preflight,
+	} {
+		msg.TraceID = a.currentTrace()
+		msg.Timestamp = clk.Now()
+		if err := st.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if insights := a.AnalyzeMessage(preflight); len(insights) != 0 {
+		t.Errorf("slow failed preflight flagged as %s", insights[0].Category)
+	}
+	summary := a.GetSummary()
+	if summary["avg_duration_ms"] != int64(100) || summary["error_count"] != 0 || summary["success_count"] != 1 {
+		t.Errorf("summary counted the preflight: %v", summary)
+	}
+}
+
+func TestSummaryCountsAgentsAndSkills(t *testing.T) {
+	a, st, _ := newTestAnalyzer(t, Config{})
+
+	for _, agent := range []*store.Agent{
+		{URL: "http://planner:8080", Name: "Planner", Skills: `[{"id":"plan","name":"Plan"},{"id":"replan","name":"Replan"}]`},
+		{URL: "http://search:8080", Name: "Search", Skills: `[{"id":"search","name":"Search"}]`},
+		{URL: "http://broken:8080", Name: "Broken", Skills: `[{"id":`},
+	} {
+		if err := st.SaveAgent(agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only agents a trace talked to count toward its summary
+	talkTo := func(traceID string, agents ...string) {
+		t.Helper()
+		for _, agent := range agents {
+			if err := st.SaveMessage(&store.Message{TraceID: traceID, Direction: "request", ToAgent: agent, Timestamp: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	talkTo(a.currentTrace(), "planner:8080", "search:8080", "broken:8080")
+
+	summary := a.GetSummary()
+	if summary["unique_agents"] != 3 || summary["total_skills"] != 3 {
+		t.Errorf("got %v agents with %v skills, want 3 with 3", summary["unique_agents"], summary["total_skills"])
+	}
+
+	second, err := st.CreateTrace("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetTrace(second.ID)
+	talkTo(second.ID, "search:8080")
+
+	summary = a.GetSummary()
+	if summary["unique_agents"] != 1 || summary["total_skills"] != 1 {
+		t.Errorf("second trace: got %v agents with %v skills, want 1 with 1", summary["unique_agents"], summary["total_skills"])
+	}
+}
+
+func TestSummarySLOBreaches(t *testing.T) {
+	a, st, clk := newTestAnalyzer(t, Config{SLO: 300 * time.Millisecond})
+
+	// A response taking exactly the SLO meets it
+	calls := []struct {
+		agent      string
+		durationMs int64
+	}{
+		{"planner:8080", 100}, {"planner:8080", 300}, {"planner:8080", 301}, {"planner:8080", 900},
+		{"search:8080", 50}, {"search:8080", 120}, {"search:8080", 2000},
+	}
+	for i, call := range calls {
+		for _, msg := range []*store.Message{
+			{ID: fmt.Sprint(i), Direction: "request", ToAgent: call.agent},
+			{ID: fmt.Sprint(i, "-resp"), Direction: "response", RequestID: fmt.Sprint(i), StatusCode: 200, DurationMs: call.durationMs},
+		} {
+			msg.TraceID = a.currentTrace()
+			msg.Timestamp = clk.Now()
+			if err := st.SaveMessage(msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	summary := a.GetSummary()
+	breaches, _ := summary["slo_breaches"].(map[string]int)
+	compliance, _ := summary["slo_compliance"].(map[string]float64)
+	if breaches["planner:8080"] != 2 || breaches["search:8080"] != 1 {
+		t.Errorf("breaches %v, want 2 for planner and 1 for search", breaches)
+	}
+	if compliance["planner:8080"] != 50 || compliance["search:8080"] != 66.7 {
+		t.Errorf("compliance %v, want 50%% for planner and 66.7%% for search", compliance)
+	}
+	if summary["slo_ms"] != int64(300) {
+		t.Errorf("slo_ms = %v, want 300", summary["slo_ms"])
+	}
+
+	// Without an SLO there's nothing to report
+	a, _, _ = newTestAnalyzer(t, Config{})
+	if _, ok := a.GetSummary()["slo_breaches"]; ok {
+		t.Error("summary has slo_breaches without an SLO")
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	if insights := a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", StatusCode: 200, Body: `{"jsonrpc":"2.0","id":1,"result":{}}`}); len(insights) != 0 {
+		t.Errorf("whole response flagged as %s", insights[0].Category)
+	}
+
+	// A body cut off at the limit isn't also reported as invalid JSON
+	insights := a.AnalyzeMessage(&store.Message{
+		ID:          "resp-2",
+		Direction:   "response",
+		StatusCode:  200,
+		ContentType: "application/json",
+		Body:        `{"jsonrpc":"2.0","result":{"text":"xxxx`,
+		Size:        10 << 20,
+		Truncated:   true,
+	})
+	if len(insights) != 1 || insights[0].Category != "response_too_large" {
+		t.Fatalf("got %d insights, want one response_too_large", len(insights))
+	}
+}
+
+func TestIDTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		requestType  string
+		responseType string
+		responseBody string
+		want         bool
+	}{
+		{"number echoed as string", "number", "string", `{"jsonrpc":"2.0","id":"1","result":{}}`, true},
+		{"string echoed as number", "string", "number", `{"jsonrpc":"2.0","id":1,"result":{}}`, true},
+		{"same type", "number", "number", `{"jsonrpc":"2.0","id":1,"result":{}}`, false},
+		{"null on a parse error", "number", "null", `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, _ := newTestAnalyzer(t, Config{})
+			a.AnalyzeMessage(&store.Message{
+				ID:        "req-1",
+				Direction: "request",
+				Method:    "tasks/get",
+				Body:      `{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{"id":"task-1"}}`,
+				IDType:    tt.requestType,
+			})
+
+			insights := ofCategory(a.AnalyzeMessage(&store.Message{
+				ID:         "resp-1",
+				RequestID:  "req-1",
+				Direction:  "response",
+				StatusCode: 200,
+				Body:       tt.responseBody,
+				IDType:     tt.responseType,
+			}), "protocol_violation")
+
+			if got := len(insights) == 1 && strings.Contains(insights[0].Details, "Response 'id' is a"); got != tt.want {
+				t.Errorf("flagged = %v, want %v (%d protocol violations)", got, tt.want, len(insights))
+			}
+		})
+	}
+}
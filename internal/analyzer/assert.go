@@ -0,0 +1,210 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// Assertion is a check on a finished trace, used to gate CI runs. Each
+// assertion sets exactly one of:
+//
+//   - none: a rule condition no message may match, e.g. "status_code >= 500"
+//   - metric: a trace metric kept within min and/or max
+//   - no_insight: an insight category that must not have been raised
+type Assertion struct {
+	Name      string   `yaml:"name"`
+	None      string   `yaml:"none"`
+	Metric    string   `yaml:"metric"`
+	Min       *float64 `yaml:"min"`
+	Max       *float64 `yaml:"max"`
+	NoInsight string   `yaml:"no_insight"`
+
+	rule *Rule
+}
+
+// AssertionResult is the outcome of one assertion
+type AssertionResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// assertionsFile is the top-level layout of an assertions file
+type assertionsFile struct {
+	Assertions []*Assertion `yaml:"assertions"`
+}
+
+// assertionMetrics are the metrics an assertion can bound. Latencies are over
+// responses, leaving out CORS preflights and redirect hops.
+var assertionMetrics = []string{
+	"total_messages", "total_responses", "error_count", "error_rate", "total_insights",
+	"avg_duration_ms", "p50_duration_ms", "p95_duration_ms", "p99_duration_ms", "max_duration_ms",
+}
+
+// LoadAssertions reads and checks assertions from a YAML file
+func LoadAssertions(path string) ([]*Assertion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assertions: %w", err)
+	}
+
+	var file assertionsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse assertions: %w", err)
+	}
+	if len(file.Assertions) == 0 {
+		return nil, fmt.Errorf("no assertions in %s", path)
+	}
+
+	for i, assertion := range file.Assertions {
+		if assertion.Name == "" {
+			return nil, fmt.Errorf("assertion %d: missing name", i+1)
+		}
+		if err := assertion.Compile(); err != nil {
+			return nil, fmt.Errorf("assertion %q: %w", assertion.Name, err)
+		}
+	}
+
+	return file.Assertions, nil
+}
+
+// Compile checks the assertion and parses its condition
+func (a *Assertion) Compile() error {
+	kinds := 0
+	for _, set := range []bool{a.None != "", a.Metric != "", a.NoInsight != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("set exactly one of none, metric or no_insight")
+	}
+
+	switch {
+	case a.None != "":
+		a.rule = &Rule{Name: a.Name, When: a.None}
+		return a.rule.Compile()
+	case a.Metric != "":
+		if !slices.Contains(assertionMetrics, a.Metric) {
+			return fmt.Errorf("unknown metric %q (one of %s)", a.Metric, strings.Join(assertionMetrics, ", "))
+		}
+		if a.Min == nil && a.Max == nil {
+			return fmt.Errorf("metric %s needs a min or max", a.Metric)
+		}
+	}
+	return nil
+}
+
+// CheckAssertions evaluates assertions against a trace's messages and insights
+func CheckAssertions(assertions []*Assertion, messages []*store.Message, insights []*store.Insight) []AssertionResult {
+	metrics := traceMetrics(messages, insights)
+
+	results := make([]AssertionResult, 0, len(assertions))
+	for _, assertion := range assertions {
+		result := AssertionResult{Name: assertion.Name, Passed: true}
+
+		switch {
+		case assertion.rule != nil:
+			matched := 0
+			for _, msg := range messages {
+				if assertion.rule.Matches(msg) {
+					matched++
+				}
+			}
+			result.Passed = matched == 0
+			result.Detail = fmt.Sprintf("%d messages match %q", matched, assertion.None)
+
+		case assertion.Metric != "":
+			value := metrics[assertion.Metric]
+			if assertion.Min != nil && value < *assertion.Min {
+				result.Passed = false
+			}
+			if assertion.Max != nil && value > *assertion.Max {
+				result.Passed = false
+			}
+			result.Detail = fmt.Sprintf("%s = %s%s", assertion.Metric, formatMetric(value), formatBounds(assertion.Min, assertion.Max))
+
+		case assertion.NoInsight != "":
+			raised := 0
+			for _, insight := range insights {
+				if insight.Category == assertion.NoInsight {
+					raised += max(insight.Occurrences, 1)
+				}
+			}
+			result.Passed = raised == 0
+			result.Detail = fmt.Sprintf("%d %s insights", raised, assertion.NoInsight)
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// traceMetrics computes the values of assertionMetrics
+func traceMetrics(messages []*store.Message, insights []*store.Insight) map[string]float64 {
+	var durations []int64
+	var total int64
+	errors := 0
+
+	for _, msg := range messages {
+		if msg.Direction != "response" || msg.Preflight || msg.Redirect {
+			continue
+		}
+		durations = append(durations, msg.DurationMs)
+		total += msg.DurationMs
+		if msg.Error != "" || msg.StatusCode >= 400 {
+			errors++
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	metrics := map[string]float64{
+		"total_messages":  float64(len(messages)),
+		"total_responses": float64(len(durations)),
+		"error_count":     float64(errors),
+		"total_insights":  float64(len(insights)),
+	}
+	if len(durations) > 0 {
+		metrics["error_rate"] = float64(errors) / float64(len(durations))
+		metrics["avg_duration_ms"] = float64(total) / float64(len(durations))
+		metrics["p50_duration_ms"] = float64(percentile(durations, 50))
+		metrics["p95_duration_ms"] = float64(percentile(durations, 95))
+		metrics["p99_duration_ms"] = float64(percentile(durations, 99))
+		metrics["max_duration_ms"] = float64(durations[len(durations)-1])
+	}
+	return metrics
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// formatMetric prints whole numbers without decimals
+func formatMetric(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// formatBounds describes an assertion's allowed range, e.g. " (max 500)"
+func formatBounds(lo, hi *float64) string {
+	var bounds []string
+	if lo != nil {
+		bounds = append(bounds, "min "+formatMetric(*lo))
+	}
+	if hi != nil {
+		bounds = append(bounds, "max "+formatMetric(*hi))
+	}
+	return " (" + strings.Join(bounds, ", ") + ")"
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// loadTestAssertions writes an assertions file and loads it
+func loadTestAssertions(t *testing.T, yaml string) ([]*Assertion, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "assertions.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadAssertions(path)
+}
+
+const testAssertions = `
+assertions:
+  - name: No server errors
+    none: status_code >= 500
+  - name: Fast enough
+    metric: p95_duration_ms
+    max: 500
+  - name: No retry loops
+    no_insight: retry_loop
+`
+
+func TestAssertionsPass(t *testing.T) {
+	assertions, err := loadTestAssertions(t, testAssertions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []*store.Message{
+		{Direction: "request"},
+		{Direction: "response", StatusCode: 200, DurationMs: 100},
+		{Direction: "response", StatusCode: 200, DurationMs: 400},
+		// Left out of latency
+		{Direction: "response", StatusCode: 204, DurationMs: 9000, Preflight: true},
+	}
+	insights := []*store.Insight{{Category: "slow_response"}}
+
+	for _, result := range CheckAssertions(assertions, messages, insights) {
+		if !result.Passed {
+			t.Errorf("%s failed: %s", result.Name, result.Detail)
+		}
+	}
+}
+
+func TestAssertionsFail(t *testing.T) {
+	assertions, err := loadTestAssertions(t, testAssertions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []*store.Message{
+		{Direction: "response", StatusCode: 502, DurationMs: 100},
+		{Direction: "response", StatusCode: 200, DurationMs: 800},
+	}
+	insights := []*store.Insight{{Category: "retry_loop", Occurrences: 3}}
+
+	want := map[string]string{
+		"No server errors": `1 messages match "status_code >= 500"`,
+		"Fast enough":      "p95_duration_ms = 800 (max 500)",
+		"No retry loops":   "3 retry_loop insights",
+	}
+	results := CheckAssertions(assertions, messages, insights)
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for _, result := range results {
+		if result.Passed {
+			t.Errorf("%s passed", result.Name)
+		}
+		if result.Detail != want[result.Name] {
+			t.Errorf("%s: detail %q, want %q", result.Name, result.Detail, want[result.Name])
+		}
+	}
+}
+
+func TestLoadAssertionsErrors(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"empty":          "assertions: []",
+		"missing name":   "assertions:\n  - none: status_code >= 500",
+		"two kinds":      "assertions:\n  - name: x\n    none: status_code >= 500\n    no_insight: retry_loop",
+		"unknown metric": "assertions:\n  - name: x\n    metric: p42\n    max: 1",
+		"no bounds":      "assertions:\n  - name: x\n    metric: error_rate",
+		"bad condition":  "assertions:\n  - name: x\n    none: status_code >>> 500",
+	} {
+		if _, err := loadTestAssertions(t, yaml); err == nil {
+			t.Errorf("%s: loaded without error", name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// certExpiryWarning is how long before an upstream certificate expires it
+// gets flagged
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// checkCertExpiring checks for HTTPS upstreams whose certificate expires
+// within certExpiryWarning, or already has (reachable with --insecure)
+func (a *Analyzer) checkCertExpiring(msg *store.Message) *store.Insight {
+	if msg.TLSInfo == "" {
+		return nil
+	}
+	var info store.TLSConnection
+	if err := json.Unmarshal([]byte(msg.TLSInfo), &info); err != nil || info.NotAfter.IsZero() {
+		return nil
+	}
+	left := info.NotAfter.Sub(msg.Timestamp)
+	if left > certExpiryWarning {
+		return nil
+	}
+
+	insight := &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "cert_expiring",
+		Severity:    60,
+		Title:       "Certificate Expiring Soon",
+		Details:     formatCertExpiringDetails(msg, &info, left),
+		Fingerprint: fingerprint("cert_expiring", endpointOf(msg.URL), info.Subject),
+		Timestamp:   a.clock.Now(),
+	}
+	if left <= 0 {
+		insight.Type = "error"
+		insight.Severity = 85
+		insight.Title = "Certificate Expired"
+	}
+	return insight
+}
+
+func formatCertExpiringDetails(msg *store.Message, info *store.TLSConnection, left time.Duration) string {
+	return formatDetails(map[string]interface{}{
+		"url":        msg.URL,
+		"subject":    info.Subject,
+		"issuer":     info.Issuer,
+		"not_after":  info.NotAfter.Format(time.RFC3339),
+		"days_left":  int(left.Hours() / 24),
+		"suggestion": "Renew the agent's certificate before it expires; clients will then refuse to connect",
+	})
+}
@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestCertExpiring(t *testing.T) {
+	a, _, clk := newTestAnalyzer(t, Config{})
+	now := clk.Now()
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		want     string // Insight type, or "" for none
+	}{
+		{"valid for a month", now.Add(30 * 24 * time.Hour), ""},
+		{"expires in three days", now.Add(3 * 24 * time.Hour), "warning"},
+		{"expired yesterday", now.Add(-24 * time.Hour), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, _ := json.Marshal(store.TLSConnection{Version: "TLS 1.3", Subject: "CN=agent", NotAfter: tt.notAfter})
+			insight := a.checkCertExpiring(&store.Message{
+				ID:        "resp-1",
+				Direction: "response",
+				URL:       "https://agent.example/rpc",
+				Timestamp: now,
+				TLSInfo:   string(info),
+			})
+
+			got := ""
+			if insight != nil {
+				got = insight.Type
+				if insight.Category != "cert_expiring" {
+					t.Errorf("category %s", insight.Category)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if insight := a.checkCertExpiring(&store.Message{ID: "resp-2", Direction: "response"}); insight != nil {
+		t.Error("plain HTTP response flagged")
+	}
+}
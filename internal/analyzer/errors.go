@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// Error subcategories set by classifyError
+const (
+	errTimeout           = "timeout"
+	errConnectionRefused = "connection_refused"
+	errDNSFailure        = "dns_failure"
+	errTLS               = "tls_error"
+	errUpstream5xx       = "upstream_5xx"
+	errClient4xx         = "client_4xx"
+	errRPC               = "rpc_error"
+)
+
+// errorPatterns map fragments of transport error strings, as returned by
+// net/http, to subcategories. They are checked in order, and only for
+// responses that never arrived; an agent's own error messages can contain
+// the same words.
+var errorPatterns = []struct {
+	fragment    string
+	subcategory string
+}{
+	{"timeout", errTimeout},
+	{"deadline exceeded", errTimeout},
+	{"no such host", errDNSFailure},
+	{"server misbehaving", errDNSFailure},
+	{"dial tcp: lookup", errDNSFailure},
+	{"tls:", errTLS},
+	{"x509:", errTLS},
+	{"certificate", errTLS},
+	{"connection refused", errConnectionRefused},
+}
+
+// classifyError sorts a failed response into a subcategory of the error
+// insight, from its transport error and status code. Transport failures that
+// match no pattern, such as a dropped connection, are left unclassified.
+func classifyError(msg *store.Message) string {
+	if msg.StatusCode == 0 {
+		errLower := strings.ToLower(msg.Error)
+		for _, pattern := range errorPatterns {
+			if strings.Contains(errLower, pattern.fragment) {
+				return pattern.subcategory
+			}
+		}
+		return ""
+	}
+
+	switch {
+	case msg.StatusCode == 504:
+		return errTimeout // Gateway Timeout: a proxy in front of the agent gave up
+	case msg.StatusCode >= 500:
+		return errUpstream5xx
+	case msg.StatusCode >= 400:
+		return errClient4xx
+	default:
+		return errRPC // An error reported in a successful HTTP response
+	}
+}
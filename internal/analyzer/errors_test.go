@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    string
+		want   string
+	}{
+		{"client timeout", 0, `Post "http://agent/rpc": net/http: request canceled (Client.Timeout exceeded while awaiting headers)`, errTimeout},
+		{"context deadline", 0, "context deadline exceeded", errTimeout},
+		{"dial timeout", 0, "dial tcp 10.0.0.1:443: i/o timeout", errTimeout},
+		{"unknown host", 0, "dial tcp: lookup agent.invalid: no such host", errDNSFailure},
+		{"resolver failure", 0, "dial tcp: lookup agent on 127.0.0.53:53: server misbehaving", errDNSFailure},
+		{"bad certificate", 0, "tls: failed to verify certificate: x509: certificate signed by unknown authority", errTLS},
+		{"refused", 0, "dial tcp 127.0.0.1:9999: connect: connection refused", errConnectionRefused},
+		{"dropped connection", 0, "EOF", ""},
+		{"gateway timeout", 504, "Gateway Timeout", errTimeout},
+		{"server error", 503, "", errUpstream5xx},
+		// An agent's own message mentioning a timeout is still a 5xx
+		{"server error mentioning timeout", 500, "database timeout", errUpstream5xx},
+		{"client error", 404, "", errClient4xx},
+		{"JSON-RPC error", 200, "Task not found", errRPC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(&store.Message{StatusCode: tt.status, Error: tt.err}); got != tt.want {
+				t.Errorf("classifyError = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorInsightSubcategory(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	insights := a.AnalyzeMessage(&store.Message{
+		ID:        "resp-1",
+		Direction: "response",
+		Error:     "dial tcp 127.0.0.1:9999: connect: connection refused",
+	})
+	if len(insights) != 1 || insights[0].Category != "error" || insights[0].Subcategory != errConnectionRefused {
+		t.Fatalf("got %+v, want an error insight with subcategory %s", insights, errConnectionRefused)
+	}
+}
@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"math"
+	"net/url"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// Health score weights. An agent starts at 100 and loses up to:
+//   - errorWeight points in proportion to the share of failed responses
+//   - latencyWeight points as its average response time grows to twice the
+//     slow threshold
+//   - violationPenalty points per protocol violation, up to violationWeight
+const (
+	errorWeight      = 50.0
+	latencyWeight    = 30.0
+	violationWeight  = 20.0
+	violationPenalty = 5.0
+)
+
+// AgentHealth scores an agent over the current trace. agentURL may be any URL
+// of the agent, such as its card URL. It returns nil if the agent hasn't
+// answered any request yet.
+func (a *Analyzer) AgentHealth(agentURL string) (*store.AgentHealth, error) {
+	return a.computeAgentHealth(a.currentTrace(), agentURL)
+}
+
+// computeAgentHealth aggregates an agent's responses and protocol violations
+// in a trace into a health score
+func (a *Analyzer) computeAgentHealth(traceID, agentURL string) (*store.AgentHealth, error) {
+	agent := agentURL
+	if u, err := url.Parse(agentURL); err == nil && u.Host != "" {
+		agent = u.Host
+	}
+
+	messages, err := a.store.GetMessages(traceID)
+	if err != nil {
+		return nil, err
+	}
+	insights, err := a.store.GetInsights(traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &store.AgentHealth{}
+	agentMessages := make(map[string]bool)
+	var totalDuration int64
+	var errors int
+
+	for _, msg := range messages {
+		switch {
+		case msg.Direction == "request" && msg.ToAgent == agent:
+			agentMessages[msg.ID] = true
+		case msg.Direction == "response" && msg.FromAgent == agent && !msg.Preflight && !msg.Redirect:
+			agentMessages[msg.ID] = true
+			health.Responses++
+			totalDuration += msg.DurationMs
+			if msg.Error != "" || msg.StatusCode >= 400 {
+				errors++
+			}
+		}
+	}
+	if health.Responses == 0 {
+		return nil, nil
+	}
+
+	for _, insight := range insights {
+		if insight.Category == "protocol_violation" && agentMessages[insight.MessageID] {
+			health.Violations += max(insight.Occurrences, 1)
+		}
+	}
+
+	health.ErrorRate = float64(errors) / float64(health.Responses)
+	health.AvgDurationMs = totalDuration / int64(health.Responses)
+
+	latency := float64(health.AvgDurationMs) / float64((2 * a.slowThreshold).Milliseconds())
+	penalty := errorWeight*health.ErrorRate +
+		latencyWeight*math.Min(latency, 1) +
+		math.Min(violationPenalty*float64(health.Violations), violationWeight)
+	health.Score = int(math.Round(math.Max(100-penalty, 0)))
+
+	return health, nil
+}
@@ -0,0 +1,70 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// saveCalls records n calls to an agent, answered with status after
+// durationMs
+func saveCalls(t *testing.T, st *store.Store, traceID, agent string, n, status int, durationMs int64) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%s-%d-%d", agent, status, i)
+		for _, msg := range []*store.Message{
+			{ID: id, Direction: "request", ToAgent: agent},
+			{ID: id + "-resp", Direction: "response", RequestID: id, FromAgent: agent, StatusCode: status, DurationMs: durationMs},
+		} {
+			msg.TraceID = traceID
+			msg.Timestamp = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			if err := st.SaveMessage(msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestAgentHealth(t *testing.T) {
+	a, st, _ := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+	traceID := a.currentTrace()
+
+	saveCalls(t, st, traceID, "healthy:8080", 10, 200, 50)
+	saveCalls(t, st, traceID, "unhealthy:8080", 2, 200, 1500)
+	saveCalls(t, st, traceID, "unhealthy:8080", 8, 500, 1500)
+	if err := st.SaveInsight(&store.Insight{
+		ID:        "violation",
+		TraceID:   traceID,
+		MessageID: "unhealthy:8080-500-0",
+		Category:  "protocol_violation",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	healthy, err := a.AgentHealth("http://healthy:8080/.well-known/agent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unhealthy, err := a.AgentHealth("http://unhealthy:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if healthy.Score < 95 {
+		t.Errorf("healthy agent scored %d, want about 100", healthy.Score)
+	}
+	if unhealthy.Score >= 50 || unhealthy.Score >= healthy.Score {
+		t.Errorf("unhealthy agent scored %d against %d", unhealthy.Score, healthy.Score)
+	}
+	// 100 - 50*0.8 errors - 30*0.75 latency - 5 for the violation
+	want := store.AgentHealth{Score: 33, Responses: 10, ErrorRate: 0.8, AvgDurationMs: 1500, Violations: 1}
+	if *unhealthy != want {
+		t.Errorf("got %+v, want %+v", *unhealthy, want)
+	}
+
+	if health, err := a.AgentHealth("http://idle:8080"); err != nil || health != nil {
+		t.Errorf("agent without responses got %+v, %v; want nil", health, err)
+	}
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+const (
+	// nPlusOneWindow is how close together calls must be to count as one burst
+	nPlusOneWindow = 2 * time.Second
+	// nPlusOneCalls is how many calls with distinct params make a burst.
+	// Polling repeats the same params, so it never gets there.
+	nPlusOneCalls = 8
+)
+
+// recentCall is a request remembered for N+1 detection
+type recentCall struct {
+	at     time.Time
+	params string
+}
+
+// checkNPlusOne flags bursts of calls to one agent with the same method and
+// different params, such as a tasks/get per item in a loop, which a batch
+// call would replace; callers must hold a.mu
+func (a *Analyzer) checkNPlusOne(msg *store.Message) *store.Insight {
+	if msg.Method == "" || msg.RetryOf != "" || msg.Transport != "" {
+		return nil
+	}
+
+	var req store.A2ARequest
+	if err := json.Unmarshal([]byte(msg.Body), &req); err != nil || req.Params == nil {
+		return nil
+	}
+	params, _ := json.Marshal(req.Params) // Sorts object keys
+
+	// Forget calls that have left the window
+	key := msg.ToAgent + " " + msg.Method
+	calls := a.recentCalls[key]
+	for len(calls) > 0 && msg.Timestamp.Sub(calls[0].at) > nPlusOneWindow {
+		calls = calls[1:]
+	}
+	calls = append(calls, recentCall{at: msg.Timestamp, params: string(params)})
+
+	distinct := make(map[string]bool, len(calls))
+	for _, call := range calls {
+		distinct[call.params] = true
+	}
+	if len(distinct) < nPlusOneCalls {
+		a.recentCalls[key] = calls
+		return nil
+	}
+
+	// Start over so a long loop is reported once per burst
+	delete(a.recentCalls, key)
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "n_plus_one",
+		Severity:    35,
+		Title:       "Possible N+1 Calls",
+		Details:     formatNPlusOneDetails(msg, len(calls), calls[len(calls)-1].at.Sub(calls[0].at)),
+		Fingerprint: fingerprint("n_plus_one", msg.ToAgent, msg.Method),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+func formatNPlusOneDetails(msg *store.Message, calls int, span time.Duration) string {
+	return formatDetails(map[string]interface{}{
+		"method":     msg.Method,
+		"agent":      msg.ToAgent,
+		"call_count": calls,
+		"within_ms":  span.Milliseconds(),
+		"suggestion": "Fetch these in one batch call instead of one call per item",
+	})
+}
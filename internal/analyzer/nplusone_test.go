@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// sendTasksGet analyzes n tasks/get requests to one agent, gap apart, with
+// the task ids taskID returns for each
+func sendTasksGet(a *Analyzer, n int, gap time.Duration, taskID func(i int) string) []*store.Insight {
+	var insights []*store.Insight
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		insights = append(insights, a.AnalyzeMessage(&store.Message{
+			ID:        fmt.Sprintf("req-%d", i),
+			Direction: "request",
+			Method:    "tasks/get",
+			ToAgent:   "orders:8080",
+			Timestamp: start.Add(time.Duration(i) * gap),
+			Body:      fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tasks/get","params":{"id":%q}}`, i, taskID(i)),
+		})...)
+	}
+	return insights
+}
+
+// ofCategory filters insights to a category
+func ofCategory(insights []*store.Insight, category string) []*store.Insight {
+	var matching []*store.Insight
+	for _, insight := range insights {
+		if insight.Category == category {
+			matching = append(matching, insight)
+		}
+	}
+	return matching
+}
+
+func TestNPlusOneFlagged(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	insights := ofCategory(sendTasksGet(a, 10, 50*time.Millisecond, func(i int) string { return fmt.Sprintf("task-%d", i) }), "n_plus_one")
+	if len(insights) != 1 {
+		t.Fatalf("got %d n_plus_one insights, want 1 for the burst", len(insights))
+	}
+	if insights[0].MessageID != fmt.Sprintf("req-%d", nPlusOneCalls-1) {
+		t.Errorf("flagged %s, want the call completing the burst", insights[0].MessageID)
+	}
+}
+
+func TestNPlusOneIgnoresPolling(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	// The same task polled rapidly
+	if insights := ofCategory(sendTasksGet(a, 20, 50*time.Millisecond, func(int) string { return "task-1" }), "n_plus_one"); len(insights) != 0 {
+		t.Errorf("polling flagged as N+1")
+	}
+}
+
+func TestNPlusOneIgnoresSpreadOutCalls(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	if insights := ofCategory(sendTasksGet(a, 10, time.Second, func(i int) string { return fmt.Sprintf("task-%d", i) }), "n_plus_one"); len(insights) != 0 {
+		t.Errorf("calls a second apart flagged as N+1")
+	}
+}
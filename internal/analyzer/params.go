@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"fmt"
+)
+
+// requiredParams lists the params each core A2A method must carry. Methods
+// not listed here aren't validated.
+var requiredParams = map[string][]string{
+	"tasks/send":          {"id", "message"},
+	"tasks/sendSubscribe": {"id", "message"},
+	"tasks/get":           {"id"},
+	"tasks/cancel":        {"id"},
+	"tasks/resubscribe":   {"id"},
+	"message/send":        {"message"},
+	"message/stream":      {"message"},
+}
+
+// requiredMessageFields lists the fields an A2A message param must carry
+var requiredMessageFields = []string{"role", "parts"}
+
+// validateParams checks a request's params against the shape its method
+// expects and returns a description of each problem found
+func validateParams(method string, params interface{}) []string {
+	required, ok := requiredParams[method]
+	if !ok {
+		return nil
+	}
+
+	if params == nil {
+		return []string{fmt.Sprintf("Missing 'params' for %s", method)}
+	}
+	fields, ok := params.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("'params' for %s must be an object", method)}
+	}
+
+	var problems []string
+	for _, name := range required {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			problems = append(problems, fmt.Sprintf("Missing required param '%s' for %s", name, method))
+			continue
+		}
+		if name == "message" {
+			problems = append(problems, validateMessageParam(value)...)
+		}
+	}
+	return problems
+}
+
+// validateMessageParam checks the shape of an A2A message param
+func validateMessageParam(value interface{}) []string {
+	message, ok := value.(map[string]interface{})
+	if !ok {
+		return []string{"Param 'message' must be an object"}
+	}
+
+	var problems []string
+	for _, name := range requiredMessageFields {
+		if _, ok := message[name]; !ok {
+			problems = append(problems, fmt.Sprintf("Missing 'message.%s'", name))
+		}
+	}
+	if parts, ok := message["parts"]; ok {
+		if _, isList := parts.([]interface{}); !isList {
+			problems = append(problems, "'message.parts' must be an array")
+		}
+	}
+	return problems
+}
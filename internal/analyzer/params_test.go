@@ -0,0 +1,66 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// decodeParams decodes a JSON params value
+func decodeParams(t *testing.T, raw string) interface{} {
+	t.Helper()
+	var params interface{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		params string
+		want   []string
+	}{
+		{"valid tasks/send", "tasks/send", `{"id":"t1","message":{"role":"user","parts":[{"kind":"text","text":"hi"}]}}`, nil},
+		{"tasks/send missing message", "tasks/send", `{"id":"t1"}`, []string{"Missing required param 'message' for tasks/send"}},
+		{"params absent", "tasks/get", `null`, []string{"Missing 'params' for tasks/get"}},
+		{"params not an object", "tasks/cancel", `["t1"]`, []string{"'params' for tasks/cancel must be an object"}},
+		{"bad message", "message/send", `{"message":{"parts":"hi"}}`, []string{"Missing 'message.role'", "'message.parts' must be an array"}},
+		{"unknown method", "agent/custom", `null`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateParams(tt.method, decodeParams(t, tt.params)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsViolationInsight(t *testing.T) {
+	a, _, _ := newTestAnalyzer(t, Config{})
+
+	insights := a.AnalyzeMessage(&store.Message{
+		ID:        "req-1",
+		Direction: "request",
+		Method:    "tasks/send",
+		Body:      `{"jsonrpc":"2.0","id":1,"method":"tasks/send","params":{"id":"t1"}}`,
+	})
+	if len(insights) != 1 || insights[0].Category != "protocol_violation" {
+		t.Fatalf("got %d insights, want one protocol_violation", len(insights))
+	}
+
+	insights = a.AnalyzeMessage(&store.Message{
+		ID:        "req-2",
+		Direction: "request",
+		Method:    "tasks/send",
+		Body:      `{"jsonrpc":"2.0","id":2,"method":"tasks/send","params":{"id":"t2","message":{"role":"user","parts":[]}}}`,
+	})
+	if len(insights) != 0 {
+		t.Errorf("flagged valid params: %s", insights[0].Details)
+	}
+}
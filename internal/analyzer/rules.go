@@ -0,0 +1,254 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// Rule is a user-defined insight evaluated against every message.
+//
+// Conditions are written as "<field> <op> <value>" and may be joined with
+// "and", for example:
+//
+//	status_code >= 500 and method == "tasks/send"
+//	body contains "quota exceeded"
+//
+// Numeric fields (status_code, duration_ms, size) support ==, !=, >, >=, <, <=.
+// Text fields (method, url, body, error, direction, content_type, from_agent,
+// to_agent) support ==, != and contains.
+type Rule struct {
+	Name     string `yaml:"name"`
+	When     string `yaml:"when"`
+	Type     string `yaml:"type"`     // "error", "warning" or "info" (default: "warning")
+	Category string `yaml:"category"` // Default: "custom_rule"
+	Title    string `yaml:"title"`    // Default: the rule name
+	Severity int    `yaml:"severity"` // 0-100 (default: 70 for errors, 40 for warnings, 10 for info)
+
+	conditions []condition
+}
+
+// rulesFile is the top-level layout of a rules file
+type rulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+type condition struct {
+	field string
+	op    string
+	text  string
+	num   int64
+}
+
+var numericFields = map[string]func(*store.Message) int64{
+	"status_code": func(m *store.Message) int64 { return int64(m.StatusCode) },
+	"duration_ms": func(m *store.Message) int64 { return m.DurationMs },
+	"size":        func(m *store.Message) int64 { return m.Size },
+}
+
+var textFields = map[string]func(*store.Message) string{
+	"method":       func(m *store.Message) string { return m.Method },
+	"url":          func(m *store.Message) string { return m.URL },
+	"body":         func(m *store.Message) string { return m.Body },
+	"error":        func(m *store.Message) string { return m.Error },
+	"direction":    func(m *store.Message) string { return m.Direction },
+	"content_type": func(m *store.Message) string { return m.ContentType },
+	"from_agent":   func(m *store.Message) string { return m.FromAgent },
+	"to_agent":     func(m *store.Message) string { return m.ToAgent },
+}
+
+// LoadRules reads and compiles rules from a YAML file
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %w", err)
+	}
+
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	for i, rule := range file.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if err := rule.Compile(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+
+	return file.Rules, nil
+}
+
+// Compile parses the rule's condition and fills in defaults
+func (r *Rule) Compile() error {
+	switch r.Type {
+	case "":
+		r.Type = "warning"
+	case "error", "warning", "info":
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	if r.Severity < 0 || r.Severity > 100 {
+		return fmt.Errorf("severity must be between 0 and 100, got %d", r.Severity)
+	}
+	if r.Severity == 0 {
+		r.Severity = map[string]int{"error": 70, "warning": 40, "info": 10}[r.Type]
+	}
+	if r.Category == "" {
+		r.Category = "custom_rule"
+	}
+	if r.Title == "" {
+		r.Title = r.Name
+	}
+
+	tokens, err := tokenize(r.When)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty condition")
+	}
+
+	r.conditions = nil
+	for len(tokens) > 0 {
+		if len(tokens) < 3 {
+			return fmt.Errorf("incomplete condition %q", strings.Join(tokens, " "))
+		}
+		cond, err := parseCondition(tokens[0], tokens[1], tokens[2])
+		if err != nil {
+			return err
+		}
+		r.conditions = append(r.conditions, cond)
+
+		tokens = tokens[3:]
+		if len(tokens) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return fmt.Errorf("expected 'and', got %q", tokens[0])
+			}
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				return fmt.Errorf("dangling 'and'")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether all of the rule's conditions hold for msg
+func (r *Rule) Matches(msg *store.Message) bool {
+	for _, cond := range r.conditions {
+		if !cond.matches(msg) {
+			return false
+		}
+	}
+	return len(r.conditions) > 0
+}
+
+func parseCondition(field, op, value string) (condition, error) {
+	cond := condition{field: field, op: op}
+
+	if _, ok := numericFields[field]; ok {
+		switch op {
+		case "==", "!=", ">", ">=", "<", "<=":
+		default:
+			return cond, fmt.Errorf("operator %q not supported for %s", op, field)
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return cond, fmt.Errorf("%s needs a number, got %q", field, value)
+		}
+		cond.num = n
+		return cond, nil
+	}
+
+	if _, ok := textFields[field]; ok {
+		switch op {
+		case "==", "!=", "contains":
+		default:
+			return cond, fmt.Errorf("operator %q not supported for %s", op, field)
+		}
+		cond.text = value
+		return cond, nil
+	}
+
+	return cond, fmt.Errorf("unknown field %q", field)
+}
+
+func (c condition) matches(msg *store.Message) bool {
+	if get, ok := numericFields[c.field]; ok {
+		v := get(msg)
+		switch c.op {
+		case "==":
+			return v == c.num
+		case "!=":
+			return v != c.num
+		case ">":
+			return v > c.num
+		case ">=":
+			return v >= c.num
+		case "<":
+			return v < c.num
+		case "<=":
+			return v <= c.num
+		}
+		return false
+	}
+
+	v := textFields[c.field](msg)
+	switch c.op {
+	case "==":
+		return v == c.text
+	case "!=":
+		return v != c.text
+	case "contains":
+		return strings.Contains(v, c.text)
+	}
+	return false
+}
+
+// tokenize splits a condition on whitespace, keeping quoted strings intact
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+	var quote rune
+
+	for _, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", expr)
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
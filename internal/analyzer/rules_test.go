@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// loadTestRules writes a rules file and loads it
+func loadTestRules(t *testing.T, yaml string) []*Rule {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRuleMatchesStatusCode(t *testing.T) {
+	rules := loadTestRules(t, `
+rules:
+  - name: Server error
+    when: status_code >= 500
+    type: error
+`)
+
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{200, false},
+		{499, false},
+		{500, true},
+		{503, true},
+	}
+	for _, tt := range tests {
+		msg := &store.Message{Direction: "response", StatusCode: tt.status}
+		if got := rules[0].Matches(msg); got != tt.want {
+			t.Errorf("status %d: Matches = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatchesBodySubstring(t *testing.T) {
+	rules := loadTestRules(t, `
+rules:
+  - name: Quota exceeded
+    when: body contains "quota exceeded" and method == tasks/send
+    category: quota
+`)
+
+	if !rules[0].Matches(&store.Message{Method: "tasks/send", Body: `{"error":"quota exceeded for today"}`}) {
+		t.Error("didn't match a body containing the phrase")
+	}
+	if rules[0].Matches(&store.Message{Method: "tasks/send", Body: `{"error":"quota"}`}) {
+		t.Error("matched a body without the phrase")
+	}
+	if rules[0].Matches(&store.Message{Method: "tasks/get", Body: "quota exceeded"}) {
+		t.Error("matched another method")
+	}
+}
+
+func TestRuleDefaults(t *testing.T) {
+	rules := loadTestRules(t, `
+rules:
+  - name: Big body
+    when: size > 1000
+`)
+
+	rule := rules[0]
+	if rule.Type != "warning" || rule.Category != "custom_rule" || rule.Title != "Big body" || rule.Severity != 40 {
+		t.Errorf("got type %q, category %q, title %q, severity %d", rule.Type, rule.Category, rule.Title, rule.Severity)
+	}
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	for _, when := range []string{
+		"",
+		"status_code >=",
+		"status_code contains 5",
+		"status_code > abc",
+		"latency > 5",
+		`body contains "unterminated`,
+		"method == x and",
+		"method == x or status_code > 1",
+	} {
+		rule := &Rule{Name: "bad", When: when}
+		if err := rule.Compile(); err == nil {
+			t.Errorf("%q compiled", when)
+		}
+	}
+}
+
+func TestAnalyzerEmitsRuleInsights(t *testing.T) {
+	rules := loadTestRules(t, `
+rules:
+  - name: Server error
+    when: status_code >= 500
+    type: error
+`)
+	a, _, _ := newTestAnalyzer(t, Config{Rules: rules})
+
+	insights := a.AnalyzeMessage(&store.Message{ID: "resp-1", Direction: "response", StatusCode: 503})
+	for _, insight := range insights {
+		if insight.Category == "custom_rule" && insight.Title == "Server error" && insight.Type == "error" {
+			return
+		}
+	}
+	t.Errorf("no custom_rule insight among %d", len(insights))
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// secretPattern matches one kind of credential
+type secretPattern struct {
+	kind       string
+	re         *regexp.Regexp
+	group      int     // Submatch holding the secret itself
+	minEntropy float64 // Bits per character, to skip placeholders like "sk-xxxxxxxx..."
+}
+
+var secretPatterns = []secretPattern{
+	{"JWT", regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), 0, 3.5},
+	{"API key (sk-)", regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}`), 0, 3.5},
+	{"AWS access key", regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), 0, 3.0},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}`), 0, 3.5},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`), 0, 3.5},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), 0, 3.0},
+	{"Private key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |OPENSSH )?PRIVATE KEY-----`), 0, 0},
+	{"Password field", regexp.MustCompile(`"(?i:password|passwd|secret|api_?key|access_?token|client_secret)"\s*:\s*"([^"]{12,})"`), 1, 3.0},
+}
+
+// scanForSecrets returns the kinds of likely credentials found in body, in
+// pattern order and without duplicates. Matches with low entropy are ignored
+// to keep placeholders and example values from being reported.
+func scanForSecrets(body string) []string {
+	if body == "" {
+		return nil
+	}
+
+	var kinds []string
+	for _, p := range secretPatterns {
+		for _, match := range p.re.FindAllStringSubmatch(body, -1) {
+			if shannonEntropy(match[p.group]) >= p.minEntropy {
+				kinds = append(kinds, p.kind)
+				break
+			}
+		}
+	}
+	return kinds
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// formatSecretKinds joins secret kinds for an insight title
+func formatSecretKinds(kinds []string) string {
+	return strings.Join(kinds, ", ")
+}
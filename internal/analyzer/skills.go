@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// AnalyzeAgent emits an insight when a rediscovered agent's card advertises
+// different skills than it did before, usually a sign it was redeployed
+func (a *Analyzer) AnalyzeAgent(agent *store.Agent) {
+	if agent.PreviousSkills == "" {
+		return
+	}
+	added, removed, err := diffSkills(agent.PreviousSkills, agent.Skills)
+	if err != nil || (len(added) == 0 && len(removed) == 0) {
+		return
+	}
+
+	a.emit([]*store.Insight{{
+		ID:          a.newID(),
+		TraceID:     a.currentTrace(),
+		Type:        "info",
+		Category:    "skills_changed",
+		Severity:    15,
+		Title:       "Agent Skills Changed",
+		Details:     formatSkillsChangedDetails(agent, added, removed),
+		Fingerprint: fingerprint("skills_changed", agent.URL, strings.Join(added, ","), strings.Join(removed, ",")),
+		Timestamp:   a.clock.Now(),
+	}})
+}
+
+// diffSkills compares two JSON skill lists by ID, returning the sorted IDs
+// only in after and only in before, or an error if either doesn't parse
+func diffSkills(before, after string) (added, removed []string, err error) {
+	old, err := skillIDs(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := skillIDs(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	for id := range current {
+		if !old[id] {
+			added = append(added, id)
+		}
+	}
+	for id := range old {
+		if !current[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed, nil
+}
+
+// skillIDs returns the IDs in a JSON skill list
+func skillIDs(skills string) (map[string]bool, error) {
+	var parsed []store.Skill
+	if err := json.Unmarshal([]byte(skills), &parsed); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(parsed))
+	for _, skill := range parsed {
+		if skill.ID != "" {
+			ids[skill.ID] = true
+		}
+	}
+	return ids, nil
+}
+
+func formatSkillsChangedDetails(agent *store.Agent, added, removed []string) string {
+	details := map[string]interface{}{
+		"agent":      agent.Name,
+		"url":        agent.URL,
+		"suggestion": "The agent was likely redeployed; check callers still use skills it advertises",
+	}
+	if len(added) > 0 {
+		details["added"] = added
+	}
+	if len(removed) > 0 {
+		details["removed"] = removed
+	}
+	return formatDetails(details)
+}
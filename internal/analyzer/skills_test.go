@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestSkillsChangedOnRediscovery(t *testing.T) {
+	a, st, _ := newTestAnalyzer(t, Config{})
+
+	discover := func(skills string) {
+		t.Helper()
+		agent := &store.Agent{URL: "http://planner:8080", Name: "Planner", Skills: skills}
+		if err := st.SaveAgent(agent); err != nil {
+			t.Fatal(err)
+		}
+		a.AnalyzeAgent(agent)
+	}
+	discover(`[{"id":"plan"},{"id":"search"}]`)
+	discover(`[{"id":"search"},{"id":"plan"}]`) // Reordered only
+	if insights := insightsOf(t, st, a.currentTrace(), "skills_changed"); len(insights) != 0 {
+		t.Fatalf("got %d skills_changed insights before skills changed", len(insights))
+	}
+
+	discover(`[{"id":"plan"},{"id":"summarize"},{"id":"translate"}]`)
+	insights := insightsOf(t, st, a.currentTrace(), "skills_changed")
+	if len(insights) != 1 || insights[0].Type != "info" {
+		t.Fatalf("got %d skills_changed insights, want one info", len(insights))
+	}
+	var details struct {
+		Added   []string `json:"added"`
+		Removed []string `json:"removed"`
+	}
+	if err := json.Unmarshal([]byte(insights[0].Details), &details); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(details.Added) != "[summarize translate]" || fmt.Sprint(details.Removed) != "[search]" {
+		t.Errorf("added %v, removed %v; want summarize and translate added, search removed", details.Added, details.Removed)
+	}
+}
@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// slowHandshakeShare is the share of a slow response's duration a TLS
+// handshake must take for the connection, not the agent, to be blamed
+const slowHandshakeShare = 0.5
+
+// maxPlausibleDuration is the longest response time taken at face value;
+// the proxy's own upstream timeout is far shorter
+const maxPlausibleDuration = 24 * time.Hour
+
+// checkBadTiming checks responses for durations that can't be right: below
+// zero, beyond maxPlausibleDuration, or ending before the request they
+// answer was sent. They point at a response linked to the wrong request.
+// Callers must hold a.mu and call it before the request is forgotten.
+func (a *Analyzer) checkBadTiming(msg *store.Message) *store.Insight {
+	var problem string
+	switch {
+	case msg.DurationMs < 0:
+		problem = "negative duration"
+	case time.Duration(msg.DurationMs)*time.Millisecond > maxPlausibleDuration:
+		problem = "implausibly long duration"
+	default:
+		if req, ok := a.pending[msg.RequestID]; ok && msg.Timestamp.Before(req.sent) {
+			problem = "response recorded before its request"
+		}
+	}
+	if problem == "" {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "bad_timing",
+		Severity:    40,
+		Title:       "Implausible Response Timing",
+		Details:     formatBadTimingDetails(msg, problem),
+		Fingerprint: fingerprint("bad_timing", problem, endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+func formatBadTimingDetails(msg *store.Message, problem string) string {
+	return formatDetails(map[string]interface{}{
+		"problem":     problem,
+		"duration_ms": msg.DurationMs,
+		"request_id":  msg.RequestID,
+		"url":         msg.URL,
+		"suggestion":  "The response was likely matched to the wrong request; check for reused JSON-RPC ids or a misconfigured --correlation-header",
+	})
+}
+
+// checkSlowHandshake checks slow responses for time mostly spent on the TLS
+// handshake of a new connection, which points at connections not being
+// reused rather than a slow agent
+func (a *Analyzer) checkSlowHandshake(msg *store.Message) *store.Insight {
+	if msg.Timing == "" || msg.DurationMs <= a.slowThreshold.Milliseconds() {
+		return nil
+	}
+	var timing store.ConnectionTiming
+	if err := json.Unmarshal([]byte(msg.Timing), &timing); err != nil {
+		return nil
+	}
+	if float64(timing.TLSHandshakeMs) < slowHandshakeShare*float64(msg.DurationMs) {
+		return nil
+	}
+
+	return &store.Insight{
+		ID:          a.newID(),
+		TraceID:     a.traceID,
+		MessageID:   msg.ID,
+		Type:        "warning",
+		Category:    "slow_handshake",
+		Severity:    a.slowSeverity(msg.DurationMs),
+		Title:       "Slow TLS Handshake",
+		Details:     formatSlowHandshakeDetails(msg, timing),
+		Fingerprint: fingerprint("slow_handshake", endpointOf(msg.URL)),
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+func formatSlowHandshakeDetails(msg *store.Message, timing store.ConnectionTiming) string {
+	return formatDetails(map[string]interface{}{
+		"duration_ms":      msg.DurationMs,
+		"tls_handshake_ms": timing.TLSHandshakeMs,
+		"url":              msg.URL,
+		"suggestion":       "Most of the time went to setting up a new TLS connection, not the agent; reuse connections (keep-alive, one shared HTTP client) instead of connecting per call",
+	})
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestBadTiming(t *testing.T) {
+	tests := []struct {
+		name       string
+		durationMs int64
+		offset     time.Duration // Of the response from its request
+		want       string
+	}{
+		{"plausible", 120, 120 * time.Millisecond, ""},
+		{"negative duration", -250, time.Second, "negative duration"},
+		{"implausibly long", (25 * time.Hour).Milliseconds(), time.Second, "implausibly long duration"},
+		{"before its request", 100, -time.Second, "response recorded before its request"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, clk := newTestAnalyzer(t, Config{SlowThreshold: 48 * time.Hour})
+			requestID := fmt.Sprintf("req-%d", i)
+			a.AnalyzeMessage(&store.Message{
+				ID:        requestID,
+				Direction: "request",
+				Method:    "tasks/get",
+				Body:      `{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{"id":"task-1"}}`,
+				Timestamp: clk.Now(),
+			})
+
+			insights := ofCategory(a.AnalyzeMessage(&store.Message{
+				ID:         "resp-" + requestID,
+				RequestID:  requestID,
+				Direction:  "response",
+				StatusCode: 200,
+				Body:       `{"jsonrpc":"2.0","id":1,"result":{}}`,
+				DurationMs: tt.durationMs,
+				Timestamp:  clk.Now().Add(tt.offset),
+			}), "bad_timing")
+
+			if tt.want == "" {
+				if len(insights) != 0 {
+					t.Errorf("flagged: %s", insights[0].Details)
+				}
+				return
+			}
+			if len(insights) != 1 || !strings.Contains(insights[0].Details, tt.want) {
+				t.Fatalf("got %d bad_timing insights, want one for %s", len(insights), tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowHandshake(t *testing.T) {
+	tests := []struct {
+		name       string
+		durationMs int64
+		timing     string
+		want       string
+	}{
+		{"handshake dominates", 2000, `{"connect_ms":20,"tls_handshake_ms":1500}`, "slow_handshake"},
+		{"agent dominates", 2000, `{"connect_ms":20,"tls_handshake_ms":300}`, "slow_response"},
+		{"reused connection", 2000, `{"reused":true}`, "slow_response"},
+		{"no timing", 2000, "", "slow_response"},
+		{"fast", 400, `{"tls_handshake_ms":390}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, _ := newTestAnalyzer(t, Config{SlowThreshold: time.Second})
+			insights := a.AnalyzeMessage(&store.Message{
+				ID:         "resp-1",
+				Direction:  "response",
+				StatusCode: 200,
+				Body:       `{"jsonrpc":"2.0","id":1,"result":{}}`,
+				URL:        "https://agent.example/rpc",
+				DurationMs: tt.durationMs,
+				Timing:     tt.timing,
+			})
+
+			var got []string
+			for _, insight := range insights {
+				got = append(got, insight.Category)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("got insights %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// startCommand starts an external command without waiting for it
+var startCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
+
+// OpenBrowser opens url in the default browser. Failures are only reported
+// as a warning since the UI is still reachable by hand.
+func OpenBrowser(url string) {
+	name, args := browserCommand(runtime.GOOS, url)
+	if _, err := exec.LookPath(name); err != nil {
+		PrintWarning("Could not open browser: no " + name + " found, open " + url + " manually")
+		return
+	}
+	if err := startCommand(name, args...); err != nil {
+		PrintWarning("Could not open browser: " + err.Error())
+	}
+}
+
+// browserCommand returns the platform's command for opening a URL
+func browserCommand(goos, url string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{url}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", url}
+	default:
+		return "xdg-open", []string{url}
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestBrowserCommand(t *testing.T) {
+	const url = "http://localhost:8080/ui"
+	tests := []struct {
+		goos     string
+		wantName string
+		wantArgs []string
+	}{
+		{"darwin", "open", []string{url}},
+		{"windows", "rundll32", []string{"url.dll,FileProtocolHandler", url}},
+		{"linux", "xdg-open", []string{url}},
+		{"freebsd", "xdg-open", []string{url}},
+	}
+	for _, tt := range tests {
+		name, args := browserCommand(tt.goos, url)
+		if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: got %s %v, want %s %v", tt.goos, name, args, tt.wantName, tt.wantArgs)
+		}
+	}
+}
+
+func TestOpenBrowserRunsOpener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs an executable file without an extension on PATH")
+	}
+
+	// A stand-in for the platform's opener, so LookPath finds it
+	name, _ := browserCommand(runtime.GOOS, "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	var gotName string
+	var gotArgs []string
+	original := startCommand
+	startCommand = func(name string, args ...string) error {
+		gotName, gotArgs = name, args
+		return nil
+	}
+	defer func() { startCommand = original }()
+
+	OpenBrowser("http://localhost:8080/ui")
+
+	wantName, wantArgs := browserCommand(runtime.GOOS, "http://localhost:8080/ui")
+	if gotName != wantName || !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("ran %s %v, want %s %v", gotName, gotArgs, wantName, wantArgs)
+	}
+}
+
+func TestOpenBrowserWithoutOpener(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	original := startCommand
+	startCommand = func(name string, args ...string) error {
+		t.Errorf("ran %s without it on PATH", name)
+		return nil
+	}
+	defer func() { startCommand = original }()
+
+	OpenBrowser("http://localhost:8080/ui")
+}
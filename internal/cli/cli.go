@@ -1,9 +1,13 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -17,17 +21,81 @@ var (
 
 // Config holds CLI configuration
 type Config struct {
-	Port     int
-	UIPort   int
-	DBPath   string
-	Verbose  bool
-	NoUI     bool
-	Command  []string
+	Subcommand string // Set when a subcommand such as "mock" was run instead of tracing
+	Port       int
+	ExtraPorts []int // Further proxy ports from a comma-separated --port; Port is the first
+	UIPort     int
+	DBPath     string
+	Save       bool   // Save to a new timestamped database in DataDir instead of memory
+	DataDir    string // Directory --save creates databases in
+	Resume     string // Interrupted trace in DBPath to append to instead of starting a new one
+	Verbose    bool
+	NoUI       bool
+	Open       bool // Open the UI in the default browser once started
+	Command    []string
+	Execs      [][]string // Additional commands from repeated --exec flags
+	RulesPath  string     // YAML file with custom insight rules
+	LabelsPath string     // JSON file naming methods for display
+	SummaryOut string     // JSON file the end-of-trace summary is written to
+	AssertPath string     // YAML file with assertions checked against the finished trace
+	Quiet      bool       // Don't relay child process output to the terminal
+	ChildLog   string     // File child process output is written to
+	NoInject   []string   // Proxy environment variables not overridden for traced processes
+
+	UnixSocket  string // Also accept proxy requests on this unix socket
+	Transparent bool   // Forward connections redirected by iptables to their original destination
+
+	WebhookPort int // Also listen on this port for agents' push notifications (0: off)
+
+	MaxConcurrency  int  // Limit on simultaneous proxied requests (0: unlimited)
+	RejectOverLimit bool // Return 503 instead of queuing when MaxConcurrency is reached
+
+	CACert     string // PEM bundle trusted for HTTPS upstreams
+	ClientCert string // PEM client certificate presented to upstreams
+	ClientKey  string // PEM key for ClientCert
+	Insecure   bool   // Skip verifying upstream certificates
+
+	CorrelationHeader string   // Header linking responses to requests, preferred over JSON-RPC ids
+	CaptureMethods    []string // HTTP methods recorded even without a body; others only if recognizably A2A (empty: any request with a body)
+
+	JSONLPath    string // Also append every message to this JSONL file
+	JSONLMaxSize int64  // Rotate the JSONL file past this many MB (0: never)
+
+	NoFollowRedirects bool     // Return upstream redirects to the client instead of following them
+	AutoDiscover      bool     // Fetch the agent card of each new host seen in traffic
+	FaultRules        []string // Faults to inject, see proxy.ParseFaultRule
+	UpstreamAuth      []string // Basic credentials for upstream hosts, see proxy.ParseUpstreamAuth
+
+	Debug    bool // Serve the proxy's own resource usage at /api/debug/runtime
+	ReadOnly bool // Refuse API requests that change state, for sharing a trace
+
+	SLO time.Duration // Target response latency the summary counts breaches of (0: off)
+
+	WSBatchMs int // Coalesce WebSocket updates into one frame per this many milliseconds (0: off)
+
+	BlobDir       string // Directory for bodies over BlobThreshold, kept out of the database
+	BlobThreshold int64  // Size in KB above which bodies go to BlobDir
+
+	MaxResponseSize int64 // Size in MB above which responses are streamed and recorded truncated (0: unlimited)
+
+	AgentAliases map[string]string // Agent host -> display name, from --agent-alias
+
+	MockTracePath string // Exported trace replayed by "mock"
+
+	ReplayMessageID string // Recorded request resent by "replay"
+	ReplayTimes     int    // How many times "replay" sends it
+
+	ImportPath string // Binary trace export loaded by "import"
 }
 
-// ParseArgs parses command line arguments and returns a Config
+// ParseArgs parses command line arguments and returns a Config.
+// It returns a nil Config when only help or version output was requested.
 func ParseArgs() (*Config, error) {
 	cfg := &Config{}
+	var execs, aliases []string
+	var ports, captureMethods string
+	var keepNoProxy bool
+	ran := false
 
 	rootCmd := &cobra.Command{
 		Use:   "a2a-trace [flags] -- <command> [args...]",
@@ -41,7 +109,12 @@ Usage:
   a2a-trace --port 8081 -- ./my-go-agent
 
 The command after '--' is run with HTTP_PROXY set to route traffic
-through A2A Trace for inspection.`,
+through A2A Trace for inspection.
+
+Every flag can also be set with an environment variable named after it,
+prefixed with A2A_TRACE_: A2A_TRACE_PORT for --port, A2A_TRACE_DB for
+--db, A2A_TRACE_NO_UI=true for --no-ui, and so on. Flags given on the
+command line take precedence. Repeatable flags take one value per line.`,
 		Example: `  # Trace a Node.js agent
   a2a-trace -- node my-agent.js
 
@@ -49,9 +122,81 @@ through A2A Trace for inspection.`,
   a2a-trace --port 9000 -- python agent.py
 
   # Trace without opening UI
-  a2a-trace --no-ui -- ./my-agent`,
+  a2a-trace --no-ui -- ./my-agent
+
+  # Route agents through separate proxy ports in one trace
+  a2a-trace --port 8080,8081 -- ./run-agents.sh
+
+  # Trace a host and two workers in one session
+  a2a-trace --exec "python worker.py --port 9001" --exec "python worker.py --port 9002" -- python host.py`,
 		Version: formatVersion(),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			ran = true
+			return applyEnv(cmd.Flags())
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if cfg.Port, cfg.ExtraPorts, err = parsePorts(ports); err != nil {
+				return fmt.Errorf("invalid --port %q: %w", ports, err)
+			}
+			if captureMethods != "" {
+				if cfg.CaptureMethods, err = parseCaptureMethods(captureMethods); err != nil {
+					return fmt.Errorf("invalid --capture-methods %q: %w", captureMethods, err)
+				}
+			}
+			if keepNoProxy {
+				cfg.NoInject = append(cfg.NoInject, "NO_PROXY")
+			}
+			if cfg.Save && cfg.DBPath != "" {
+				return fmt.Errorf("--save and --db can't be used together")
+			}
+			if cfg.Resume != "" && cfg.DBPath == "" {
+				return fmt.Errorf("--resume needs the --db the trace was recorded in")
+			}
+			if cfg.Transparent && runtime.GOOS != "linux" {
+				return fmt.Errorf("--transparent is only supported on Linux")
+			}
+			if cfg.SLO < 0 {
+				return fmt.Errorf("--slo must not be negative")
+			}
+			if cfg.WSBatchMs < 0 {
+				return fmt.Errorf("--ws-batch-ms must not be negative")
+			}
+			if cfg.MaxConcurrency < 0 {
+				return fmt.Errorf("--max-concurrency must not be negative")
+			}
+			if cfg.JSONLMaxSize < 0 {
+				return fmt.Errorf("--jsonl-max-size must not be negative")
+			}
+			if cfg.MaxResponseSize < 0 {
+				return fmt.Errorf("--max-response-size must not be negative")
+			}
+			if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+				return fmt.Errorf("--client-cert and --client-key must be used together")
+			}
+
+			for _, alias := range aliases {
+				host, name, ok := strings.Cut(alias, "=")
+				host, name = strings.TrimSpace(host), strings.TrimSpace(name)
+				if !ok || host == "" || name == "" {
+					return fmt.Errorf("invalid --agent-alias %q: expected host=Name", alias)
+				}
+				if cfg.AgentAliases == nil {
+					cfg.AgentAliases = make(map[string]string)
+				}
+				cfg.AgentAliases[host] = name
+			}
+
+			for _, e := range execs {
+				command, err := splitCommandLine(e)
+				if err != nil {
+					return fmt.Errorf("invalid --exec %q: %w", e, err)
+				}
+				if len(command) > 0 {
+					cfg.Execs = append(cfg.Execs, command)
+				}
+			}
+
 			// Find the command after --
 			dashIndex := -1
 			for i, arg := range os.Args {
@@ -62,6 +207,9 @@ through A2A Trace for inspection.`,
 			}
 
 			if dashIndex == -1 || dashIndex == len(os.Args)-1 {
+				if len(cfg.Execs) > 0 {
+					return nil
+				}
 				return fmt.Errorf("no command specified after '--'\n\nUsage: a2a-trace [flags] -- <command> [args...]")
 			}
 
@@ -72,11 +220,55 @@ through A2A Trace for inspection.`,
 	}
 
 	// Flags
-	rootCmd.Flags().IntVarP(&cfg.Port, "port", "p", 8080, "Proxy port")
+	rootCmd.Flags().StringVarP(&ports, "port", "p", "8080", "Proxy port, or a comma-separated list to listen on several")
 	rootCmd.Flags().IntVar(&cfg.UIPort, "ui-port", 0, "UI port (default: same as proxy port)")
+	rootCmd.Flags().StringVar(&cfg.UnixSocket, "unix-socket", "", "Also listen for proxy requests on a unix socket")
+	rootCmd.Flags().BoolVar(&cfg.Transparent, "transparent", false, "Accept plain HTTP connections redirected to the proxy port by iptables and forward them to their original destination (Linux only)")
+	rootCmd.Flags().IntVar(&cfg.WebhookPort, "webhook-capture", 0, "Also listen on this port for agents' push notifications")
 	rootCmd.Flags().StringVar(&cfg.DBPath, "db", "", "SQLite database path (default: in-memory)")
+	rootCmd.Flags().BoolVar(&cfg.Save, "save", false, "Save the trace to a new timestamped database in --data-dir")
+	rootCmd.Flags().StringVar(&cfg.DataDir, "data-dir", ".", "Directory --save creates databases in")
+	rootCmd.Flags().StringVar(&cfg.Resume, "resume", "", "Continue an interrupted trace in --db instead of starting a new one")
 	rootCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output")
 	rootCmd.Flags().BoolVar(&cfg.NoUI, "no-ui", false, "Don't serve the web UI")
+	rootCmd.Flags().BoolVar(&cfg.Open, "open", false, "Open the UI in the default browser")
+	rootCmd.Flags().StringVar(&cfg.RulesPath, "rules", "", "YAML file with custom insight rules")
+	rootCmd.Flags().StringVar(&cfg.LabelsPath, "method-labels", "", "JSON file mapping A2A methods to display names, over the built-in ones")
+	rootCmd.Flags().BoolVarP(&cfg.Quiet, "quiet", "q", false, "Don't relay the command's output to the terminal")
+	rootCmd.Flags().StringVar(&cfg.ChildLog, "child-log", "", "Write the command's output to a file")
+	rootCmd.Flags().StringArrayVar(&cfg.NoInject, "no-inject", nil, "Proxy environment variable to leave as inherited instead of overriding, e.g. HTTPS_PROXY (repeatable)")
+	rootCmd.Flags().BoolVar(&keepNoProxy, "keep-no-proxy", false, "Keep the inherited NO_PROXY so listed hosts bypass the proxy; same as --no-inject NO_PROXY")
+	rootCmd.Flags().StringVar(&cfg.AssertPath, "assert", "", "YAML file with assertions on the finished trace; exit non-zero if any fail")
+	rootCmd.Flags().StringVar(&cfg.SummaryOut, "summary-out", "", "Write the end-of-trace summary and insights to a JSON file")
+	rootCmd.Flags().IntVar(&cfg.MaxConcurrency, "max-concurrency", 0, "Maximum simultaneous proxied requests (default: unlimited)")
+	rootCmd.Flags().BoolVar(&cfg.RejectOverLimit, "reject-over-limit", false, "Return 503 instead of queuing requests over --max-concurrency")
+	rootCmd.Flags().StringVar(&cfg.CACert, "ca-cert", "", "PEM CA bundle to trust for HTTPS upstreams")
+	rootCmd.Flags().StringVar(&cfg.ClientCert, "client-cert", "", "PEM client certificate for upstreams requiring mTLS")
+	rootCmd.Flags().StringVar(&cfg.ClientKey, "client-key", "", "PEM private key for --client-cert")
+	rootCmd.Flags().BoolVar(&cfg.Insecure, "insecure", false, "Don't verify upstream TLS certificates")
+	rootCmd.Flags().StringArrayVar(&cfg.UpstreamAuth, "upstream-auth", nil, "Basic auth credentials added to requests for a host, e.g. \"localhost:9001=user:pass\" (repeatable)")
+	rootCmd.Flags().StringVar(&captureMethods, "capture-methods", "", "Comma-separated HTTP methods to record, e.g. POST,PUT,GET, even without a body; requests with others are recorded only if recognizably A2A (default: any request with a body)")
+	rootCmd.Flags().StringVar(&cfg.CorrelationHeader, "correlation-header", "", "Header echoed on responses to link them to requests (e.g. X-Request-Id)")
+	rootCmd.Flags().StringVar(&cfg.JSONLPath, "jsonl", "", "Also append every message to a JSONL file")
+	rootCmd.Flags().Int64Var(&cfg.JSONLMaxSize, "jsonl-max-size", 0, "Rotate the JSONL file once it reaches this many MB (default: never)")
+	rootCmd.Flags().BoolVar(&cfg.NoFollowRedirects, "no-follow-redirects", false, "Return upstream redirects to the client instead of following them")
+	rootCmd.Flags().BoolVar(&cfg.AutoDiscover, "auto-discover", false, "Fetch /.well-known/agent.json from each new agent host seen in traffic, so agents are listed even if clients never fetch their cards")
+	rootCmd.Flags().StringArrayVar(&cfg.FaultRules, "fault-inject", nil, "Inject a fault into matching requests, e.g. \"method=tasks/send,status=500,percent=20\" (repeatable)")
+	rootCmd.Flags().BoolVar(&cfg.Debug, "debug", false, "Serve the proxy's memory, goroutine and database usage at /api/debug/runtime")
+	rootCmd.Flags().BoolVar(&cfg.ReadOnly, "read-only", false, "Refuse API requests that change state (new traces, annotations, pause/resume) with 403")
+	rootCmd.Flags().DurationVar(&cfg.SLO, "slo", 0, "Target response latency, e.g. 300ms; the summary reports per agent how many responses breached it")
+	rootCmd.Flags().IntVar(&cfg.WSBatchMs, "ws-batch-ms", 0, "Send WebSocket updates to the UI as one batch frame at most every this many milliseconds (default: each at once)")
+	rootCmd.Flags().StringVar(&cfg.BlobDir, "blob-dir", "", "Store bodies over --blob-threshold as files in this directory instead of the database")
+	rootCmd.Flags().Int64Var(&cfg.BlobThreshold, "blob-threshold", 1024, "Size in KB above which bodies go to --blob-dir")
+	rootCmd.Flags().Int64Var(&cfg.MaxResponseSize, "max-response-size", 100, "Size in MB above which responses are streamed to the client unbuffered and recorded truncated; 0 for no limit")
+	rootCmd.Flags().StringArrayVar(&aliases, "agent-alias", nil, "Display name for an agent host, e.g. \"localhost:9001=Planner\" (repeatable)")
+	rootCmd.Flags().StringArrayVar(&execs, "exec", nil, "Additional command to trace in the same session (repeatable)")
+
+	rootCmd.AddCommand(newMockCmd(cfg))
+	rootCmd.AddCommand(newReplayCmd(cfg))
+	rootCmd.AddCommand(newCompactCmd(cfg))
+	rootCmd.AddCommand(newImportCmd(cfg))
+	rootCmd.AddCommand(newVersionCmd())
 
 	// Parse without the -- and everything after it
 	var argsToparse []string
@@ -92,15 +284,264 @@ through A2A Trace for inspection.`,
 	if err := rootCmd.Execute(); err != nil {
 		return nil, err
 	}
+	if !ran {
+		return nil, nil
+	}
 
 	// Set UI port to proxy port if not specified
-	if cfg.UIPort == 0 {
+	if cfg.UIPort == 0 && cfg.Subcommand == "" {
 		cfg.UIPort = cfg.Port
 	}
 
 	return cfg, nil
 }
 
+// newMockCmd creates the "mock" subcommand, which serves recorded responses
+func newMockCmd(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mock <trace.json>",
+		Short: "Replay recorded responses from an exported trace as a fake upstream",
+		Long: `Serves the responses recorded in an exported trace (GET /api/export)
+for requests matching the same A2A method and URL path, so clients can run
+against recorded agent behavior offline. Requests without a recorded
+match get a 501 response.`,
+		Example: `  a2a-trace mock trace.json --port 8090`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Subcommand = "mock"
+			cfg.MockTracePath = args[0]
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().IntVarP(&cfg.Port, "port", "p", 8090, "Mock server port")
+
+	return cmd
+}
+
+// newReplayCmd creates the "replay" subcommand, which resends a recorded
+// request
+func newReplayCmd(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay --db <trace.db> --message-id <id>",
+		Short: "Resend a recorded request and compare the response to the original",
+		Long: `Loads a request from a trace database and sends it again to the same
+URL with its original headers and body, printing each new response and
+how it differs from the recorded one. Use --times to send it repeatedly
+when reproducing a flaky failure. Exits non-zero if any send fails or
+gets an error status.`,
+		Example: `  a2a-trace replay --db trace.db --message-id 3f2a9c1e
+  a2a-trace replay --db trace.db --message-id 3f2a9c1e --times 20`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.ReplayTimes < 1 {
+				return fmt.Errorf("--times must be at least 1")
+			}
+			cfg.Subcommand = "replay"
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&cfg.DBPath, "db", "", "SQLite database the request was recorded in")
+	cmd.Flags().StringVar(&cfg.ReplayMessageID, "message-id", "", "ID of the recorded request")
+	cmd.Flags().IntVar(&cfg.ReplayTimes, "times", 1, "Number of times to send the request")
+	_ = cmd.MarkFlagRequired("db")
+	_ = cmd.MarkFlagRequired("message-id")
+
+	return cmd
+}
+
+// newCompactCmd creates the "compact" subcommand, which shrinks a trace
+// database
+func newCompactCmd(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact --db <trace.db>",
+		Short: "Shrink a trace database by reclaiming the space of deleted data",
+		Long: `Rebuilds a trace database with SQLite's VACUUM so the space left by
+deleted traces is returned to the filesystem, and prints its size before
+and after. Don't run it on a database a2a-trace is currently writing to.`,
+		Example: `  a2a-trace compact --db trace.db`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Subcommand = "compact"
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&cfg.DBPath, "db", "", "SQLite database to compact")
+	_ = cmd.MarkFlagRequired("db")
+
+	return cmd
+}
+
+// newImportCmd creates the "import" subcommand, which loads a binary trace
+// export into a database
+func newImportCmd(cfg *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import --db <trace.db> <trace.a2at>",
+		Short: "Load a binary trace export into a trace database",
+		Long: `Loads a trace exported with GET /api/export?format=binary into a trace
+database, keeping its ID, so it can be browsed again with --db. The trace
+must not already be in the database.`,
+		Example: `  a2a-trace import --db archive.db trace-3f2a9c1e.a2at`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Subcommand = "import"
+			cfg.ImportPath = args[0]
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&cfg.DBPath, "db", "", "SQLite database to load the trace into")
+	_ = cmd.MarkFlagRequired("db")
+
+	return cmd
+}
+
+// newVersionCmd creates the "version" subcommand, which prints version
+// information and exits
+func newVersionCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print version information",
+		Args:  cobra.NoArgs,
+		// Only prints, so ParseArgs reports nothing to run
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !asJSON {
+				fmt.Fprintf(cmd.OutOrStdout(), "a2a-trace version %s\n", formatVersion())
+				return nil
+			}
+			data, err := json.Marshal(versionInfo())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print as JSON")
+	return cmd
+}
+
+// VersionInfo is the machine-readable form of the version information
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// versionInfo returns the version information of this build
+func versionInfo() VersionInfo {
+	return VersionInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// Commands returns every command to run, the one after '--' first
+func (c *Config) Commands() [][]string {
+	var commands [][]string
+	if len(c.Command) > 0 {
+		commands = append(commands, c.Command)
+	}
+	return append(commands, c.Execs...)
+}
+
+// parsePorts parses a --port value: one port, or a comma-separated list whose
+// first port is the primary one traced processes are pointed at
+func parsePorts(spec string) (int, []int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(spec, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || port < 1 || port > 65535 {
+			return 0, nil, fmt.Errorf("expected ports between 1 and 65535")
+		}
+		if seen[port] {
+			return 0, nil, fmt.Errorf("port %d given twice", port)
+		}
+		seen[port] = true
+		ports = append(ports, port)
+	}
+	return ports[0], ports[1:], nil
+}
+
+// parseCaptureMethods parses a --capture-methods value: a comma-separated list
+// of HTTP methods, returned upper-cased
+func parseCaptureMethods(spec string) ([]string, error) {
+	var methods []string
+	for _, field := range strings.Split(spec, ",") {
+		method := strings.ToUpper(strings.TrimSpace(field))
+		if method == "" || strings.IndexFunc(method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+			return nil, fmt.Errorf("expected HTTP methods such as POST,PUT")
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+// splitCommandLine splits a command string into arguments, honoring
+// single quotes, double quotes and backslash escapes
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
 // formatVersion returns formatted version information
 func formatVersion() string {
 	return fmt.Sprintf("%s (commit: %s, built: %s)", Version, Commit, BuildDate)
@@ -118,10 +559,18 @@ func PrintBanner(cfg *Config) {
 	fmt.Print(banner)
 	fmt.Printf("  Version: %s\n", Version)
 	fmt.Printf("  Proxy:   http://127.0.0.1:%d\n", cfg.Port)
+	for _, port := range cfg.ExtraPorts {
+		fmt.Printf("           http://127.0.0.1:%d\n", port)
+	}
+	if cfg.UnixSocket != "" {
+		fmt.Printf("  Socket:  %s\n", cfg.UnixSocket)
+	}
 	if !cfg.NoUI {
 		fmt.Printf("  UI:      http://127.0.0.1:%d/ui\n", cfg.UIPort)
 	}
-	fmt.Printf("  Command: %s\n", strings.Join(cfg.Command, " "))
+	for _, command := range cfg.Commands() {
+		fmt.Printf("  Command: %s\n", strings.Join(command, " "))
+	}
 	fmt.Println()
 	fmt.Println("  📡 Intercepting A2A traffic...")
 	fmt.Println()
@@ -146,4 +595,3 @@ func PrintInfo(msg string) {
 func PrintWarning(msg string) {
 	fmt.Printf("⚠️  %s\n", msg)
 }
-
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// runVersion runs the version subcommand with args, returning its output
+func runVersion(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := newVersionCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestVersionJSON(t *testing.T) {
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(runVersion(t, "--json")), &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"version":    Version,
+		"commit":     Commit,
+		"build_date": BuildDate,
+		"go_version": runtime.Version(),
+	}
+	if len(fields) != len(want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("%s = %q, want %q", key, fields[key], value)
+		}
+	}
+}
+
+func TestVersionText(t *testing.T) {
+	if out := runVersion(t); !strings.HasPrefix(out, "a2a-trace version "+Version+" (commit: ") {
+		t.Errorf("got %q", out)
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// envPrefix starts the environment variables flags can be set with
+const envPrefix = "A2A_TRACE_"
+
+// envVar returns the environment variable for a flag, e.g. A2A_TRACE_NO_UI
+// for --no-ui
+func envVar(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnv sets flags that weren't given on the command line from their
+// environment variables. Repeatable flags take one value per line.
+func applyEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" || f.Name == "version" {
+			return
+		}
+		value, ok := os.LookupEnv(envVar(f.Name))
+		if !ok {
+			return
+		}
+
+		values := []string{value}
+		if f.Value.Type() == "stringArray" {
+			values = strings.Split(strings.TrimSpace(value), "\n")
+		}
+		for _, v := range values {
+			if setErr := flags.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("%s: %w", envVar(f.Name), setErr)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// newTestFlags returns flags of each kind applyEnv handles
+func newTestFlags() (*pflag.FlagSet, *int, *string, *bool, *[]string) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	port := flags.Int("port", 8080, "")
+	db := flags.String("db", "", "")
+	noUI := flags.Bool("no-ui", false, "")
+	aliases := flags.StringArray("agent-alias", nil, "")
+	return flags, port, db, noUI, aliases
+}
+
+func TestEnvVar(t *testing.T) {
+	if got := envVar("agent-alias"); got != "A2A_TRACE_AGENT_ALIAS" {
+		t.Errorf("envVar = %s", got)
+	}
+}
+
+func TestApplyEnvFillsUnsetFlags(t *testing.T) {
+	t.Setenv("A2A_TRACE_PORT", "9090")
+	t.Setenv("A2A_TRACE_DB", "/data/env.db")
+	t.Setenv("A2A_TRACE_NO_UI", "true")
+	t.Setenv("A2A_TRACE_AGENT_ALIAS", "planner:8080=Planner\nsearch:8080=Search\n")
+
+	flags, port, db, noUI, aliases := newTestFlags()
+	if err := flags.Parse([]string{"--db", "/data/flag.db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if *port != 9090 || !*noUI {
+		t.Errorf("port = %d, no-ui = %v; want the environment's", *port, *noUI)
+	}
+	if *db != "/data/flag.db" {
+		t.Errorf("db = %s, want the flag to take precedence", *db)
+	}
+	if fmt.Sprint(*aliases) != "[planner:8080=Planner search:8080=Search]" {
+		t.Errorf("agent-alias = %q, want one value per line", *aliases)
+	}
+}
+
+func TestApplyEnvInvalidValue(t *testing.T) {
+	t.Setenv("A2A_TRACE_PORT", "eighty")
+
+	flags, _, _, _, _ := newTestFlags()
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	err := applyEnv(flags)
+	if err == nil || !strings.HasPrefix(err.Error(), "A2A_TRACE_PORT: ") {
+		t.Errorf("got %v, want an error naming the variable", err)
+	}
+}
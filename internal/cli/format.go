@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// ANSI escape codes used by the verbose log
+const (
+	ansiReset  = "\033[0m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// colorEnabled reports whether log output goes to a terminal that can show
+// colors. The verbose log is written by the log package, i.e. to stderr.
+var colorEnabled = isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == ""
+
+// isTerminal reports whether f is a character device rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// FormatMessageLine formats a message for the verbose log as aligned columns
+// (direction, method, status, duration, URL), colored by direction and status
+// when writing to a terminal
+func FormatMessageLine(msg *store.Message) string {
+	direction, directionColor := "→ REQ", ansiCyan
+	if msg.Direction == "response" {
+		direction, directionColor = "← RES", ansiDim
+	}
+
+	method := msg.Method
+	if msg.MethodLabel != "" {
+		method = msg.MethodLabel
+	}
+	if method == "" {
+		method = "-"
+	}
+
+	status, statusColor := "   ", ""
+	duration := "       "
+	if msg.Direction == "response" {
+		status, statusColor = fmt.Sprintf("%3d", msg.StatusCode), statusColorFor(msg.StatusCode)
+		if msg.StatusCode == 0 {
+			status = "ERR"
+		}
+		duration = fmt.Sprintf("%5dms", msg.DurationMs)
+	}
+
+	line := fmt.Sprintf("%s  %-22s  %s  %s  %s",
+		colorize(direction, directionColor),
+		truncate(method, 22),
+		colorize(status, statusColor),
+		duration,
+		msg.URL,
+	)
+	if msg.Error != "" {
+		line += "  " + colorize(msg.Error, ansiRed)
+	}
+	return line
+}
+
+// statusColorFor picks green for 2xx, yellow for 4xx and red for 5xx and
+// transport failures
+func statusColorFor(code int) string {
+	switch {
+	case code == 0 || code >= 500:
+		return ansiRed
+	case code >= 400:
+		return ansiYellow
+	case code >= 200 && code < 300:
+		return ansiGreen
+	}
+	return ""
+}
+
+// colorize wraps s in an ANSI color when colors are enabled
+func colorize(s, color string) string {
+	if !colorEnabled || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
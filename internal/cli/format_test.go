@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// withColor runs f with colors enabled or disabled, as on a terminal or a pipe
+func withColor(enabled bool, f func()) {
+	original := colorEnabled
+	colorEnabled = enabled
+	defer func() { colorEnabled = original }()
+	f()
+}
+
+func TestFormatMessageLineColorsOnlyOnTerminal(t *testing.T) {
+	msg := &store.Message{Direction: "response", Method: "message/send", StatusCode: 503, DurationMs: 42, URL: "http://agent.example/rpc"}
+
+	withColor(true, func() {
+		line := FormatMessageLine(msg)
+		if !strings.Contains(line, ansiRed+"503"+ansiReset) {
+			t.Errorf("terminal line %q lacks a red status", line)
+		}
+	})
+	withColor(false, func() {
+		line := FormatMessageLine(msg)
+		if strings.Contains(line, "\033[") {
+			t.Errorf("piped line %q has color codes", line)
+		}
+		if !strings.Contains(line, "message/send") || !strings.Contains(line, "503") || !strings.Contains(line, "42ms") {
+			t.Errorf("piped line %q lacks a column", line)
+		}
+	})
+}
+
+func TestStatusColorFor(t *testing.T) {
+	for code, want := range map[int]string{200: ansiGreen, 204: ansiGreen, 302: "", 404: ansiYellow, 500: ansiRed, 0: ansiRed} {
+		if got := statusColorFor(code); got != want {
+			t.Errorf("statusColorFor(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestFormatMessageLineAlignsColumns(t *testing.T) {
+	withColor(false, func() {
+		short := FormatMessageLine(&store.Message{Direction: "request", Method: "tasks/get", URL: "http://a/"})
+		long := FormatMessageLine(&store.Message{Direction: "request", Method: "a/very/long/method/name/indeed", URL: "http://a/"})
+		if urlColumn(short) != urlColumn(long) {
+			t.Errorf("URL columns differ:\n%s\n%s", short, long)
+		}
+	})
+}
+
+// urlColumn returns the column, in characters, where a line's URL starts
+func urlColumn(line string) int {
+	return utf8.RuneCountInString(line[:strings.Index(line, "http://")])
+}
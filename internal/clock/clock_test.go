@@ -0,0 +1,23 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+
+	if !clk.Now().Equal(start) {
+		t.Errorf("Now() = %v, want %v", clk.Now(), start)
+	}
+	clk.Advance(90 * time.Second)
+	if want := start.Add(90 * time.Second); !clk.Now().Equal(want) {
+		t.Errorf("after Advance, Now() = %v, want %v", clk.Now(), want)
+	}
+	clk.Set(start)
+	if !clk.Now().Equal(start) {
+		t.Errorf("after Set, Now() = %v, want %v", clk.Now(), start)
+	}
+}
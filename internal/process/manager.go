@@ -2,9 +2,13 @@ package process
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -13,14 +17,22 @@ import (
 	"syscall"
 )
 
+// maxOutputLine is the longest line of output relayed line by line; longer
+// lines switch relaying to raw bytes
+const maxOutputLine = 1024 * 1024
+
 // OutputHandler is called for each line of output from the process
 type OutputHandler func(line string, isStderr bool)
 
 // Manager manages the child process
 type Manager struct {
 	cmd           *exec.Cmd
+	name          string
 	proxyPort     int
 	outputHandler OutputHandler
+	quiet         bool
+	log           io.Writer
+	noInject      map[string]bool // Uppercased names of proxy variables to leave alone
 	mu            sync.Mutex
 	started       bool
 	ctx           context.Context
@@ -30,8 +42,12 @@ type Manager struct {
 // Config holds process manager configuration
 type Config struct {
 	Command       []string
+	Name          string // Tags the process's traffic and output when several run together
 	ProxyPort     int
 	OutputHandler OutputHandler
+	Quiet         bool      // Don't relay output to the terminal
+	Log           io.Writer // Also write output here, e.g. a --child-log file
+	NoInject      []string  // Proxy variables to leave as they are in the environment, in either case
 }
 
 // New creates a new process Manager
@@ -43,11 +59,18 @@ func New(cfg Config) (*Manager, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	m := &Manager{
+		name:          cfg.Name,
 		proxyPort:     cfg.ProxyPort,
 		outputHandler: cfg.OutputHandler,
+		quiet:         cfg.Quiet,
+		log:           cfg.Log,
+		noInject:      make(map[string]bool),
 		ctx:           ctx,
 		cancel:        cancel,
 	}
+	for _, name := range cfg.NoInject {
+		m.noInject[strings.ToUpper(name)] = true
+	}
 
 	// Create the command
 	if len(cfg.Command) == 1 {
@@ -103,6 +126,11 @@ func (m *Manager) Start() error {
 func (m *Manager) buildEnv() []string {
 	env := os.Environ()
 	proxyURL := fmt.Sprintf("http://127.0.0.1:%d", m.proxyPort)
+	if m.name != "" {
+		// Clients send the userinfo back as Proxy-Authorization, which lets
+		// the proxy tell which process a request came from
+		proxyURL = fmt.Sprintf("http://%s@127.0.0.1:%d", url.User(m.name).String(), m.proxyPort)
+	}
 
 	// Add/override proxy environment variables
 	proxyVars := map[string]string{
@@ -111,14 +139,21 @@ func (m *Manager) buildEnv() []string {
 		"HTTPS_PROXY": proxyURL,
 		"https_proxy": proxyURL,
 		// Force proxy for localhost (many clients skip localhost by default)
-		"NO_PROXY":  "",
-		"no_proxy":  "",
+		"NO_PROXY": "",
+		"no_proxy": "",
 		// A2A specific - some implementations use these
 		"A2A_PROXY":    proxyURL,
 		"A2A_TRACE":    "1",
 		"A2A_TRACE_UI": fmt.Sprintf("http://127.0.0.1:%d/ui", m.proxyPort),
 	}
 
+	// Variables excluded with --no-inject keep their inherited values
+	for key := range proxyVars {
+		if m.noInject[strings.ToUpper(key)] {
+			delete(proxyVars, key)
+		}
+	}
+
 	// Remove existing proxy vars and add new ones
 	filteredEnv := make([]string, 0, len(env)+len(proxyVars))
 	for _, e := range env {
@@ -135,26 +170,100 @@ func (m *Manager) buildEnv() []string {
 	return filteredEnv
 }
 
-// handleOutput reads from a pipe and calls the output handler
+// handleOutput reads from a pipe and relays it line by line, calling the
+// output handler for each. If the output can't be split into lines, e.g. a
+// line is over maxOutputLine, the rest is copied as raw bytes instead, so
+// relaying never stops early.
 func (m *Manager) handleOutput(pipe io.ReadCloser, isStderr bool) {
+	// Keep what was read of a line too long to scan, to copy it first
+	var pending []byte
 	scanner := bufio.NewScanner(pipe)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
+	scanner.Buffer(make([]byte, 64*1024), maxOutputLine)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanRawLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= maxOutputLine {
+			pending = append([]byte(nil), data...)
+			return 0, nil, bufio.ErrTooLong
+		}
+		return advance, token, err
+	})
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		
-		// Always print to appropriate output
+		m.relayLine(scanner.Bytes(), isStderr)
+	}
+
+	// The pipe is closed once the process has been waited on
+	err := scanner.Err()
+	if err == nil || errors.Is(err, os.ErrClosed) {
+		return
+	}
+	log.Printf("Relaying output of %s as raw bytes: %v", m.displayName(), err)
+	m.relayRaw(io.MultiReader(bytes.NewReader(pending), pipe), isStderr)
+}
+
+// scanRawLines is a split function like bufio.ScanLines that keeps each
+// line's ending, so lines can be relayed byte for byte
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// relayLine writes a line of output to the terminal and log exactly as the
+// process wrote it, and passes it to the handler without its line ending
+// and with invalid UTF-8 replaced
+func (m *Manager) relayLine(raw []byte, isStderr bool) {
+	printed := raw
+	if m.name != "" {
+		printed = append([]byte(fmt.Sprintf("[%s] ", m.name)), raw...)
+	}
+	for _, w := range m.outputWriters(isStderr) {
+		_, _ = w.Write(printed)
+	}
+
+	// Call handler if set
+	if m.outputHandler != nil {
+		line := strings.TrimSuffix(strings.TrimSuffix(string(raw), "\n"), "\r")
+		m.outputHandler(strings.ToValidUTF8(line, "\uFFFD"), isStderr)
+	}
+}
+
+// relayRaw copies output unchanged to the terminal and log until it ends.
+// Raw output isn't split into lines, so it isn't passed to the handler.
+func (m *Manager) relayRaw(r io.Reader, isStderr bool) {
+	writers := m.outputWriters(isStderr)
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Printf("Failed to relay output of %s: %v", m.displayName(), err)
+	}
+}
+
+// outputWriters returns where output is relayed: the log, and the terminal
+// unless quiet
+func (m *Manager) outputWriters(isStderr bool) []io.Writer {
+	var writers []io.Writer
+	if m.log != nil {
+		writers = append(writers, m.log)
+	}
+	if !m.quiet {
 		if isStderr {
-			fmt.Fprintln(os.Stderr, line)
+			writers = append(writers, os.Stderr)
 		} else {
-			fmt.Println(line)
+			writers = append(writers, os.Stdout)
 		}
+	}
+	return writers
+}
 
-		// Call handler if set
-		if m.outputHandler != nil {
-			m.outputHandler(line, isStderr)
-		}
+// displayName names the process in log messages
+func (m *Manager) displayName() string {
+	if m.name != "" {
+		return m.name
 	}
+	return "the traced process"
 }
 
 // Wait waits for the process to exit and returns the exit code
@@ -219,7 +328,7 @@ func (m *Manager) IsRunning() bool {
 	if m.cmd == nil || m.cmd.Process == nil {
 		return false
 	}
-	
+
 	// Check if process is still running
 	err := m.cmd.Process.Signal(syscall.Signal(0))
 	return err == nil
@@ -237,6 +346,11 @@ func (m *Manager) SetupSignalHandling() {
 	}()
 }
 
+// Name returns the name used to tag the process, if any
+func (m *Manager) Name() string {
+	return m.name
+}
+
 // CommandString returns the command as a string
 func (m *Manager) CommandString() string {
 	if m.cmd == nil {
@@ -244,4 +358,3 @@ func (m *Manager) CommandString() string {
 	}
 	return strings.Join(m.cmd.Args, " ")
 }
-
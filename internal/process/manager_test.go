@@ -0,0 +1,156 @@
+package process
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what f writes to os.Stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = original }()
+
+	f()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestQuietOutputGoesToLogOnly(t *testing.T) {
+	var log bytes.Buffer
+	var handled []string
+	m, err := New(Config{
+		Command:       []string{"true"},
+		Quiet:         true,
+		Log:           &log,
+		OutputHandler: func(line string, isStderr bool) { handled = append(handled, line) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := captureStdout(t, func() {
+		m.handleOutput(io.NopCloser(strings.NewReader("first\nsecond\n")), false)
+	})
+
+	if stdout != "" {
+		t.Errorf("quiet mode printed %q", stdout)
+	}
+	if log.String() != "first\nsecond\n" {
+		t.Errorf("log got %q", log.String())
+	}
+	if strings.Join(handled, ",") != "first,second" {
+		t.Errorf("handler got %v", handled)
+	}
+}
+
+func TestOutputRelayedWhenNotQuiet(t *testing.T) {
+	var log bytes.Buffer
+	m, err := New(Config{Command: []string{"true"}, Name: "worker", Log: &log})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := captureStdout(t, func() {
+		m.handleOutput(io.NopCloser(strings.NewReader("ready\n")), false)
+	})
+
+	if stdout != "[worker] ready\n" || log.String() != "[worker] ready\n" {
+		t.Errorf("stdout %q, log %q; want both tagged with the name", stdout, log.String())
+	}
+}
+
+func TestOutputRelayedPastLongLine(t *testing.T) {
+	var out bytes.Buffer
+	var handled []string
+	m, err := New(Config{
+		Command:       []string{"true"},
+		Quiet:         true,
+		Log:           &out,
+		OutputHandler: func(line string, isStderr bool) { handled = append(handled, line) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Binary, then a line over maxOutputLine, then more output
+	input := "before\n\xff\xfe\n" + strings.Repeat("x", 2*maxOutputLine) + "\nafter\n"
+	m.handleOutput(io.NopCloser(strings.NewReader(input)), false)
+
+	if out.String() != input {
+		t.Errorf("relayed %d bytes, want all %d", out.Len(), len(input))
+	}
+	if strings.Join(handled, ",") != "before,\uFFFD" {
+		t.Errorf("handler got %q, want the lines before the long one", handled)
+	}
+}
+
+// envOf maps an environment list by variable name
+func envOf(env []string) map[string]string {
+	vars := make(map[string]string)
+	for _, e := range env {
+		key, value, _ := strings.Cut(e, "=")
+		vars[key] = value
+	}
+	return vars
+}
+
+func TestBuildEnvNoInject(t *testing.T) {
+	t.Setenv("NO_PROXY", "localhost,internal.example")
+	t.Setenv("no_proxy", "localhost")
+	t.Setenv("HTTPS_PROXY", "http://corporate:3128")
+
+	m, err := New(Config{Command: []string{"true"}, ProxyPort: 9000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := envOf(m.buildEnv())
+	if env["NO_PROXY"] != "" || env["no_proxy"] != "" || env["HTTPS_PROXY"] != "http://127.0.0.1:9000" {
+		t.Errorf("inherited proxy variables not overridden: %v", env)
+	}
+
+	// Names match in either case
+	m, err = New(Config{Command: []string{"true"}, ProxyPort: 9000, NoInject: []string{"no_proxy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env = envOf(m.buildEnv())
+	if env["NO_PROXY"] != "localhost,internal.example" || env["no_proxy"] != "localhost" {
+		t.Errorf("NO_PROXY = %q, no_proxy = %q; want them inherited", env["NO_PROXY"], env["no_proxy"])
+	}
+	if env["HTTPS_PROXY"] != "http://127.0.0.1:9000" {
+		t.Errorf("HTTPS_PROXY = %q, want it still injected", env["HTTPS_PROXY"])
+	}
+}
+
+func TestNonUTF8OutputRelayedVerbatim(t *testing.T) {
+	var handled []string
+	m, err := New(Config{
+		Command:       []string{"true"},
+		OutputHandler: func(line string, isStderr bool) { handled = append(handled, line) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "caf\xe9 ok\r\n\x1b[31mred\x1b[0m \xc3\x28\n"
+	stdout := captureStdout(t, func() {
+		m.handleOutput(io.NopCloser(strings.NewReader(input)), false)
+	})
+
+	if stdout != input {
+		t.Errorf("stdout got %q, want %q", stdout, input)
+	}
+	if strings.Join(handled, "|") != "caf\uFFFD ok|\x1b[31mred\x1b[0m \uFFFD(" {
+		t.Errorf("handler got %q, want decoded lines without endings", handled)
+	}
+}
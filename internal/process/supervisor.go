@@ -0,0 +1,99 @@
+package process
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Supervisor runs several child processes as one traced session
+type Supervisor struct {
+	managers []*Manager
+}
+
+// NewSupervisor creates a process Manager for each config
+func NewSupervisor(cfgs []Config) (*Supervisor, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("no command specified")
+	}
+
+	s := &Supervisor{}
+	for _, cfg := range cfgs {
+		m, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.managers = append(s.managers, m)
+	}
+
+	return s, nil
+}
+
+// Start starts all child processes, stopping any already started if one fails
+func (s *Supervisor) Start() error {
+	for i, m := range s.managers {
+		if err := m.Start(); err != nil {
+			for _, started := range s.managers[:i] {
+				_ = started.Stop()
+			}
+			if m.Name() != "" {
+				return fmt.Errorf("%s: %w", m.Name(), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Wait waits for all child processes to exit and returns the first non-zero exit code
+func (s *Supervisor) Wait() (int, error) {
+	codes := make([]int, len(s.managers))
+	errs := make([]error, len(s.managers))
+
+	var wg sync.WaitGroup
+	for i, m := range s.managers {
+		wg.Add(1)
+		go func(i int, m *Manager) {
+			defer wg.Done()
+			codes[i], errs[i] = m.Wait()
+		}(i, m)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return -1, err
+		}
+	}
+	for _, code := range codes {
+		if code != 0 {
+			return code, nil
+		}
+	}
+	return 0, nil
+}
+
+// Stop stops all child processes
+func (s *Supervisor) Stop() error {
+	var firstErr error
+	for _, m := range s.managers {
+		if err := m.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// IsRunning returns true if any child process is still running
+func (s *Supervisor) IsRunning() bool {
+	for _, m := range s.managers {
+		if m.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// Managers returns the managed processes in start order
+func (s *Supervisor) Managers() []*Manager {
+	return s.managers
+}
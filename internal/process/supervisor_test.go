@@ -0,0 +1,70 @@
+package process
+
+import "testing"
+
+func TestSupervisorManagesEveryProcess(t *testing.T) {
+	s, err := NewSupervisor([]Config{
+		{Command: []string{"sleep", "30"}, Name: "host", ProxyPort: 8080, Quiet: true},
+		{Command: []string{"sleep", "30"}, Name: "worker", ProxyPort: 8080, Quiet: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	managers := s.Managers()
+	if len(managers) != 2 {
+		t.Fatalf("got %d managers, want 2", len(managers))
+	}
+	for _, m := range managers {
+		if !m.IsRunning() {
+			t.Errorf("%s isn't running", m.Name())
+		}
+	}
+
+	// Stopping the session stops every child
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsRunning() {
+		t.Error("a child is still running after Stop")
+	}
+}
+
+func TestSupervisorTagsProxyURLWithName(t *testing.T) {
+	s, err := NewSupervisor([]Config{
+		{Command: []string{"true"}, Name: "host", ProxyPort: 8080},
+		{Command: []string{"true"}, Name: "worker", ProxyPort: 8080},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range s.Managers() {
+		want := "HTTP_PROXY=http://" + m.Name() + "@127.0.0.1:8080"
+		if !containsEnv(m.buildEnv(), want) {
+			t.Errorf("%s environment lacks %s", m.Name(), want)
+		}
+	}
+}
+
+func TestNewSupervisorRequiresCommand(t *testing.T) {
+	if _, err := NewSupervisor(nil); err == nil {
+		t.Error("expected an error without commands")
+	}
+}
+
+// containsEnv reports whether env has the entry want
+func containsEnv(env []string, want string) bool {
+	for _, e := range env {
+		if e == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// a2aArtifact is an artifact as it appears in a task result
+type a2aArtifact struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Index       *int      `json:"index"`
+	Parts       []a2aPart `json:"parts"`
+}
+
+// a2aPart is one part of an artifact. Older agents name the part kind
+// "type", newer ones "kind".
+type a2aPart struct {
+	Kind string          `json:"kind"`
+	Type string          `json:"type"`
+	Text string          `json:"text"`
+	Data json.RawMessage `json:"data"`
+	File *struct {
+		Name     string `json:"name"`
+		MimeType string `json:"mimeType"`
+		Bytes    string `json:"bytes"` // Base64
+		URI      string `json:"uri"`
+	} `json:"file"`
+}
+
+// ParseArtifacts extracts artifact summaries from a JSON-RPC response whose
+// result is a task (result.artifacts) or an artifact update event
+// (result.artifact). It returns nil when there are none.
+func (i *Interceptor) ParseArtifacts(body []byte) []*store.Artifact {
+	var resp struct {
+		Result *struct {
+			Artifacts []a2aArtifact `json:"artifacts"`
+			Artifact  *a2aArtifact  `json:"artifact"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Result == nil {
+		return nil
+	}
+
+	raw := resp.Result.Artifacts
+	if resp.Result.Artifact != nil {
+		raw = append(raw, *resp.Result.Artifact)
+	}
+
+	var artifacts []*store.Artifact
+	for n, a := range raw {
+		artifact := &store.Artifact{
+			Index:       n,
+			Name:        a.Name,
+			Description: a.Description,
+			PartCount:   len(a.Parts),
+		}
+		if a.Index != nil {
+			artifact.Index = *a.Index
+		}
+
+		var types []string
+		for _, part := range a.Parts {
+			kind := part.Kind
+			if kind == "" {
+				kind = part.Type
+			}
+			if kind != "" && !containsString(types, kind) {
+				types = append(types, kind)
+			}
+
+			artifact.Size += int64(len(part.Text)) + int64(len(part.Data))
+			if part.File != nil {
+				if artifact.MimeType == "" {
+					artifact.MimeType = part.File.MimeType
+				}
+				if artifact.Name == "" {
+					artifact.Name = part.File.Name
+				}
+				artifact.Size += base64Size(part.File.Bytes)
+			}
+		}
+		artifact.Types = strings.Join(types, ",")
+
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts
+}
+
+// base64Size returns the decoded size of base64 content without decoding it
+func base64Size(encoded string) int64 {
+	return int64(base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(encoded, "="))))
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+const twoArtifactResult = `{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"t1","status":{"state":"completed"},"artifacts":[
+	{"name":"summary","parts":[{"kind":"text","text":"hello"},{"kind":"data","data":{"a":1}}]},
+	{"parts":[{"kind":"file","file":{"name":"report.pdf","mimeType":"application/pdf","bytes":"aGVsbG8gd29ybGQ="}}]}
+]}}`
+
+func TestParseArtifacts(t *testing.T) {
+	artifacts := NewInterceptor().ParseArtifacts([]byte(twoArtifactResult))
+	if len(artifacts) != 2 {
+		t.Fatalf("got %d artifacts, want 2", len(artifacts))
+	}
+
+	summary, report := artifacts[0], artifacts[1]
+	if summary.Name != "summary" || summary.Types != "text,data" || summary.PartCount != 2 || summary.Size != 12 {
+		t.Errorf("first artifact = %+v", summary)
+	}
+	if report.Index != 1 || report.Name != "report.pdf" || report.MimeType != "application/pdf" || report.Types != "file" || report.Size != 11 {
+		t.Errorf("second artifact = %+v", report)
+	}
+}
+
+func TestParseArtifactsWithoutArtifacts(t *testing.T) {
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"t1","status":{"state":"working"}}}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"failed"}}`,
+		`not json`,
+	} {
+		if artifacts := NewInterceptor().ParseArtifacts([]byte(body)); artifacts != nil {
+			t.Errorf("%s: got %d artifacts", body, len(artifacts))
+		}
+	}
+}
+
+func TestArtifactsEndpoint(t *testing.T) {
+	upstream := newJSONUpstream(t, twoArtifactResult)
+	p, st, trace := newTestProxy(t, Config{})
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages/"+messages[1].ID+"/artifacts", nil))
+
+	var artifacts []*store.Artifact
+	if err := json.Unmarshal(rec.Body.Bytes(), &artifacts); err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 2 || artifacts[0].Name != "summary" || artifacts[1].Name != "report.pdf" {
+		t.Errorf("got %d artifacts: %s", len(artifacts), rec.Body.String())
+	}
+}
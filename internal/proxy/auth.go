@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UpstreamAuth holds HTTP Basic credentials the proxy adds to requests for
+// one upstream host, written as host=user:pass. host is either host:port,
+// matching that port only, or a bare host matching any port.
+type UpstreamAuth struct {
+	Host     string
+	Username string
+	Password string
+}
+
+// ParseUpstreamAuth parses upstream credentials
+func ParseUpstreamAuth(spec string) (*UpstreamAuth, error) {
+	host, credentials, ok := strings.Cut(spec, "=")
+	host = strings.ToLower(strings.TrimSpace(host))
+	if !ok || host == "" {
+		return nil, fmt.Errorf("expected host=user:pass")
+	}
+	username, password, ok := strings.Cut(credentials, ":")
+	if !ok || username == "" {
+		return nil, fmt.Errorf("expected user:pass for %s", host)
+	}
+	return &UpstreamAuth{Host: host, Username: username, Password: password}, nil
+}
+
+// upstreamAuthFor returns the credentials for a target URL, preferring an
+// exact host:port match over a bare host, or nil
+func (p *Proxy) upstreamAuthFor(targetURL string) *UpstreamAuth {
+	if len(p.upstreamAuth) == 0 {
+		return nil
+	}
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Host)
+	if auth, ok := p.upstreamAuth[host]; ok {
+		return auth
+	}
+	return p.upstreamAuth[strings.ToLower(u.Hostname())]
+}
+
+// injectUpstreamAuth sets the configured credentials on a request to the
+// upstream, replacing any the client sent. Only the forwarded request
+// carries them; the recorded request keeps the client's headers, so they
+// are never stored.
+func (p *Proxy) injectUpstreamAuth(proxyReq *http.Request, targetURL string) {
+	if auth := p.upstreamAuthFor(targetURL); auth != nil {
+		proxyReq.SetBasicAuth(auth.Username, auth.Password)
+	}
+}
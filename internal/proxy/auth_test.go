@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// mustParseAuth parses upstream credentials, failing the test on an error
+func mustParseAuth(t *testing.T, spec string) *UpstreamAuth {
+	t.Helper()
+	auth, err := ParseUpstreamAuth(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestUpstreamAuthInjectedButNotStored(t *testing.T) {
+	var gotUser, gotPass string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		gotUser, gotPass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	p, st, trace := newTestProxy(t, Config{UpstreamAuth: []*UpstreamAuth{mustParseAuth(t, u.Host+"=agent:s3cret")}})
+
+	// The client's own credentials are replaced
+	req := httptest.NewRequest("POST", upstream.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("client", "wrong")
+	p.handleProxy(httptest.NewRecorder(), req)
+
+	if gotUser != "agent" || gotPass != "s3cret" {
+		t.Errorf("upstream got %s:%s, want the configured credentials", gotUser, gotPass)
+	}
+	for _, msg := range messagesOf(t, st, trace.ID) {
+		if strings.Contains(msg.Headers, "s3cret") || strings.Contains(msg.Headers, "YWdlbnQ6czNjcmV0") {
+			t.Errorf("%s stored the injected credentials: %s", msg.Direction, msg.Headers)
+		}
+	}
+}
+
+func TestUpstreamAuthFor(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{UpstreamAuth: []*UpstreamAuth{
+		mustParseAuth(t, "Agent.Example=any:port"),
+		mustParseAuth(t, "agent.example:8443=exact:port"),
+	}})
+
+	tests := []struct{ url, want string }{
+		{"https://agent.example:8443/rpc", "exact"},
+		{"http://AGENT.example:9000/rpc", "any"},
+		{"http://agent.example/rpc", "any"},
+		{"http://other.example/rpc", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if auth := p.upstreamAuthFor(tt.url); auth != nil {
+			got = auth.Username
+		}
+		if got != tt.want {
+			t.Errorf("%s: got credentials %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseUpstreamAuthErrors(t *testing.T) {
+	for _, spec := range []string{"agent.example", "=user:pass", "agent.example=user", "agent.example=:pass"} {
+		if _, err := ParseUpstreamAuth(spec); err == nil {
+			t.Errorf("%q parsed without error", spec)
+		}
+	}
+}
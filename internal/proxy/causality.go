@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// agentLookupTimeout bounds resolving an agent's host name to the addresses
+// its outgoing requests come from
+const agentLookupTimeout = time.Second
+
+// inflightRequest is a forwarded request still waiting on its response. An
+// agent serving it may make requests of its own, which it becomes the parent
+// of.
+type inflightRequest struct {
+	messageID  string
+	toAgent    string
+	remoteAddr string // Client connection the request arrived on
+	started    time.Time
+}
+
+// trackInflight records a request as being served until the returned
+// function is called, after setting its ParentID to the request it was
+// likely made to serve.
+//
+// The proxy can't see which agent sent a request, so the parent is inferred:
+// it is the most recently started request still in flight to the agent on
+// the host the new request came from, over a different client connection. A
+// client waiting on a response can't send more requests over the same
+// connection, so those are never the cause, and requests from other hosts
+// come from other clients.
+func (p *Proxy) trackInflight(msg *store.Message, remoteAddr string) func() {
+	if parent := p.findParent(msg, remoteAddr); parent != "" {
+		msg.ParentID = parent
+	}
+
+	// Preflights are answered by the agent's HTTP stack, not served
+	if msg.Preflight {
+		return func() {}
+	}
+
+	p.inflightMu.Lock()
+	p.inflight[msg.ID] = &inflightRequest{
+		messageID:  msg.ID,
+		toAgent:    msg.ToAgent,
+		remoteAddr: remoteAddr,
+		started:    msg.Timestamp,
+	}
+	p.inflightMu.Unlock()
+
+	return func() {
+		p.inflightMu.Lock()
+		defer p.inflightMu.Unlock()
+		delete(p.inflight, msg.ID)
+	}
+}
+
+// findParent returns the ID of the in-flight request a new request was
+// likely made to serve, or ""
+func (p *Proxy) findParent(msg *store.Message, remoteAddr string) string {
+	remoteHost, _, err := net.SplitHostPort(remoteAddr)
+	remoteIP := net.ParseIP(remoteHost)
+	if err != nil || remoteIP == nil {
+		return "" // e.g. over a unix socket, where the sender is unknown
+	}
+
+	p.inflightMu.Lock()
+	var candidates []*inflightRequest
+	for _, req := range p.inflight {
+		if req.remoteAddr != remoteAddr && req.toAgent != msg.ToAgent {
+			candidates = append(candidates, req)
+		}
+	}
+	p.inflightMu.Unlock()
+
+	// Resolving agent hosts may block, so it's done outside the lock
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].started.After(candidates[j].started)
+	})
+	for _, req := range candidates {
+		if p.agentHasIP(req.toAgent, remoteIP) {
+			return req.messageID
+		}
+	}
+	return ""
+}
+
+// agentHasIP reports whether an agent, identified by host[:port], is at ip.
+// Loopback addresses all count as the same host.
+func (p *Proxy) agentHasIP(agent string, ip net.IP) bool {
+	host, _, err := net.SplitHostPort(agent)
+	if err != nil {
+		host = agent
+	}
+	for _, agentIP := range p.agentIPs(host) {
+		if agentIP.Equal(ip) || (agentIP.IsLoopback() && ip.IsLoopback()) {
+			return true
+		}
+	}
+	return false
+}
+
+// agentIPs returns the addresses of an agent host, resolving names once and
+// caching the result
+func (p *Proxy) agentIPs(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	p.inflightMu.Lock()
+	ips, ok := p.agentAddrs[host]
+	p.inflightMu.Unlock()
+	if ok {
+		return ips
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentLookupTimeout)
+	defer cancel()
+	addrs, _ := net.DefaultResolver.LookupIPAddr(ctx, host)
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+
+	p.inflightMu.Lock()
+	p.agentAddrs[host] = ips // A failed lookup is cached too, as no addresses
+	p.inflightMu.Unlock()
+	return ips
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNestedCallLinkedToParent(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	proxyURL := startProxy(t, p)
+	via, _ := url.Parse(proxyURL)
+	newClient := func() *http.Client {
+		return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(via)}}
+	}
+
+	search := newJSONUpstream(t, `{"jsonrpc":"2.0","id":2,"result":{}}`)
+
+	// The planner delegates to the search agent, through the proxy, while
+	// serving each request
+	planner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		resp, err := newClient().Post(search.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tasks/send","params":{}}`))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		resp.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	t.Cleanup(planner.Close)
+
+	resp, err := newClient().Post(planner.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var outer, inner string
+	for _, msg := range messagesOf(t, st, trace.ID) {
+		if msg.Direction != "request" {
+			continue
+		}
+		switch msg.Method {
+		case "message/send":
+			outer = msg.ID
+			if msg.ParentID != "" {
+				t.Errorf("outer request has parent %s", msg.ParentID)
+			}
+		case "tasks/send":
+			inner = msg.ID
+			if msg.ParentID == "" {
+				t.Error("nested request has no parent")
+			}
+		}
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages/"+outer+"/children", nil))
+	var children []struct {
+		ID       string `json:"id"`
+		ParentID string `json:"parent_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &children); err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 1 || children[0].ID != inner || children[0].ParentID != outer {
+		t.Errorf("children of the outer request %s, want the nested request", rec.Body)
+	}
+}
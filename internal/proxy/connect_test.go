@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newEchoServer returns the address of a TCP server echoing whatever it is
+// sent until the client closes the connection
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// openTunnel connects to the proxy and asks it for a tunnel to target
+func openTunnel(t *testing.T, proxyAddr, target string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT got %d", resp.StatusCode)
+	}
+	return conn
+}
+
+// waitForGoroutines waits for the goroutine count to fall back to at most
+// baseline, failing the test if it doesn't
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines, want at most %d:\n%s", runtime.NumGoroutine(), baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnectTunnelTornDownWhenClientCloses(t *testing.T) {
+	echo := newEchoServer(t)
+	p, _, _ := newTestProxy(t, Config{})
+	proxyAddr := startProxy(t, p)[len("http://"):]
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		conn := openTunnel(t, proxyAddr, echo)
+		io.WriteString(conn, "ping")
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+			t.Fatalf("read %q, %v through the tunnel", buf, err)
+		}
+		conn.Close()
+	}
+
+	waitForGoroutines(t, baseline)
+}
+
+func TestConnectTunnelTornDownWhenUpstreamCloses(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close() // Hangs up straight away
+		}
+	}()
+	p, _, _ := newTestProxy(t, Config{})
+	proxyAddr := startProxy(t, p)[len("http://"):]
+	baseline := runtime.NumGoroutine()
+
+	conn := openTunnel(t, proxyAddr, ln.Addr().String())
+	defer conn.Close()
+	// The client, though still connected, sees the tunnel close
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read got %v, want EOF", err)
+	}
+
+	waitForGoroutines(t, baseline)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// connTiming collects how long getting a connection to an upstream took.
+// Redirects can open several connections; their phases add up.
+type connTiming struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	gotConn      bool
+	timing       store.ConnectionTiming
+}
+
+// withConnTiming returns a request that records its connection timing in
+// the returned connTiming
+func withConnTiming(req *http.Request) (*http.Request, *connTiming) {
+	t := &connTiming{}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.DNSMs += time.Since(t.dnsStart).Milliseconds()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.ConnectMs += time.Since(t.connectStart).Milliseconds()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.TLSHandshakeMs += time.Since(t.tlsStart).Milliseconds()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = true
+			t.timing.Reused = info.Reused
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+// JSON encodes the timing for Message.Timing, or returns "" if no
+// connection was made
+func (t *connTiming) JSON() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.gotConn && t.timing == (store.ConnectionTiming{}) {
+		return ""
+	}
+	timingJSON, _ := json.Marshal(t.timing)
+	return string(timingJSON)
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// RuntimeStats is the proxy's own resource usage, served at /api/debug/runtime
+type RuntimeStats struct {
+	Goroutines    int     `json:"goroutines"`
+	HeapAlloc     uint64  `json:"heap_alloc_bytes"`
+	HeapInuse     uint64  `json:"heap_inuse_bytes"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	Sys           uint64  `json:"sys_bytes"`
+	NumGC         uint32  `json:"num_gc"`
+	PauseTotalMs  float64 `json:"gc_pause_total_ms"`
+	LastGC        string  `json:"last_gc,omitempty"`
+	DBSize        int64   `json:"db_size_bytes"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
+}
+
+// handleDebugRuntime reports goroutines, heap and GC statistics and the
+// database size, to help track down leaks in long sessions
+func (p *Proxy) handleDebugRuntime(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		PauseTotalMs:  float64(mem.PauseTotalNs) / float64(time.Millisecond),
+		UptimeSeconds: int64(time.Since(p.startedAt).Seconds()),
+	}
+	if mem.LastGC != 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	size, err := p.store.SizeContext(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats.DBSize = size
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(stats)
+	w.Write(json)
+}
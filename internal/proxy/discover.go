@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// agentCardPath is where agents publish their card
+	agentCardPath = "/.well-known/agent.json"
+	// discoverRetryInterval is how long auto-discovery waits before trying a
+	// host again after its card couldn't be fetched
+	discoverRetryInterval = time.Minute
+	// maxAgentCardSize bounds the agent cards auto-discovery reads
+	maxAgentCardSize = 1 << 20
+)
+
+// hostDiscovery is auto-discovery's progress on one agent host
+type hostDiscovery struct {
+	found    bool      // The host's agent is known; never fetch again
+	fetching bool      // A fetch is under way
+	lastTry  time.Time // When the last fetch started, for rate limiting
+}
+
+// discoverAgent fetches the agent card of a request's target host in the
+// background, the first time the host is seen, so agents are listed even
+// when clients never ask for their cards. Hosts whose card can't be fetched
+// are retried at most once per discoverRetryInterval.
+func (p *Proxy) discoverAgent(targetURL string) {
+	u, err := url.Parse(targetURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return
+	}
+	// A client fetching the card records the agent itself
+	if u.Path == agentCardPath {
+		return
+	}
+	host := canonicalHost(u)
+
+	p.discoverMu.Lock()
+	defer p.discoverMu.Unlock()
+	state := p.discovered[host]
+	if state == nil {
+		state = &hostDiscovery{}
+		p.discovered[host] = state
+	}
+	now := time.Now()
+	if state.found || state.fetching || now.Sub(state.lastTry) < discoverRetryInterval {
+		return
+	}
+	state.fetching = true
+	state.lastTry = now
+
+	cardURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: agentCardPath}).String()
+	go func() {
+		found := p.fetchAgentCard(host, cardURL)
+
+		p.discoverMu.Lock()
+		defer p.discoverMu.Unlock()
+		state.fetching = false
+		state.found = found
+	}()
+}
+
+// canonicalHost returns a URL's host lowercased and without the scheme's
+// default port, as agent URLs are stored, so host:80 and host are the same
+func canonicalHost(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]" // IPv6 literal
+	}
+	return host
+}
+
+// fetchAgentCard records the agent at a card URL, through the same transport
+// as proxied traffic, and returns whether the host's agent is now known. The
+// fetch itself isn't recorded as a message.
+func (p *Proxy) fetchAgentCard(host, cardURL string) bool {
+	// The agent may already be known from an earlier session
+	if agents, err := p.store.GetAgents(); err == nil {
+		for _, agent := range agents {
+			if u, err := url.Parse(agent.URL); err == nil && canonicalHost(u) == host {
+				return true
+			}
+		}
+	}
+
+	req, err := http.NewRequest("GET", cardURL, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Via", "1.1 "+viaToken)
+	p.injectUpstreamAuth(req, cardURL)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("Failed to discover agent at %s: %v", host, err)
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to discover agent at %s: %s", host, resp.Status)
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAgentCardSize))
+	if err != nil {
+		return false
+	}
+
+	agent := p.interceptor.ParseAgentCard(body, cardURL)
+	if agent == nil || agent.Name == "" {
+		log.Printf("Failed to discover agent at %s: not an agent card", host)
+		return false
+	}
+	if err := p.saveAgent(agent); err != nil {
+		log.Printf("Failed to save agent: %v", err)
+		return false
+	}
+	log.Printf("Discovered agent: %s (%s)", agent.Name, agent.URL)
+	if p.onAgent != nil {
+		p.onAgent(agent)
+	}
+	return true
+}
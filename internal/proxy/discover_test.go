@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// newCardServer returns an agent serving card at the agent card path,
+// or 404 if it's empty, counting the fetches in cardFetches
+func newCardServer(t *testing.T, card string, cardFetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path == agentCardPath {
+			cardFetches.Add(1)
+			if card == "" {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, card)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAutoDiscoverFetchesCardOncePerHost(t *testing.T) {
+	var plannerFetches, cardlessFetches atomic.Int32
+	planner := newCardServer(t, `{"name":"Planner","url":"http://planner.example","skills":[]}`, &plannerFetches)
+	cardless := newCardServer(t, "", &cardlessFetches)
+
+	agents := make(chan *store.Agent, 10)
+	p, st, _ := newTestProxy(t, Config{
+		AutoDiscover: true,
+		OnAgent:      func(agent *store.Agent) { agents <- agent },
+	})
+
+	const call = `{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{"id":"task-1"}}`
+	sendJSON(p, planner.URL+"/rpc", call)
+	select {
+	case agent := <-agents:
+		if agent.Name != "Planner" {
+			t.Errorf("discovered %q, want Planner", agent.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent not discovered")
+	}
+
+	for range 3 {
+		sendJSON(p, planner.URL+"/rpc", call)
+		sendJSON(p, cardless.URL+"/rpc", call)
+	}
+
+	// Until the failed fetch has finished
+	deadline := time.Now().Add(5 * time.Second)
+	for cardlessFetches.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if n := plannerFetches.Load(); n != 1 {
+		t.Errorf("planner's card fetched %d times, want once", n)
+	}
+	if n := cardlessFetches.Load(); n != 1 {
+		t.Errorf("missing card fetched %d times, want once within the retry interval", n)
+	}
+	if stored, err := st.GetAgents(); err != nil || len(stored) != 1 {
+		t.Errorf("got %d agents, want 1", len(stored))
+	}
+}
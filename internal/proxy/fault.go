@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// FaultRule injects a fault into matching proxied requests. Rules are written
+// as comma-separated key=value pairs, for example:
+//
+//	method=tasks/send,delay=2s
+//	url=/a2a,status=500,percent=20
+//	method=tasks/get,drop
+//
+// method matches the A2A method exactly and url matches any part of the
+// target URL; both default to any. percent (default 100) is the share of
+// matching requests affected. delay holds the request before forwarding it,
+// status answers with that HTTP status instead of forwarding, and drop closes
+// the client connection without a response.
+type FaultRule struct {
+	Method  string
+	URL     string
+	Percent float64
+	Delay   time.Duration
+	Status  int
+	Drop    bool
+
+	spec string
+}
+
+// ParseFaultRule parses a fault rule
+func ParseFaultRule(spec string) (*FaultRule, error) {
+	rule := &FaultRule{Percent: 100, spec: spec}
+
+	for _, part := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		var err error
+		switch key {
+		case "method":
+			rule.Method = value
+		case "url":
+			rule.URL = value
+		case "percent":
+			rule.Percent, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err == nil && (rule.Percent < 0 || rule.Percent > 100) {
+				err = fmt.Errorf("must be between 0 and 100")
+			}
+		case "delay":
+			rule.Delay, err = time.ParseDuration(value)
+		case "status":
+			rule.Status, err = strconv.Atoi(value)
+			if err == nil && (rule.Status < 100 || rule.Status > 599) {
+				err = fmt.Errorf("not an HTTP status")
+			}
+		case "drop":
+			rule.Drop = true
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("unknown fault option %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+	}
+
+	if rule.Delay == 0 && rule.Status == 0 && !rule.Drop {
+		return nil, fmt.Errorf("fault rule %q has no delay, status or drop", spec)
+	}
+	if rule.Status != 0 && rule.Drop {
+		return nil, fmt.Errorf("fault rule %q can't both return a status and drop", spec)
+	}
+	return rule, nil
+}
+
+// String returns the rule as it was written, used to mark affected messages
+func (r *FaultRule) String() string {
+	return r.spec
+}
+
+// matches reports whether the rule applies to a request
+func (r *FaultRule) matches(method, targetURL string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	return r.URL == "" || strings.Contains(targetURL, r.URL)
+}
+
+// FaultInjector picks the fault, if any, to inject into each request
+type FaultInjector struct {
+	rules []*FaultRule
+
+	mu     sync.Mutex
+	random func() float64 // Returns [0, 1), for percent sampling
+}
+
+// NewFaultInjector creates a FaultInjector applying the first matching rule
+func NewFaultInjector(rules []*FaultRule) *FaultInjector {
+	return &FaultInjector{
+		rules:  rules,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())).Float64,
+	}
+}
+
+// Pick returns the fault to inject into a request, or nil
+func (f *FaultInjector) Pick(method, targetURL string) *FaultRule {
+	for _, rule := range f.rules {
+		if !rule.matches(method, targetURL) {
+			continue
+		}
+
+		f.mu.Lock()
+		roll := f.random() * 100
+		f.mu.Unlock()
+
+		if roll < rule.Percent {
+			return rule
+		}
+		return nil
+	}
+	return nil
+}
+
+// injectFault answers a request with an injected error status or dropped
+// connection instead of forwarding it, recording the outcome as its response
+func (p *Proxy) injectFault(w http.ResponseWriter, reqMsg *store.Message, fault *FaultRule, targetURL string, startTime time.Time) {
+	if reqMsg != nil {
+		faultMsg := &store.Message{
+			TraceID:     reqMsg.TraceID,
+			Timestamp:   p.interceptor.Clock.Now(),
+			Direction:   "response",
+			URL:         targetURL,
+			FromAgent:   reqMsg.ToAgent,
+			StatusCode:  fault.Status,
+			DurationMs:  time.Since(startTime).Milliseconds(),
+			RequestID:   reqMsg.ID,
+			Source:      reqMsg.Source,
+			IngressPort: reqMsg.IngressPort,
+			Fault:       fault.String(),
+		}
+		if fault.Drop {
+			faultMsg.Error = "Connection dropped by injected fault"
+		} else {
+			faultMsg.Error = http.StatusText(fault.Status)
+		}
+		p.saveMessage(faultMsg)
+		if p.onMessage != nil {
+			p.onMessage(faultMsg)
+		}
+	}
+
+	if fault.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		// Aborts the response without logging, e.g. for HTTP/2
+		panic(http.ErrAbortHandler)
+	}
+
+	http.Error(w, "a2a-trace: injected fault", fault.Status)
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustParseFaults parses fault rules, failing the test on an error
+func mustParseFaults(t *testing.T, specs ...string) []*FaultRule {
+	t.Helper()
+	var rules []*FaultRule
+	for _, spec := range specs {
+		rule, err := ParseFaultRule(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func TestFaultInjectsLatency(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	p, st, trace := newTestProxy(t, Config{Faults: mustParseFaults(t, "method=message/send,delay=100ms")})
+
+	start := time.Now()
+	rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d, want the upstream's 200", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("answered after %v, want at least the 100ms delay", elapsed)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 || messages[1].Fault != "method=message/send,delay=100ms" {
+		t.Fatalf("response not marked with the fault: %+v", messages)
+	}
+
+	// Other methods aren't delayed
+	start = time.Now()
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":2,"method":"tasks/get"}`)
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("unmatched request delayed %v", elapsed)
+	}
+}
+
+func TestFaultKeptOnOversizedResponse(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{"text":"`+strings.Repeat("x", 2000)+`"}}`)
+	p, st, trace := newTestProxy(t, Config{
+		Faults:          mustParseFaults(t, "method=message/send,delay=10ms"),
+		MaxResponseSize: 1000,
+	})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	resp := messages[1]
+	if !resp.Truncated || resp.Fault != "method=message/send,delay=10ms" {
+		t.Errorf("truncated %v with fault %q, want the fault kept", resp.Truncated, resp.Fault)
+	}
+	if resp.Timing == "" {
+		t.Error("truncated response has no connection timing")
+	}
+}
+
+func TestFaultInjectsError(t *testing.T) {
+	var forwarded int
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	upstream.Config.Handler = countRequests(upstream.Config.Handler, &forwarded)
+	p, st, trace := newTestProxy(t, Config{Faults: mustParseFaults(t, "url=/rpc,status=503")})
+
+	rec := sendJSON(p, upstream.URL+"/rpc", `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want the injected 503", rec.Code)
+	}
+	if forwarded != 0 {
+		t.Errorf("upstream got %d requests, want none", forwarded)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	resp := messages[1]
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Fault != "url=/rpc,status=503" || resp.RequestID != messages[0].ID {
+		t.Errorf("recorded %d fault %q for %s", resp.StatusCode, resp.Fault, resp.RequestID)
+	}
+}
+
+// countRequests wraps a handler, counting the requests it serves
+func countRequests(next http.Handler, n *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*n++
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestFaultPercentSampling(t *testing.T) {
+	injector := NewFaultInjector(mustParseFaults(t, "status=500,percent=20"))
+	rolls := []float64{0.1, 0.19, 0.2, 0.5}
+	injector.random = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	var picked []bool
+	for range 4 {
+		picked = append(picked, injector.Pick("message/send", "http://agent/rpc") != nil)
+	}
+	if want := []bool{true, true, false, false}; fmt.Sprint(picked) != fmt.Sprint(want) {
+		t.Errorf("picked %v, want %v", picked, want)
+	}
+}
+
+func TestParseFaultRuleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"method=tasks/send",
+		"status=700",
+		"percent=120,drop",
+		"delay=soon",
+		"status=500,drop",
+		"colour=red,drop",
+	} {
+		if _, err := ParseFaultRule(spec); err == nil {
+			t.Errorf("%q parsed without error", spec)
+		}
+	}
+}
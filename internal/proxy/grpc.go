@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// grpcPreviewBytes limits how much of a protobuf body is recorded as hex
+const grpcPreviewBytes = 256
+
+// grpcCodes names the gRPC status codes
+var grpcCodes = []string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded",
+	"NotFound", "AlreadyExists", "PermissionDenied", "ResourceExhausted",
+	"FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented",
+	"Internal", "Unavailable", "DataLoss", "Unauthenticated",
+}
+
+// isGRPC reports whether a content type is gRPC or gRPC-Web
+func isGRPC(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc")
+}
+
+// grpcBodySummary records a length-prefixed protobuf body as its size and a
+// hex preview, since it can't be decoded without the service's schema
+func grpcBodySummary(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	preview := body
+	if len(preview) > grpcPreviewBytes {
+		preview = preview[:grpcPreviewBytes]
+	}
+	summary := fmt.Sprintf("<grpc %d bytes> %s", len(body), hex.EncodeToString(preview))
+	if len(preview) < len(body) {
+		summary += "…"
+	}
+	return summary
+}
+
+// grpcStatus returns the call's grpc-status and grpc-message. They normally
+// arrive as trailers, so resp.Trailer is only populated once the body has been
+// read; trailers-only responses carry them in the headers instead.
+func grpcStatus(resp *http.Response) (code int, message string, ok bool) {
+	raw := resp.Trailer.Get("Grpc-Status")
+	message = resp.Trailer.Get("Grpc-Message")
+	if raw == "" {
+		raw = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if raw == "" {
+		return 0, "", false
+	}
+
+	code, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, "", false
+	}
+	// grpc-message is percent-encoded on the wire
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return code, message, true
+}
+
+// grpcCodeName returns the name of a gRPC status code
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodes) {
+		return grpcCodes[code]
+	}
+	return fmt.Sprintf("Code(%d)", code)
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// grpcFrame returns a length-prefixed, uncompressed gRPC message
+func grpcFrame(payload []byte) []byte {
+	n := len(payload)
+	return append([]byte{0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, payload...)
+}
+
+func TestProxyRecordsGRPCCall(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Write(grpcFrame([]byte{0x0a, 0x02, 't', '1'}))
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "task%20not%20found")
+	}))
+	defer upstream.Close()
+	p, st, trace := newTestProxy(t, Config{})
+
+	body := grpcFrame([]byte{0x0a, 0x02, 't', '1'})
+	req := httptest.NewRequest("POST", upstream.URL+"/a2a.v1.A2AService/GetTask", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	rec := httptest.NewRecorder()
+	p.handleProxy(rec, req)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	request, response := messages[0], messages[1]
+	if request.Transport != "grpc" || request.Method != "/a2a.v1.A2AService/GetTask" {
+		t.Errorf("request: transport %q, method %q", request.Transport, request.Method)
+	}
+	if !strings.HasPrefix(request.Body, "<grpc 9 bytes> 00000000040a027431") {
+		t.Errorf("request body = %q, want a size and hex summary", request.Body)
+	}
+	if response.Transport != "grpc" || response.Error != "gRPC NotFound: task not found" {
+		t.Errorf("response: transport %q, error %q", response.Transport, response.Error)
+	}
+
+	// The client still gets the status trailer
+	if got := rec.Result().Trailer.Get("Grpc-Status"); got != "5" {
+		t.Errorf("relayed grpc-status = %q, want 5", got)
+	}
+}
+
+func TestGRPCCodeName(t *testing.T) {
+	for code, want := range map[int]string{0: "OK", 5: "NotFound", 16: "Unauthenticated", 42: "Code(42)"} {
+		if got := grpcCodeName(code); got != want {
+			t.Errorf("grpcCodeName(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestGzipResponseDecodesToSameJSON(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	for i := 0; i < 20; i++ {
+		msg := &store.Message{ID: fmt.Sprintf("msg-%d", i), TraceID: trace.ID, Direction: "request", Method: "message/send"}
+		if err := st.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plain := serveLocal(p, httptest.NewRequest("GET", "/api/messages", nil))
+
+	req := httptest.NewRequest("GET", "/api/messages", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	compressed := serveLocal(p, req)
+
+	if got := compressed.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := compressed.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if compressed.Body.Len() >= plain.Body.Len() {
+		t.Errorf("compressed body is %d bytes, plain %d", compressed.Body.Len(), plain.Body.Len())
+	}
+
+	gz, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != plain.Body.String() {
+		t.Error("decompressed body differs from the uncompressed response")
+	}
+}
+
+func TestGzipSkipsEventStreams(t *testing.T) {
+	handler := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "data: {}\n\n")
+	}))
+
+	req := httptest.NewRequest("GET", "/api/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "data: {}\n\n" {
+		t.Errorf("event stream was compressed: %q", rec.Body.String())
+	}
+}
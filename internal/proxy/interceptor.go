@@ -2,47 +2,172 @@ package proxy
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/harry-kp/a2a-trace/internal/clock"
 	"github.com/harry-kp/a2a-trace/internal/store"
 )
 
+const (
+	// retryWindow is how long a request is remembered for retry detection
+	retryWindow = time.Minute
+	// maxRecentRequests bounds the retry detection cache
+	maxRecentRequests = 1024
+	// correlationWindow is how long a request waits for a correlated response
+	// before it may be dropped to make room
+	correlationWindow = 10 * time.Minute
+)
+
 // Interceptor parses and classifies A2A protocol messages
-type Interceptor struct{}
+type Interceptor struct {
+	// NewID generates request message ids (default: random UUIDs)
+	NewID func() string
+	// Clock stamps messages and agents (default: the system clock)
+	Clock clock.Clock
+	// CorrelationHeader, when set, names a header such as X-Request-Id that
+	// agents echo on responses. It links responses to requests in preference
+	// to the JSON-RPC id.
+	CorrelationHeader string
+	// MethodLabels names methods for display, adding to and overriding the
+	// built-in labels
+	MethodLabels map[string]string
+	// CaptureMethods, when set, are the HTTP methods of requests recorded,
+	// with or without a body. Requests with other methods are only
+	// forwarded. When unset, any request with a body is recorded.
+	CaptureMethods map[string]bool
+
+	mu      sync.Mutex
+	recent  map[string]recentRequest // Retry key -> first request seen with it
+	pending map[string]recentRequest // Correlation header value -> request awaiting a response
+	tasks   map[string]string        // Task ID -> request message that started it, for push notifications
+}
+
+// recentRequest is an entry in the retry detection cache
+type recentRequest struct {
+	messageID string
+	seen      time.Time
+}
 
 // NewInterceptor creates a new Interceptor instance
 func NewInterceptor() *Interceptor {
-	return &Interceptor{}
+	return &Interceptor{
+		NewID:   func() string { return uuid.New().String() },
+		Clock:   clock.Real{},
+		recent:  make(map[string]recentRequest),
+		pending: make(map[string]recentRequest),
+		tasks:   make(map[string]string),
+	}
+}
+
+// streamingMethods are the A2A methods answered with a Server-Sent Events
+// stream rather than a single JSON-RPC response
+var streamingMethods = map[string]bool{
+	"message/stream":      true,
+	"tasks/sendSubscribe": true,
+	"tasks/resubscribe":   true,
+}
+
+// IsA2ARequest checks if a request should be recorded as an A2A message,
+// given its body. Agent card fetches, CORS preflights and recognizable A2A
+// calls always are. Not all A2A variants POST JSON-RPC, so other requests
+// are recorded when they carry a body or, when CaptureMethods is set, when
+// their HTTP method is one of them.
+func (i *Interceptor) IsA2ARequest(r *http.Request, body []byte) bool {
+	// Checked first, as agent card fetches carry no content type
+	if isAgentCardRequest(r) || isPreflight(r) || isA2ACall(r, body) {
+		return true
+	}
+	if len(i.CaptureMethods) > 0 {
+		return i.CaptureMethods[r.Method]
+	}
+	return len(body) > 0
 }
 
-// IsA2ARequest checks if a request is an A2A protocol request
-func (i *Interceptor) IsA2ARequest(r *http.Request) bool {
-	// GET to /.well-known/agent.json is A2A (check this first, before content-type)
-	if r.Method == "GET" && strings.Contains(r.URL.String(), "/.well-known/agent.json") {
+// isA2ACall reports whether a request is recognizably an A2A call: a
+// JSON-RPC or gRPC POST, or a streaming call, which is known by its Accept
+// header or method whatever HTTP method it's sent with
+func isA2ACall(r *http.Request, body []byte) bool {
+	if r.Method == "POST" && isJSONRPCPost(r) {
 		return true
 	}
+	return acceptsEventStream(r) || isStreamingMethod(body)
+}
+
+// isAgentCardRequest reports whether a request fetches an agent card
+func isAgentCardRequest(r *http.Request) bool {
+	return r.Method == "GET" && strings.Contains(r.URL.String(), "/.well-known/agent.json")
+}
+
+// isJSONRPCPost reports whether a POST carries JSON-RPC or gRPC, which A2A
+// calls are sent as
+func isJSONRPCPost(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.Contains(contentType, "application/json") || isGRPC(contentType)
+}
 
-	// A2A uses POST for JSON-RPC with application/json
-	if r.Method == "POST" {
-		contentType := r.Header.Get("Content-Type")
-		if strings.Contains(contentType, "application/json") {
+// acceptsEventStream reports whether a request asks for a Server-Sent Events
+// response, as streaming calls do whatever content type they're sent with
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "text/event-stream") {
 			return true
 		}
 	}
-
 	return false
 }
 
+// isStreamingMethod reports whether a body is a JSON-RPC call to one of the
+// streaming methods
+func isStreamingMethod(body []byte) bool {
+	var req struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return false
+	}
+	return streamingMethods[req.Method]
+}
+
+// isPreflight reports whether a request is a CORS preflight, which browsers
+// send before cross-origin requests
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// isFormEncoded reports whether a content type is an HTML form's
+func isFormEncoded(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "application/x-www-form-urlencoded")
+}
+
+// ingressPort returns the local port a request came in on, or 0 if it
+// didn't arrive over TCP
+func ingressPort(r *http.Request) int {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
 // ParseRequest parses an HTTP request into an A2A message
 func (i *Interceptor) ParseRequest(r *http.Request, body []byte, traceID string) *store.Message {
 	msg := &store.Message{
+		ID:          i.NewID(), // Assigned up front so retries can refer to it
 		TraceID:     traceID,
-		Timestamp:   time.Now(),
+		Timestamp:   i.Clock.Now(),
 		Direction:   "request",
+		HTTPMethod:  r.Method,
 		URL:         r.URL.String(),
 		ContentType: r.Header.Get("Content-Type"),
 		Size:        int64(len(body)),
@@ -58,37 +183,216 @@ func (i *Interceptor) ParseRequest(r *http.Request, body []byte, traceID string)
 	}
 	headersJSON, _ := json.Marshal(headers)
 	msg.Headers = string(headersJSON)
+	msg.Trailers = trailersJSON(r.Trailer)
 
 	// Extract target agent from URL
 	msg.ToAgent = extractAgentFromURL(r.URL.String())
 
+	// Identify which traced process sent the request, and through which port
+	msg.Source = sourceFromProxyAuth(r)
+	msg.IngressPort = ingressPort(r)
+
+	// Browser clients ask before cross-origin calls; there's no A2A payload
+	if isPreflight(r) {
+		msg.Preflight = true
+		msg.RetryOf = i.recordRequest(msg, body)
+		return msg
+	}
+
+	if key := i.correlationKey(r.Header); key != "" {
+		msg.CorrelationID = key
+		i.recordPending(key, msg)
+	}
+
+	// gRPC names the method in the path; the protobuf body can't be decoded
+	if isGRPC(msg.ContentType) {
+		msg.Transport = "grpc"
+		msg.Method = r.URL.Path
+		msg.MethodLabel = i.methodLabel(msg.Method)
+		msg.Body = grpcBodySummary(body)
+		msg.RetryOf = i.recordRequest(msg, body)
+		return msg
+	}
+
 	// Parse JSON-RPC to extract method
 	var a2aReq store.A2ARequest
 	if err := json.Unmarshal(body, &a2aReq); err == nil {
 		msg.Method = a2aReq.Method
+		msg.IDType = jsonRPCIDType(body)
 		if a2aReq.ID != nil {
 			msg.RequestID = formatRequestID(a2aReq.ID)
+		} else if a2aReq.Method != "" {
+			msg.IsNotification = isNotification(body)
+		}
+	} else if isFormEncoded(msg.ContentType) {
+		// Form-encoded variants carry the JSON-RPC fields as form fields
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			msg.Method = form.Get("method")
+			if form.Has("id") {
+				msg.RequestID = form.Get("id")
+				msg.IDType = "string" // Form values have no other type
+			}
+		}
+	}
+
+	// REST-style agents name the method in the path instead of the body
+	if msg.Method == "" {
+		msg.Method = deriveMethodFromPath(r.URL.Path)
+	}
+	msg.MethodLabel = i.methodLabel(msg.Method)
+
+	// Push notifications for the task will refer back to this request
+	if params, ok := a2aReq.Params.(map[string]interface{}); ok {
+		if strings.HasPrefix(msg.Method, "tasks/") {
+			i.recordTask(stringField(params, "id"), msg.ID)
+		} else if message, ok := params["message"].(map[string]interface{}); ok {
+			i.recordTask(stringField(message, "taskId"), msg.ID)
 		}
 	}
 
+	// Without a correlation header, the JSON-RPC id links the pair
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = msg.RequestID
+	}
+
+	msg.RetryOf = i.recordRequest(msg, body)
+
 	return msg
 }
 
+// recordRequest remembers a request and returns the ID of an earlier request
+// it repeats, or "". A repeat has the same URL and either the same method and
+// JSON-RPC id or the same body, within retryWindow of the original.
+func (i *Interceptor) recordRequest(msg *store.Message, body []byte) string {
+	var keys []string
+	if msg.Method != "" && msg.RequestID != "" {
+		keys = append(keys, "id|"+msg.URL+"|"+msg.Method+"|"+msg.RequestID)
+	}
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		keys = append(keys, "body|"+msg.URL+"|"+hex.EncodeToString(sum[:]))
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := msg.Timestamp
+	if len(i.recent) >= maxRecentRequests {
+		for key, req := range i.recent {
+			if now.Sub(req.seen) > retryWindow {
+				delete(i.recent, key)
+			}
+		}
+	}
+
+	retryOf := ""
+	for _, key := range keys {
+		if req, ok := i.recent[key]; ok && now.Sub(req.seen) <= retryWindow {
+			retryOf = req.messageID
+			break
+		}
+	}
+
+	// Retries keep pointing at the original and extend its window
+	original := msg.ID
+	if retryOf != "" {
+		original = retryOf
+	}
+	for _, key := range keys {
+		if _, ok := i.recent[key]; ok || len(i.recent) < maxRecentRequests {
+			i.recent[key] = recentRequest{messageID: original, seen: now}
+		}
+	}
+	return retryOf
+}
+
+// correlationKey returns the value of the correlation header, or "" when none
+// is configured or present
+func (i *Interceptor) correlationKey(header http.Header) string {
+	if i.CorrelationHeader == "" {
+		return ""
+	}
+	return header.Get(i.CorrelationHeader)
+}
+
+// recordPending remembers a request by its correlation key until its
+// response arrives
+func (i *Interceptor) recordPending(key string, msg *store.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := msg.Timestamp
+	if len(i.pending) >= maxRecentRequests {
+		for k, req := range i.pending {
+			if now.Sub(req.seen) > correlationWindow {
+				delete(i.pending, k)
+			}
+		}
+	}
+	if len(i.pending) < maxRecentRequests {
+		i.pending[key] = recentRequest{messageID: msg.ID, seen: now}
+	}
+}
+
+// takePending returns and forgets the request waiting on a correlation key
+func (i *Interceptor) takePending(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	req, ok := i.pending[key]
+	if !ok {
+		return ""
+	}
+	delete(i.pending, key)
+	return req.messageID
+}
+
+// forgetPending stops waiting on a request once its exchange has completed
+func (i *Interceptor) forgetPending(msg *store.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if req, ok := i.pending[msg.CorrelationID]; ok && req.messageID == msg.ID {
+		delete(i.pending, msg.CorrelationID)
+	}
+}
+
 // ParseResponse parses an HTTP response into an A2A message
 func (i *Interceptor) ParseResponse(resp *http.Response, body []byte, requestMsg *store.Message, duration time.Duration) *store.Message {
 	msg := &store.Message{
-		TraceID:     requestMsg.TraceID,
-		Timestamp:   time.Now(),
-		Direction:   "response",
-		URL:         requestMsg.URL,
-		FromAgent:   requestMsg.ToAgent,
-		StatusCode:  resp.StatusCode,
-		ContentType: resp.Header.Get("Content-Type"),
-		Size:        int64(len(body)),
-		Body:        string(body),
-		DurationMs:  duration.Milliseconds(),
-		RequestID:   requestMsg.RequestID,
+		TraceID:        requestMsg.TraceID,
+		Timestamp:      i.Clock.Now(),
+		Direction:      "response",
+		URL:            requestMsg.URL,
+		FromAgent:      requestMsg.ToAgent,
+		StatusCode:     resp.StatusCode,
+		ContentType:    resp.Header.Get("Content-Type"),
+		Size:           int64(len(body)),
+		Body:           string(body),
+		DurationMs:     duration.Milliseconds(),
+		RequestID:      requestMsg.ID,
+		CorrelationID:  requestMsg.CorrelationID,
+		IsNotification: requestMsg.IsNotification,
+		Source:         requestMsg.Source,
+		IngressPort:    requestMsg.IngressPort,
+		Preflight:      requestMsg.Preflight,
+	}
+
+	// An echoed correlation header names the request this answers
+	if key := i.correlationKey(resp.Header); key != "" {
+		msg.CorrelationID = key
+		if requestID := i.takePending(key); requestID != "" {
+			msg.RequestID = requestID
+		}
 	}
+	i.forgetPending(requestMsg)
 
 	// Parse headers
 	headers := make(map[string]string)
@@ -100,11 +404,32 @@ func (i *Interceptor) ParseResponse(resp *http.Response, body []byte, requestMsg
 	headersJSON, _ := json.Marshal(headers)
 	msg.Headers = string(headersJSON)
 
-	// Parse JSON-RPC response for errors
-	var a2aResp store.A2AResponse
-	if err := json.Unmarshal(body, &a2aResp); err == nil {
-		if a2aResp.Error != nil {
-			msg.Error = a2aResp.Error.Message
+	// Trailers are only populated once the body has been read
+	msg.Trailers = trailersJSON(resp.Trailer)
+	msg.TLSInfo = tlsInfoJSON(resp.TLS)
+
+	if requestMsg.Transport == "grpc" {
+		// gRPC reports failures in the grpc-status trailer, usually with HTTP 200
+		msg.Transport = "grpc"
+		msg.Body = grpcBodySummary(body)
+		if code, message, ok := grpcStatus(resp); ok && code != 0 {
+			msg.Error = "gRPC " + grpcCodeName(code)
+			if message != "" {
+				msg.Error += ": " + message
+			}
+		}
+	} else {
+		// Parse JSON-RPC response for errors
+		var a2aResp store.A2AResponse
+		if err := json.Unmarshal(body, &a2aResp); err == nil {
+			msg.IDType = jsonRPCIDType(body)
+			if a2aResp.Error != nil {
+				msg.Error = a2aResp.Error.Message
+			}
+			// A task created by the request is named in the result
+			if result, ok := a2aResp.Result.(map[string]interface{}); ok {
+				i.recordTask(taskIDOf(result), requestMsg.ID)
+			}
 		}
 	}
 
@@ -116,6 +441,44 @@ func (i *Interceptor) ParseResponse(resp *http.Response, body []byte, requestMsg
 	return msg
 }
 
+// trailersJSON encodes HTTP trailers like headers, or returns "" when there
+// are none
+func trailersJSON(trailer http.Header) string {
+	trailers := make(map[string]string)
+	for key, values := range trailer {
+		if len(values) > 0 && values[0] != "" {
+			trailers[key] = values[0]
+		}
+	}
+	if len(trailers) == 0 {
+		return ""
+	}
+	trailersJSON, _ := json.Marshal(trailers)
+	return string(trailersJSON)
+}
+
+// tlsInfoJSON encodes the TLS connection a response arrived over, or returns
+// "" for plain HTTP. CONNECT tunnels are relayed opaquely, so only requests
+// the proxy sends over HTTPS itself have one.
+func tlsInfoJSON(state *tls.ConnectionState) string {
+	if state == nil {
+		return ""
+	}
+	info := store.TLSConnection{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		info.Subject = leaf.Subject.String()
+		info.Issuer = leaf.Issuer.String()
+		info.NotAfter = leaf.NotAfter
+	}
+	infoJSON, _ := json.Marshal(info)
+	return string(infoJSON)
+}
+
 // ParseAgentCard parses an agent card response
 func (i *Interceptor) ParseAgentCard(body []byte, url string) *store.Agent {
 	var card store.AgentCard
@@ -131,7 +494,7 @@ func (i *Interceptor) ParseAgentCard(body []byte, url string) *store.Agent {
 		Description: card.Description,
 		Version:     card.Version,
 		Skills:      string(skillsJSON),
-		FirstSeen:   time.Now(),
+		FirstSeen:   i.Clock.Now(),
 	}
 }
 
@@ -151,48 +514,126 @@ func (i *Interceptor) ReadBody(body io.ReadCloser) ([]byte, io.ReadCloser, error
 	return data, io.NopCloser(bytes.NewReader(data)), nil
 }
 
+// sourceFromProxyAuth returns the process name a child sends as the proxy
+// username (see process.Manager), or "" when no proxy credentials are present
+func sourceFromProxyAuth(r *http.Request) string {
+	auth := r.Header.Get("Proxy-Authorization")
+	if auth == "" {
+		return ""
+	}
+	// Reuse the Basic auth parser by presenting the header as Authorization
+	req := &http.Request{Header: http.Header{"Authorization": {auth}}}
+	username, _, ok := req.BasicAuth()
+	if !ok {
+		return ""
+	}
+	return username
+}
+
 // extractAgentFromURL extracts the agent identifier from a URL
 func extractAgentFromURL(urlStr string) string {
+	// Agents behind a unix socket are identified by the socket path
+	if socketPath := socketPathFromURL(urlStr); socketPath != "" {
+		return socketPath
+	}
+
 	// Remove protocol and path, keep host
 	urlStr = strings.TrimPrefix(urlStr, "http://")
 	urlStr = strings.TrimPrefix(urlStr, "https://")
-	
+
 	// Get just the host part
 	if idx := strings.Index(urlStr, "/"); idx != -1 {
 		urlStr = urlStr[:idx]
 	}
-	
+
 	return urlStr
 }
 
+// a2aResources and a2aVerbs are the path segments deriveMethodFromPath
+// recognizes, e.g. tasks + cancel in /v1/tasks/{id}:cancel
+var (
+	a2aResources = map[string]bool{"tasks": true, "message": true}
+	a2aVerbs     = map[string]bool{
+		"send": true, "sendSubscribe": true, "stream": true, "get": true, "list": true,
+		"cancel": true, "resubscribe": true, "subscribe": true, "create": true,
+		"pushNotificationConfig": true, "set": true, "delete": true,
+	}
+)
+
+// deriveMethodFromPath maps a REST-style A2A path to its JSON-RPC method name,
+// e.g. /v1/tasks/send -> tasks/send and /v1/message:send -> message/send.
+// Resource ids between the resource and the verb are skipped. It returns ""
+// when the path doesn't name an A2A method.
+func deriveMethodFromPath(path string) string {
+	segments := strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':'
+	})
+
+	for i, segment := range segments {
+		if !a2aResources[segment] {
+			continue
+		}
+
+		method := []string{segment}
+		for _, next := range segments[i+1:] {
+			if a2aVerbs[next] {
+				method = append(method, next)
+			}
+		}
+		if len(method) == 1 {
+			return ""
+		}
+		return strings.Join(method, "/")
+	}
+
+	return ""
+}
+
+// isNotification reports whether a JSON-RPC body omits the id member entirely.
+// An explicit "id": null is still a request, only a missing id marks a notification.
+func isNotification(body []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	_, hasID := fields["id"]
+	return !hasID
+}
+
 // formatRequestID converts the JSON-RPC id to a string
 func formatRequestID(id interface{}) string {
 	switch v := id.(type) {
 	case string:
 		return v
 	case float64:
-		return strings.TrimSuffix(strings.TrimSuffix(
-			strings.Replace(string(rune(int(v))), ".", "", 1), "0"), ".")
+		return strconv.FormatFloat(v, 'f', -1, 64)
 	default:
 		data, _ := json.Marshal(id)
 		return string(data)
 	}
 }
 
-// ClassifyMethod returns a human-readable description of an A2A method
-func ClassifyMethod(method string) string {
-	methodDescriptions := map[string]string{
-		"tasks/create":   "Create Task",
-		"tasks/get":      "Get Task Status",
-		"tasks/cancel":   "Cancel Task",
-		"tasks/send":     "Send Message",
-		"tasks/sendSubscribe": "Send & Subscribe",
-		"tasks/resubscribe":   "Resubscribe to Task",
+// jsonRPCIDType names the JSON type of a JSON-RPC body's id, e.g. "number"
+// or "string", or returns "" if it has none
+func jsonRPCIDType(body []byte) string {
+	var msg struct {
+		ID json.RawMessage `json:"id"`
 	}
-
-	if desc, ok := methodDescriptions[method]; ok {
-		return desc
+	if json.Unmarshal(body, &msg) != nil || len(msg.ID) == 0 {
+		return ""
+	}
+	switch msg.ID[0] {
+	case '"':
+		return "string"
+	case 'n':
+		return "null"
+	case 't', 'f':
+		return "boolean"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	default:
+		return "number"
 	}
-	return method
 }
-
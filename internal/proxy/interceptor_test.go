@@ -0,0 +1,305 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/clock"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestParseRequestNotification(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"without id", `{"jsonrpc":"2.0","method":"tasks/pushNotification","params":{}}`, true},
+		{"with id", `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`, false},
+		{"with null id", `{"jsonrpc":"2.0","id":null,"method":"message/send","params":{}}`, false},
+	}
+
+	interceptor := NewInterceptor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://agent.example/rpc", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			msg := interceptor.ParseRequest(req, []byte(tt.body), "trace")
+			if msg.IsNotification != tt.want {
+				t.Errorf("IsNotification = %v, want %v", msg.IsNotification, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRequestTagsSource(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`
+	req := httptest.NewRequest("POST", "http://agent.example/rpc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Proxy-Authorization", "Basic d29ya2VyOg==") // worker:
+
+	msg := NewInterceptor().ParseRequest(req, []byte(body), "trace")
+	if msg.Source != "worker" {
+		t.Errorf("Source = %q, want worker", msg.Source)
+	}
+}
+
+// parseJSONRequest parses a JSON POST of body to url
+func parseJSONRequest(i *Interceptor, url, body string) *store.Message {
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return i.ParseRequest(req, []byte(body), "trace")
+}
+
+func TestParseRequestMarksRetries(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	interceptor := NewInterceptor()
+	interceptor.Clock = clk
+
+	const send = `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{"text":"hi"}}`
+	original := parseJSONRequest(interceptor, "http://agent.example/rpc", send)
+	if original.RetryOf != "" {
+		t.Fatalf("first request marked as a retry of %s", original.RetryOf)
+	}
+
+	clk.Advance(time.Second)
+	if retry := parseJSONRequest(interceptor, "http://agent.example/rpc", send); retry.RetryOf != original.ID {
+		t.Errorf("identical request: RetryOf = %q, want %q", retry.RetryOf, original.ID)
+	}
+
+	// Same method and id, different params
+	changed := `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{"text":"hello"}}`
+	if retry := parseJSONRequest(interceptor, "http://agent.example/rpc", changed); retry.RetryOf != original.ID {
+		t.Errorf("same method and id: RetryOf = %q, want %q", retry.RetryOf, original.ID)
+	}
+
+	// Another agent, or a new id
+	if fresh := parseJSONRequest(interceptor, "http://other.example/rpc", send); fresh.RetryOf != "" {
+		t.Errorf("request to another agent marked as a retry of %s", fresh.RetryOf)
+	}
+	next := `{"jsonrpc":"2.0","id":2,"method":"message/send","params":{"text":"bye"}}`
+	if fresh := parseJSONRequest(interceptor, "http://agent.example/rpc", next); fresh.RetryOf != "" {
+		t.Errorf("new request marked as a retry of %s", fresh.RetryOf)
+	}
+}
+
+func TestParseRequestRetryWindow(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	interceptor := NewInterceptor()
+	interceptor.Clock = clk
+
+	const send = `{"jsonrpc":"2.0","id":1,"method":"message/send"}`
+	parseJSONRequest(interceptor, "http://agent.example/rpc", send)
+
+	clk.Advance(retryWindow + time.Second)
+	if msg := parseJSONRequest(interceptor, "http://agent.example/rpc", send); msg.RetryOf != "" {
+		t.Errorf("request after the retry window marked as a retry of %s", msg.RetryOf)
+	}
+}
+
+func TestDeriveMethodFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/v1/tasks/send":                        "tasks/send",
+		"/v1/message:send":                      "message/send",
+		"/v1/tasks/abc-123:cancel":              "tasks/cancel",
+		"/tasks/abc/pushNotificationConfig/set": "tasks/pushNotificationConfig/set",
+		"/v1/tasks":                             "",
+		"/rpc":                                  "",
+		"/":                                     "",
+	} {
+		if got := deriveMethodFromPath(path); got != want {
+			t.Errorf("deriveMethodFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseRequestMethod(t *testing.T) {
+	interceptor := NewInterceptor()
+
+	// From the body, which wins over the path
+	msg := parseJSONRequest(interceptor, "http://agent.example/v1/tasks/get", `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if msg.Method != "message/send" {
+		t.Errorf("body method: got %q, want message/send", msg.Method)
+	}
+
+	// From the path, when the body has no method
+	msg = parseJSONRequest(interceptor, "http://agent.example/v1/message:stream", `{"message":{"parts":[]}}`)
+	if msg.Method != "message/stream" {
+		t.Errorf("path method: got %q, want message/stream", msg.Method)
+	}
+}
+
+func TestCorrelationHeaderLinksResponse(t *testing.T) {
+	interceptor := NewInterceptor()
+	interceptor.CorrelationHeader = "X-Request-Id"
+
+	parse := func(requestID, body string) *store.Message {
+		req := httptest.NewRequest("POST", "http://agent.example/rpc", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-Id", requestID)
+		return interceptor.ParseRequest(req, []byte(body), "trace")
+	}
+	first := parse("req-a", `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`)
+	second := parse("req-b", `{"jsonrpc":"2.0","id":2,"method":"tasks/get","params":{}}`)
+	if first.CorrelationID != "req-a" {
+		t.Errorf("CorrelationID = %q, want the header's req-a", first.CorrelationID)
+	}
+
+	// The response echoes the first request's header, but a JSON-RPC id
+	// matching neither, and arrives on the second's connection
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"req-a"}},
+	}
+	msg := interceptor.ParseResponse(resp, []byte(`{"jsonrpc":"2.0","id":99,"result":{}}`), second, time.Millisecond)
+	if msg.RequestID != first.ID || msg.CorrelationID != "req-a" {
+		t.Errorf("response linked to %s (%s), want %s", msg.RequestID, msg.CorrelationID, first.ID)
+	}
+
+	// Without the header, the response answers the request it was sent for
+	resp.Header.Del("X-Request-Id")
+	msg = interceptor.ParseResponse(resp, []byte(`{"jsonrpc":"2.0","id":2,"result":{}}`), second, time.Millisecond)
+	if msg.RequestID != second.ID {
+		t.Errorf("response linked to %s, want %s", msg.RequestID, second.ID)
+	}
+}
+
+func TestParseRequestMarksPreflight(t *testing.T) {
+	interceptor := NewInterceptor()
+
+	req := httptest.NewRequest("OPTIONS", "http://agent.example/rpc", nil)
+	req.Header.Set("Origin", "http://app.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	if !interceptor.IsA2ARequest(req, nil) {
+		t.Fatal("preflight not recorded")
+	}
+	if msg := interceptor.ParseRequest(req, nil, "trace"); !msg.Preflight || msg.Method != "" {
+		t.Errorf("Preflight = %v, method %q; want a preflight without a method", msg.Preflight, msg.Method)
+	}
+
+	// A plain OPTIONS request isn't a preflight
+	req = httptest.NewRequest("OPTIONS", "http://agent.example/rpc", nil)
+	if msg := interceptor.ParseRequest(req, nil, "trace"); msg.Preflight {
+		t.Error("OPTIONS without Access-Control-Request-Method marked as a preflight")
+	}
+}
+
+func TestIsA2ACall(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		accept      string
+		body        string
+		want        bool
+	}{
+		{"JSON-RPC POST", "POST", "application/json", "", `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`, true},
+		{"gRPC POST", "POST", "application/grpc", "", "", true},
+		{"stream by Accept", "GET", "", "application/json, text/event-stream", "", true},
+		{"stream by method", "POST", "text/plain", "", `{"jsonrpc":"2.0","id":1,"method":"message/stream"}`, true},
+		{"resubscribe", "PUT", "", "", `{"jsonrpc":"2.0","id":1,"method":"tasks/resubscribe"}`, true},
+		{"plain text POST", "POST", "text/plain", "", `{"method":"tasks/get"}`, false},
+		{"form POST", "POST", "application/x-www-form-urlencoded", "", "q=1", false},
+		{"JSON GET", "GET", "application/json", "application/json", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://agent.example/rpc", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := isA2ACall(req, []byte(tt.body)); got != tt.want {
+				t.Errorf("isA2ACall = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsA2ARequestWithCaptureMethods(t *testing.T) {
+	interceptor := NewInterceptor()
+	interceptor.CaptureMethods = map[string]bool{"GET": true}
+
+	// A streaming call is recorded whatever CaptureMethods says
+	req := httptest.NewRequest("POST", "http://agent.example/rpc", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Accept", "text/event-stream")
+	if !interceptor.IsA2ARequest(req, []byte(`{}`)) {
+		t.Error("streaming request not recorded")
+	}
+
+	// Other calls only when their HTTP method is captured
+	req = httptest.NewRequest("POST", "http://agent.example/upload", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	if interceptor.IsA2ARequest(req, []byte("hello")) {
+		t.Error("plain text POST recorded without POST in CaptureMethods")
+	}
+	if !interceptor.IsA2ARequest(httptest.NewRequest("GET", "http://agent.example/tasks", nil), nil) {
+		t.Error("GET not recorded with GET in CaptureMethods")
+	}
+}
+
+func TestJSONRPCIDType(t *testing.T) {
+	tests := map[string]string{
+		`{"jsonrpc":"2.0","id":7,"method":"tasks/get"}`:       "number",
+		`{"jsonrpc":"2.0","id":"7","method":"tasks/get"}`:     "string",
+		`{"jsonrpc":"2.0","id":null,"error":{}}`:              "null",
+		`{"jsonrpc":"2.0","id":-1.5,"result":{}}`:             "number",
+		`{"jsonrpc":"2.0","method":"tasks/pushNotification"}`: "",
+		`not json`: "",
+	}
+	for body, want := range tests {
+		if got := jsonRPCIDType([]byte(body)); got != want {
+			t.Errorf("jsonRPCIDType(%s) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestCaptureMethodsRecordOtherVariants(t *testing.T) {
+	interceptor := NewInterceptor()
+	interceptor.CaptureMethods = map[string]bool{"PUT": true, "PATCH": true}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantMethod  string
+		wantID      string
+		wantIDType  string
+	}{
+		{"PUT with JSON-RPC", "PUT", "application/json", `{"jsonrpc":"2.0","id":5,"method":"tasks/send","params":{}}`, "tasks/send", "5", "number"},
+		{"PATCH with a form", "PATCH", "application/x-www-form-urlencoded", "method=tasks%2Fcancel&id=7", "tasks/cancel", "7", "string"},
+		{"PUT with plain JSON", "PUT", "application/json", `{"reason":"done"}`, "tasks/cancel", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://agent.example/v1/tasks/t1:cancel", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if !interceptor.IsA2ARequest(req, []byte(tt.body)) {
+				t.Fatal("not recorded")
+			}
+
+			msg := interceptor.ParseRequest(req, []byte(tt.body), "trace")
+			if msg.HTTPMethod != tt.method || msg.Method != tt.wantMethod {
+				t.Errorf("recorded %s %q, want %s %q", msg.HTTPMethod, msg.Method, tt.method, tt.wantMethod)
+			}
+			if msg.RequestID != tt.wantID || msg.IDType != tt.wantIDType {
+				t.Errorf("id %q of type %q, want %q of type %q", msg.RequestID, msg.IDType, tt.wantID, tt.wantIDType)
+			}
+		})
+	}
+
+	// Methods not listed aren't, unless recognizably A2A
+	req := httptest.NewRequest("DELETE", "http://agent.example/tasks/1", strings.NewReader("x"))
+	if interceptor.IsA2ARequest(req, []byte("x")) {
+		t.Error("DELETE recorded")
+	}
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// defaultMethodLabels are the built-in display names of A2A methods, for both
+// current and older protocol versions
+var defaultMethodLabels = map[string]string{
+	"message/send":                        "Send Message",
+	"message/stream":                      "Send & Stream",
+	"tasks/create":                        "Create Task",
+	"tasks/get":                           "Get Task Status",
+	"tasks/cancel":                        "Cancel Task",
+	"tasks/send":                          "Send Message",
+	"tasks/sendSubscribe":                 "Send & Subscribe",
+	"tasks/resubscribe":                   "Resubscribe to Task",
+	"tasks/pushNotification/set":          "Set Push Notifications",
+	"tasks/pushNotification/get":          "Get Push Notifications",
+	"tasks/pushNotificationConfig/set":    "Set Push Notifications",
+	"tasks/pushNotificationConfig/get":    "Get Push Notifications",
+	"tasks/pushNotificationConfig/list":   "List Push Notifications",
+	"tasks/pushNotificationConfig/delete": "Delete Push Notifications",
+	"agent/getAuthenticatedExtendedCard":  "Get Extended Agent Card",
+}
+
+// ClassifyMethod returns a human-readable description of an A2A method
+func ClassifyMethod(method string) string {
+	if desc, ok := defaultMethodLabels[method]; ok {
+		return desc
+	}
+	return method
+}
+
+// LoadMethodLabels reads display names for methods from a JSON file mapping
+// method names to labels, e.g. {"tasks/send": "Delegate"}
+func LoadMethodLabels(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read method labels: %w", err)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse method labels: %w", err)
+	}
+	return labels, nil
+}
+
+// methodLabel returns the display name of a method, from MethodLabels or the
+// built-in labels, or "" if it has none
+func (i *Interceptor) methodLabel(method string) string {
+	if label, ok := i.MethodLabels[method]; ok {
+		return label
+	}
+	return defaultMethodLabels[method]
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMethodLabelsOverrideDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	if err := os.WriteFile(path, []byte(`{"tasks/send":"Delegate","tasks/plan":"Plan Work"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := LoadMethodLabels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interceptor := NewInterceptor()
+	interceptor.MethodLabels = labels
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{"tasks/send", "Delegate"},
+		{"tasks/plan", "Plan Work"},
+		{"tasks/get", "Get Task Status"},
+		{"tasks/unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + tt.method + `","params":{}}`
+			req := httptest.NewRequest("POST", "http://agent.example/rpc", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			msg := interceptor.ParseRequest(req, []byte(body), "trace")
+			if msg.MethodLabel != tt.want {
+				t.Errorf("MethodLabel = %q, want %q", msg.MethodLabel, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMethodLabelsErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`["tasks/send"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing.json"), invalid} {
+		if _, err := LoadMethodLabels(path); err == nil {
+			t.Errorf("loading %s succeeded", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// MockServer replays recorded responses from an exported trace, acting as a
+// fake upstream. Requests are matched on A2A method and URL path; repeated
+// requests receive the recorded responses in order, then the last one again.
+type MockServer struct {
+	server    *http.Server
+	port      int
+	mu        sync.Mutex
+	responses map[string][]*store.Message
+	next      map[string]int
+}
+
+// mockExport is the subset of an exported trace the mock server needs
+type mockExport struct {
+	Messages []*store.Message `json:"messages"`
+}
+
+// NewMockServer creates a MockServer from exported trace JSON
+func NewMockServer(export []byte, port int) (*MockServer, error) {
+	var data mockExport
+	if err := json.Unmarshal(export, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse trace export: %w", err)
+	}
+
+	m := &MockServer{
+		port:      port,
+		responses: make(map[string][]*store.Message),
+		next:      make(map[string]int),
+	}
+
+	requests := make(map[string]*store.Message)
+	pending := make(map[string][]*store.Message) // URL -> requests without a response yet
+	for _, msg := range data.Messages {
+		switch msg.Direction {
+		case "request":
+			requests[msg.ID] = msg
+			pending[msg.URL] = append(pending[msg.URL], msg)
+		case "response":
+			req, ok := requests[msg.RequestID]
+			if !ok && len(pending[msg.URL]) > 0 {
+				// Older exports link responses by JSON-RPC id; fall back to arrival order
+				req = pending[msg.URL][0]
+			}
+			if req == nil {
+				continue
+			}
+			pending[msg.URL] = removeMessage(pending[msg.URL], req)
+
+			// Transport errors never produced a response worth replaying
+			if msg.StatusCode == 0 {
+				continue
+			}
+			// Exports inline offloaded bodies, but older ones point at the
+			// blob store
+			body, err := store.LoadBody(msg)
+			if err != nil {
+				return nil, fmt.Errorf("response %s body is no longer available: %w", msg.ID, err)
+			}
+			msg.Body, msg.BodyPath = body, ""
+			key := mockKey(req.Method, req.URL)
+			m.responses[key] = append(m.responses[key], msg)
+		}
+	}
+
+	if len(m.responses) == 0 {
+		return nil, fmt.Errorf("trace contains no recorded responses")
+	}
+
+	return m, nil
+}
+
+// Start starts the mock server
+func (m *MockServer) Start() error {
+	m.server = &http.Server{
+		Addr:         fmt.Sprintf(":%d", m.port),
+		Handler:      m,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+
+	log.Printf("🎭 A2A Trace mock server starting on port %d (%d recorded endpoints)", m.port, len(m.responses))
+	return m.server.ListenAndServe()
+}
+
+// Stop gracefully stops the mock server
+func (m *MockServer) Stop() error {
+	if m.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.server.Shutdown(ctx)
+}
+
+// ServeHTTP replays the recorded response matching the request
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var a2aReq store.A2ARequest
+	_ = json.Unmarshal(body, &a2aReq)
+
+	key := mockKey(a2aReq.Method, r.URL.String())
+	resp := m.nextResponse(key)
+	if resp == nil {
+		http.Error(w, fmt.Sprintf("a2a-trace mock: no recorded response for %s", key), http.StatusNotImplemented)
+		return
+	}
+
+	var headers map[string]string
+	_ = json.Unmarshal([]byte(resp.Headers), &headers)
+	for k, v := range headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		w.Header().Set(k, v)
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	w.Write(withRequestID([]byte(resp.Body), a2aReq.ID))
+}
+
+// nextResponse returns the next recorded response for a key
+func (m *MockServer) nextResponse(key string) *store.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	responses := m.responses[key]
+	if len(responses) == 0 {
+		return nil
+	}
+
+	i := m.next[key]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	} else {
+		m.next[key] = i + 1
+	}
+	return responses[i]
+}
+
+// mockKey identifies a recorded exchange by A2A method and URL path
+func mockKey(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.RequestURI()
+	}
+	if method == "" {
+		return path
+	}
+	return method + " " + path
+}
+
+// withRequestID rewrites a JSON-RPC response id to echo the live request's id
+func withRequestID(body []byte, id interface{}) []byte {
+	if id == nil {
+		return body
+	}
+
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return body
+	}
+	if _, ok := resp["id"]; !ok {
+		return body
+	}
+
+	rawID, err := json.Marshal(id)
+	if err != nil {
+		return body
+	}
+	resp["id"] = rawID
+
+	rewritten, err := json.Marshal(resp)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+func removeMessage(messages []*store.Message, target *store.Message) []*store.Message {
+	for i, msg := range messages {
+		if msg == target {
+			return append(messages[:i], messages[i+1:]...)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// newTestMockServer returns a MockServer replaying an export of messages
+func newTestMockServer(t *testing.T, messages ...*store.Message) *MockServer {
+	t.Helper()
+	export, err := json.Marshal(map[string]interface{}{"messages": messages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMockServer(export, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMockServerReplaysRecordedResponse(t *testing.T) {
+	m := newTestMockServer(t,
+		&store.Message{ID: "req-1", Direction: "request", Method: "message/send", URL: "http://agent.example/rpc"},
+		&store.Message{
+			ID:         "resp-1",
+			Direction:  "response",
+			RequestID:  "req-1",
+			URL:        "http://agent.example/rpc",
+			StatusCode: 200,
+			Headers:    `{"Content-Type":"application/json","Content-Length":"99"}`,
+			Body:       `{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"t1"}}`,
+		},
+	)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":"live-7","method":"message/send"}`))
+	m.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var resp struct {
+		ID     string                 `json:"id"`
+		Result map[string]interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "live-7" {
+		t.Errorf("id = %q, want the live request's id", resp.ID)
+	}
+	if resp.Result["id"] != "t1" {
+		t.Errorf("result = %v, want the recorded task", resp.Result)
+	}
+}
+
+func TestMockServerRepliesInOrderThenRepeatsLast(t *testing.T) {
+	var messages []*store.Message
+	for i, state := range []string{"working", "completed"} {
+		id := string(rune('a' + i))
+		messages = append(messages,
+			&store.Message{ID: id, Direction: "request", Method: "tasks/get", URL: "http://agent.example/"},
+			&store.Message{Direction: "response", RequestID: id, StatusCode: 200, Body: state},
+		)
+	}
+	m := newTestMockServer(t, messages...)
+
+	for _, want := range []string{"working", "completed", "completed"} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"method":"tasks/get"}`)))
+		if body, _ := io.ReadAll(rec.Body); string(body) != want {
+			t.Errorf("got %q, want %q", body, want)
+		}
+	}
+}
+
+func TestMockServerUnmatchedRequest(t *testing.T) {
+	m := newTestMockServer(t,
+		&store.Message{ID: "req-1", Direction: "request", Method: "message/send", URL: "http://agent.example/rpc"},
+		&store.Message{Direction: "response", RequestID: "req-1", StatusCode: 200, Body: "{}"},
+	)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"method":"tasks/cancel"}`)))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rec.Code)
+	}
+}
+
+func TestMockServerLoadsOffloadedBody(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"t1"}}`
+	blobs, err := store.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := blobs.Put(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &store.Message{ID: "req-1", Direction: "request", Method: "message/send", URL: "http://agent.example/rpc"}
+	response := &store.Message{ID: "resp-1", Direction: "response", RequestID: "req-1", URL: "http://agent.example/rpc", StatusCode: 200, BodyPath: path}
+	m := newTestMockServer(t, request, response)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send"}`)))
+	if !strings.Contains(rec.Body.String(), `"id":"t1"`) {
+		t.Errorf("replied %q, want the offloaded body", rec.Body)
+	}
+
+	response.BodyPath = path + ".missing"
+	export, _ := json.Marshal(map[string]interface{}{"messages": []*store.Message{request, response}})
+	if _, err := NewMockServer(export, 0); err == nil {
+		t.Error("accepted an export whose response body is missing")
+	}
+}
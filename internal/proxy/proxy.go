@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,15 +13,38 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/harry-kp/a2a-trace/internal/clock"
 	"github.com/harry-kp/a2a-trace/internal/store"
 )
 
+// viaToken identifies a2a-trace in the Via header of forwarded requests
+const viaToken = "a2a-trace"
+
 // MessageHandler is called when a message is intercepted
 type MessageHandler func(msg *store.Message)
 
+// AnnotationHandler is called when an annotation is added via the API
+type AnnotationHandler func(annotation *store.Annotation)
+
+// InsightHandler is called when an insight is acknowledged via the API
+type InsightHandler func(insight *store.Insight)
+
+// PauseHandler is called when recording is paused or resumed via the API
+type PauseHandler func(paused bool)
+
+// TraceHandler is called when a new trace becomes the active one
+type TraceHandler func(trace *store.Trace)
+
+// ThrottleHandler is called when a request had to wait for, or was refused,
+// a slot under the concurrency limit
+type ThrottleHandler func(targetURL string, waited time.Duration, rejected bool)
+
 // AgentHandler is called when an agent is discovered
 type AgentHandler func(agent *store.Agent)
 
@@ -29,40 +53,134 @@ type SummaryProvider interface {
 	GetSummary() map[string]interface{}
 }
 
+// HealthProvider scores agents for /api/agents
+type HealthProvider interface {
+	AgentHealth(agentURL string) (*store.AgentHealth, error)
+}
+
 // InsightsProvider provides insights data
 type InsightsProvider interface {
 	GetInsights(traceID string) ([]*store.Insight, error)
 }
 
+// ClientCounter reports the number of connected WebSocket clients
+type ClientCounter interface {
+	ClientCount() int
+}
+
+// ProcessMonitor reports whether the traced process is still running
+type ProcessMonitor interface {
+	IsRunning() bool
+}
+
 // Proxy is an HTTP proxy that intercepts A2A traffic
 type Proxy struct {
-	server          *http.Server
-	interceptor     *Interceptor
-	store           *store.Store
-	traceID         string
-	port            int
-	onMessage       MessageHandler
-	onAgent         AgentHandler
-	client          *http.Client
-	wsHandler       http.HandlerFunc
-	uiHandler       http.Handler
-	summaryProvider SummaryProvider
+	server            *http.Server
+	serverMu          sync.Mutex
+	interceptor       *Interceptor
+	inflight          map[string]*inflightRequest // Requests awaiting a response by message ID, guarded by inflightMu
+	agentAddrs        map[string][]net.IP         // Resolved addresses of agent hosts, guarded by inflightMu
+	inflightMu        sync.Mutex
+	store             *store.Store
+	traceID           string // Active trace, guarded by traceMu
+	traceMu           sync.RWMutex
+	onTrace           TraceHandler
+	onAnnotation      AnnotationHandler
+	onInsightAck      InsightHandler
+	onPause           PauseHandler
+	paused            atomic.Bool // Forward without recording while set
+	sinks             []store.MessageSink
+	port              int
+	extraPorts        []int
+	onMessage         MessageHandler
+	onAgent           AgentHandler
+	client            *http.Client
+	wsHandler         http.HandlerFunc
+	streamHandler     http.HandlerFunc
+	uiHandler         http.Handler
+	summaryProvider   SummaryProvider
+	healthProvider    HealthProvider
+	local             http.Handler
+	localOnce         sync.Once
+	clients           ClientCounter
+	process           ProcessMonitor
+	processMu         sync.Mutex
+	startedAt         time.Time
+	slots             chan struct{} // Concurrency limit semaphore, nil when unlimited
+	rejectOverLimit   bool
+	onThrottle        ThrottleHandler
+	unixSocket        string
+	noFollowRedirects bool
+	faults            *FaultInjector // nil when no faults are configured
+	debug             bool
+	blobs             *store.BlobStore
+	blobThreshold     int64
+	webhookPort       int
+	webhookServer     *http.Server // Guarded by serverMu
+	maxResponseSize   int64
+	upstreamAuth      map[string]*UpstreamAuth // Keyed by lowercased host or host:port
+	readOnly          bool
+	autoDiscover      bool
+	transparent       bool
+	discovered        map[string]*hostDiscovery // Auto-discovery state by lowercased host, guarded by discoverMu
+	discoverMu        sync.Mutex
 }
 
 // Config holds proxy configuration
 type Config struct {
 	Port            int
+	ExtraPorts      []int // Also listen on these ports; messages record the port they came in on
 	Store           *store.Store
 	TraceID         string
 	OnMessage       MessageHandler
 	OnAgent         AgentHandler
-	WSHandler       http.HandlerFunc  // WebSocket handler
-	UIHandler       http.Handler      // UI file server
-	SummaryProvider SummaryProvider   // For /api/summary
+	OnTrace         TraceHandler        // Called when POST /api/traces starts a trace
+	OnAnnotation    AnnotationHandler   // Called when POST /api/annotations adds a marker
+	OnInsightAck    InsightHandler      // Called when POST /api/insights/{id}/ack acknowledges an insight
+	OnPause         PauseHandler        // Called when POST /api/control pauses or resumes recording
+	Sinks           []store.MessageSink // Also receive every message and agent saved to Store
+	WSHandler       http.HandlerFunc    // WebSocket handler
+	StreamHandler   http.HandlerFunc    // Server-Sent Events tail of the trace, for /api/stream
+	UIHandler       http.Handler        // UI file server
+	SummaryProvider SummaryProvider     // For /api/summary
+	HealthProvider  HealthProvider      // For agent health in /api/agents
+	Clients         ClientCounter       // For /health
+	MaxConcurrency  int                 // Limit on simultaneous proxied requests (0: unlimited)
+	RejectOverLimit bool                // Return 503 instead of queuing over MaxConcurrency
+	OnThrottle      ThrottleHandler
+	UnixSocket      string      // Also listen on this unix socket
+	Clock           clock.Clock // Stamps captured messages (default: the store's clock)
+	TLSConfig       *tls.Config // For HTTPS upstreams (default: system roots, no client certificate)
+
+	CorrelationHeader string       // Header echoed on responses that links them to requests, e.g. X-Request-Id
+	CaptureMethods    []string     // HTTP methods recorded even without a body; others only if recognizably A2A (empty: any request with a body)
+	NoFollowRedirects bool         // Pass 3xx responses back to the client instead of following them
+	Faults            []*FaultRule // Faults to inject into matching requests, see ParseFaultRule
+	Debug             bool         // Serve /api/debug/runtime
+	ReadOnly          bool         // Refuse API requests that change state, see ReadOnlyHandler
+
+	Blobs         *store.BlobStore // Holds bodies over BlobThreshold instead of the database (nil: keep all in the database)
+	BlobThreshold int64            // Size in bytes above which a body goes to Blobs
+
+	WebhookPort int // Also listen on this port for push notifications from agents (0: off)
+
+	UpstreamAuth []*UpstreamAuth // Basic credentials added to requests for matching hosts
+
+	MethodLabels map[string]string // Display names of methods, over the built-in ones, see LoadMethodLabels
+
+	AutoDiscover bool // Fetch the agent card of each new host seen in traffic
+	Transparent  bool // Accept connections redirected by iptables and forward them to their original destination (Linux only)
+
+	MaxResponseSize int64 // Response bodies are buffered up to this many bytes; larger ones are streamed and recorded truncated (0: unlimited)
 }
 
 // New creates a new Proxy instance
 func New(cfg Config) *Proxy {
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
 	// Create HTTP client with custom transport
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -70,107 +188,376 @@ func New(cfg Config) *Proxy {
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		TLSClientConfig:       &tls.Config{InsecureSkipVerify: false},
+		TLSClientConfig:       tlsConfig,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     true, // gRPC upstreams require HTTP/2
+	}
+	transport.RegisterProtocol("unix", newUnixTransport())
+
+	var slots chan struct{}
+	if cfg.MaxConcurrency > 0 {
+		slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+
+	interceptor := NewInterceptor()
+	if dataStore := cfg.Store; dataStore != nil {
+		// Request ids come from the same generator as the rest of the store
+		interceptor.NewID = func() string { return dataStore.NewID() }
+		interceptor.Clock = dataStore.Clock
+	}
+	if cfg.Clock != nil {
+		interceptor.Clock = cfg.Clock
+	}
+	interceptor.CorrelationHeader = cfg.CorrelationHeader
+	interceptor.MethodLabels = cfg.MethodLabels
+	if len(cfg.CaptureMethods) > 0 {
+		interceptor.CaptureMethods = make(map[string]bool)
+		for _, method := range cfg.CaptureMethods {
+			interceptor.CaptureMethods[strings.ToUpper(method)] = true
+		}
 	}
 
-	return &Proxy{
-		interceptor:     NewInterceptor(),
-		store:           cfg.Store,
-		traceID:         cfg.TraceID,
-		port:            cfg.Port,
-		onMessage:       cfg.OnMessage,
-		onAgent:         cfg.OnAgent,
-		wsHandler:       cfg.WSHandler,
-		uiHandler:       cfg.UIHandler,
-		summaryProvider: cfg.SummaryProvider,
+	p := &Proxy{
+		interceptor:       interceptor,
+		inflight:          make(map[string]*inflightRequest),
+		agentAddrs:        make(map[string][]net.IP),
+		store:             cfg.Store,
+		traceID:           cfg.TraceID,
+		port:              cfg.Port,
+		extraPorts:        cfg.ExtraPorts,
+		onMessage:         cfg.OnMessage,
+		onAgent:           cfg.OnAgent,
+		onTrace:           cfg.OnTrace,
+		onAnnotation:      cfg.OnAnnotation,
+		onInsightAck:      cfg.OnInsightAck,
+		onPause:           cfg.OnPause,
+		sinks:             cfg.Sinks,
+		wsHandler:         cfg.WSHandler,
+		streamHandler:     cfg.StreamHandler,
+		uiHandler:         cfg.UIHandler,
+		summaryProvider:   cfg.SummaryProvider,
+		healthProvider:    cfg.HealthProvider,
+		clients:           cfg.Clients,
+		startedAt:         time.Now(),
+		slots:             slots,
+		rejectOverLimit:   cfg.RejectOverLimit,
+		onThrottle:        cfg.OnThrottle,
+		unixSocket:        cfg.UnixSocket,
+		noFollowRedirects: cfg.NoFollowRedirects,
+		debug:             cfg.Debug,
+		blobs:             cfg.Blobs,
+		blobThreshold:     cfg.BlobThreshold,
+		webhookPort:       cfg.WebhookPort,
+		maxResponseSize:   cfg.MaxResponseSize,
+		readOnly:          cfg.ReadOnly,
+		autoDiscover:      cfg.AutoDiscover,
+		transparent:       cfg.Transparent,
+		discovered:        make(map[string]*hostDiscovery),
 		client: &http.Client{
 			Transport: transport,
 			Timeout:   60 * time.Second,
 		},
 	}
+	p.client.CheckRedirect = p.checkRedirect
+	if len(cfg.Faults) > 0 {
+		p.faults = NewFaultInjector(cfg.Faults)
+	}
+	if len(cfg.UpstreamAuth) > 0 {
+		p.upstreamAuth = make(map[string]*UpstreamAuth, len(cfg.UpstreamAuth))
+		for _, auth := range cfg.UpstreamAuth {
+			p.upstreamAuth[auth.Host] = auth
+		}
+	}
+	return p
 }
 
-// Start starts the proxy server
+// Start starts the proxy server on the configured port
 func (p *Proxy) Start() error {
-	mux := http.NewServeMux()
-	
-	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// Every port feeds the same store and trace, and Stop closes them all
+	var lns []net.Listener
+	closeAll := func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}
+	for _, port := range append([]int{p.port}, p.extraPorts...) {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			closeAll()
+			return err
+		}
+		if p.transparent {
+			ln = transparentListener{ln}
+		}
+		lns = append(lns, ln)
+	}
 
-	// API endpoints for UI
-	mux.HandleFunc("/api/messages", p.handleGetMessages)
-	mux.HandleFunc("/api/agents", p.handleGetAgents)
-	mux.HandleFunc("/api/trace", p.handleGetTrace)
-	mux.HandleFunc("/api/export", p.handleExport)
-	mux.HandleFunc("/api/insights", p.handleGetInsights)
-	mux.HandleFunc("/api/summary", p.handleGetSummary)
+	if p.unixSocket != "" {
+		unixLn, err := listenUnix(p.unixSocket)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		go func() {
+			if err := p.Serve(unixLn); err != nil && err != http.ErrServerClosed {
+				log.Printf("Unix socket server error: %v", err)
+			}
+		}()
+		log.Printf("🔍 A2A Trace proxy listening on %s", p.unixSocket)
+	}
 
-	// WebSocket handler
-	if p.wsHandler != nil {
-		mux.HandleFunc("/ws", p.wsHandler)
+	if p.webhookPort != 0 {
+		webhookLn, err := net.Listen("tcp", fmt.Sprintf(":%d", p.webhookPort))
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("webhook capture: %w", err)
+		}
+		p.serveWebhook(webhookLn)
 	}
 
-	// UI handler
-	if p.uiHandler != nil {
-		mux.Handle("/ui/", http.StripPrefix("/ui/", p.uiHandler))
-		mux.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
-			http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
-		})
+	for i, ln := range lns[1:] {
+		port := p.extraPorts[i]
+		go func(ln net.Listener) {
+			if err := p.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Printf("Proxy server error on port %d: %v", port, err)
+			}
+		}(ln)
+		log.Printf("🔍 A2A Trace proxy also listening on port %d", port)
 	}
 
-	// Create combined handler - serve known routes via mux, proxy everything else
+	log.Printf("🔍 A2A Trace proxy starting on port %d", p.port)
+	return p.Serve(lns[0])
+}
+
+// Serve serves the proxy on an existing listener
+func (p *Proxy) Serve(ln net.Listener) error {
+	local := p.LocalHandler()
+
+	// Create combined handler - serve known routes locally, proxy everything else
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if this is a proxy request (has absolute URL with host)
-		if r.URL.Host != "" {
+		// Check if this is a proxy request (has absolute URL with host, or
+		// names an upstream unix socket, or was redirected to us)
+		if r.URL.Host != "" || r.URL.Scheme == "unix" || originalDstOf(r) != "" {
 			// This is a proxy request - forward it
 			p.handleProxy(w, r)
 			return
 		}
-		
+
 		// For local requests, check known paths
-		path := r.URL.Path
-		switch {
-		case path == "/health",
-		     strings.HasPrefix(path, "/api/"),
-		     path == "/ws",
-		     strings.HasPrefix(path, "/ui"):
-			mux.ServeHTTP(w, r)
-		default:
-			// Unknown local path - could be a misconfigured proxy request
-			// Try to proxy it using Host header
-			p.handleProxy(w, r)
+		if isLocalPath(r.URL.Path) {
+			local.ServeHTTP(w, r)
+			return
 		}
+
+		// Unknown local path - could be a misconfigured proxy request
+		// Try to proxy it using Host header
+		p.handleProxy(w, r)
 	})
 
-	p.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", p.port),
-		Handler:      handler,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	// One server handles every listener, so Stop shuts them all down
+	p.serverMu.Lock()
+	if p.server == nil {
+		p.server = &http.Server{
+			Addr:         ln.Addr().String(),
+			Handler:      handler,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout:  120 * time.Second,
+			ConnContext:  withOriginalDst,
+		}
 	}
+	server := p.server
+	p.serverMu.Unlock()
 
-	log.Printf("🔍 A2A Trace proxy starting on port %d", p.port)
-	return p.server.ListenAndServe()
+	return server.Serve(ln)
+}
+
+// LocalHandler returns the handler for a2a-trace's own endpoints (/health,
+// /api/*, /ws and /ui). The proxy serves it on its own port, and a separate
+// UI server can mount it to expose the same routes on another port.
+func (p *Proxy) LocalHandler() http.Handler {
+	p.localOnce.Do(func() {
+		mux := http.NewServeMux()
+
+		// Health check endpoint
+		mux.HandleFunc("/health", p.Healthz)
+
+		// API endpoints for UI
+		mux.HandleFunc("/api/messages", p.handleGetMessages)
+		mux.HandleFunc("/api/messages/{id}/artifacts", p.handleGetArtifacts)
+		mux.HandleFunc("/api/messages/{id}/body", p.handleGetBody)
+		mux.HandleFunc("/api/messages/{id}/children", p.handleGetChildren)
+		mux.HandleFunc("/api/replay", p.handleReplay)
+		mux.HandleFunc("/api/agents", p.handleGetAgents)
+		mux.HandleFunc("/api/trace", p.handleGetTrace)
+		mux.HandleFunc("/api/traces", p.handleTraces)
+		mux.HandleFunc("/api/annotations", p.handleAnnotations)
+		mux.HandleFunc("/api/control", p.handleControl)
+		mux.HandleFunc("/api/export", p.handleExport)
+		mux.HandleFunc("/api/insights", p.handleGetInsights)
+		mux.HandleFunc("/api/insights/{id}/ack", p.handleAckInsight)
+		mux.HandleFunc("/api/summary", p.handleGetSummary)
+		mux.HandleFunc("/api/graph", p.handleGetGraph)
+		mux.HandleFunc("/api/topology", p.handleGetTopology)
+		mux.HandleFunc("/api/schema", p.handleGetSchema)
+		mux.HandleFunc("/api/skills", p.handleGetSkills)
+		mux.HandleFunc("/api/threads/{taskId}/export", p.handleExportThread)
+		mux.HandleFunc("/api/timeseries", p.handleGetTimeSeries)
+		if p.debug {
+			mux.HandleFunc("/api/debug/runtime", p.handleDebugRuntime)
+		}
+
+		// WebSocket handler, and its Server-Sent Events counterpart
+		if p.wsHandler != nil {
+			mux.HandleFunc("/ws", p.wsHandler)
+		}
+		if p.streamHandler != nil {
+			mux.HandleFunc("/api/stream", p.streamHandler)
+		}
+
+		// UI handler
+		if p.uiHandler != nil {
+			mux.Handle("/ui/", http.StripPrefix("/ui/", p.uiHandler))
+			mux.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+			})
+		}
+
+		var handler http.Handler = mux
+		if p.readOnly {
+			handler = ReadOnlyHandler(handler)
+		}
+
+		// Compress local API and UI responses, never proxied traffic
+		p.local = GzipHandler(handler)
+	})
+	return p.local
+}
+
+// TraceID returns the active trace new messages are recorded under
+func (p *Proxy) TraceID() string {
+	p.traceMu.RLock()
+	defer p.traceMu.RUnlock()
+	return p.traceID
+}
+
+// Paused reports whether recording is paused
+func (p *Proxy) Paused() bool {
+	return p.paused.Load()
+}
+
+// SetPaused pauses or resumes recording. Traffic is forwarded either way.
+// The pause handler is only called when the state changes.
+func (p *Proxy) SetPaused(paused bool) {
+	if p.paused.CompareAndSwap(!paused, paused) && p.onPause != nil {
+		p.onPause(paused)
+	}
+}
+
+// StartTrace creates a trace and makes it the active one. The previous trace
+// is marked completed; its messages stay under it, as do responses to
+// requests it was waiting on.
+func (p *Proxy) StartTrace(ctx context.Context, command string) (*store.Trace, error) {
+	trace, err := p.store.CreateTraceContext(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+
+	p.traceMu.Lock()
+	previous := p.traceID
+	p.traceID = trace.ID
+	p.traceMu.Unlock()
+
+	if previous != "" {
+		if err := p.store.UpdateTraceStatusContext(ctx, previous, "completed"); err != nil {
+			log.Printf("Failed to complete trace %s: %v", previous, err)
+		}
+	}
+
+	if p.onTrace != nil {
+		p.onTrace(trace)
+	}
+	return trace, nil
+}
+
+// SetProcess sets the traced process reported by /health. The process is
+// started after the proxy, so it can't be passed in Config.
+func (p *Proxy) SetProcess(process ProcessMonitor) {
+	p.processMu.Lock()
+	defer p.processMu.Unlock()
+	p.process = process
+}
+
+// Healthz reports store connectivity, the traced process state, connected
+// WebSocket clients and uptime. It responds 503 when the store is unreachable
+// so it can serve as a readiness probe.
+func (p *Proxy) Healthz(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	status := http.StatusOK
+	health := map[string]interface{}{
+		"status":         "ok",
+		"store":          "ok",
+		"uptime_seconds": int64(time.Since(p.startedAt).Seconds()),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := p.store.PingContext(ctx); err != nil {
+		status = http.StatusServiceUnavailable
+		health["status"] = "unavailable"
+		health["store"] = err.Error()
+	}
+
+	p.processMu.Lock()
+	process := p.process
+	p.processMu.Unlock()
+	if process != nil {
+		health["process_running"] = process.IsRunning()
+	}
+
+	if p.clients != nil {
+		health["ws_clients"] = p.clients.ClientCount()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json, _ := json.Marshal(health)
+	w.Write(json)
+}
+
+// isLocalPath reports whether a non-proxy request targets a2a-trace itself
+// rather than an upstream agent reached through the Host header
+func isLocalPath(path string) bool {
+	return path == "/health" ||
+		strings.HasPrefix(path, "/api/") ||
+		path == "/ws" ||
+		path == "/ui" || strings.HasPrefix(path, "/ui/")
 }
 
 // Stop gracefully stops the proxy server
 func (p *Proxy) Stop() error {
-	if p.server == nil {
-		return nil
-	}
-	
+	p.serverMu.Lock()
+	server := p.server
+	webhookServer := p.webhookServer
+	p.serverMu.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	
-	return p.server.Shutdown(ctx)
+
+	if webhookServer != nil {
+		_ = webhookServer.Shutdown(ctx)
+	}
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
 
 // handleProxy handles proxied requests
@@ -181,13 +568,34 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get target URL from request
-	targetURL := r.URL.String()
-	if !strings.HasPrefix(targetURL, "http") {
-		// If using as forward proxy, URL should be absolute
-		// Otherwise, use Host header
-		targetURL = "http://" + r.Host + r.URL.RequestURI()
+	// A request we already forwarded came back to us, e.g. an unknown local
+	// path proxied via its Host header to our own port
+	if strings.Contains(r.Header.Get("Via"), viaToken) {
+		http.Error(w, "a2a-trace: proxy loop detected", http.StatusLoopDetected)
+		return
+	}
+
+	if !p.acquireSlot(r) {
+		http.Error(w, "a2a-trace: too many concurrent requests", http.StatusServiceUnavailable)
+		return
 	}
+	defer p.releaseSlot()
+
+	// Time spent by a2a-trace itself is tracked separately from upstream latency
+	interceptStart := time.Now()
+
+	targetURL := targetURLOf(r)
+
+	// Requests not addressed to a proxy, such as redirected ones, carry only
+	// a path; they're recorded under the URL they're forwarded to
+	if r.URL.Host == "" && r.URL.Scheme != "unix" {
+		if u, err := url.Parse(targetURL); err == nil {
+			r.URL = u
+		}
+	}
+
+	// A request and its response stay in the trace active when it arrived
+	traceID := p.TraceID()
 
 	// Read request body
 	reqBody, newReqBody, err := p.interceptor.ReadBody(r.Body)
@@ -197,23 +605,52 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 	r.Body = newReqBody
 
-	// Parse request for A2A
+	// Parse request for A2A; while paused, traffic is only forwarded
 	var reqMsg *store.Message
-	if p.interceptor.IsA2ARequest(r) || len(reqBody) > 0 {
-		reqMsg = p.interceptor.ParseRequest(r, reqBody, p.traceID)
-		
+	recording := !p.Paused()
+	if recording && p.interceptor.IsA2ARequest(r, reqBody) {
+		reqMsg = p.interceptor.ParseRequest(r, reqBody, traceID)
+		defer p.trackInflight(reqMsg, r.RemoteAddr)()
+		p.offloadBody(reqMsg)
+
 		// Store request
-		if err := p.store.SaveMessage(reqMsg); err != nil {
-			log.Printf("Failed to save request: %v", err)
-		}
-		
+		p.saveMessage(reqMsg)
+
 		// Notify handler
 		if p.onMessage != nil {
 			p.onMessage(reqMsg)
 		}
+
+		if p.autoDiscover {
+			p.discoverAgent(targetURL)
+		}
 	}
 
 	startTime := time.Now()
+	overhead := startTime.Sub(interceptStart)
+
+	// Injected faults delay the request or stand in for the upstream
+	var fault *FaultRule
+	if p.faults != nil {
+		method := ""
+		if reqMsg != nil {
+			method = reqMsg.Method
+		}
+		fault = p.faults.Pick(method, targetURL)
+	}
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 || fault.Drop {
+			p.injectFault(w, reqMsg, fault, targetURL, startTime)
+			return
+		}
+	}
 
 	// Create the proxied request
 	proxyReq, err := http.NewRequest(r.Method, targetURL, bytes.NewReader(reqBody))
@@ -229,10 +666,27 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Forward request trailers, already read along with the body. They can
+	// only follow a chunked body.
+	if len(r.Trailer) > 0 {
+		proxyReq.Trailer = r.Trailer.Clone()
+		proxyReq.ContentLength = -1
+	}
+
 	// Remove proxy-specific headers
 	proxyReq.Header.Del("Proxy-Connection")
 	proxyReq.Header.Del("Proxy-Authenticate")
 	proxyReq.Header.Del("Proxy-Authorization")
+	proxyReq.Header.Add("Via", "1.1 "+viaToken)
+	p.injectUpstreamAuth(proxyReq, targetURL)
+
+	// Redirects followed on the way are recorded against the request, as is
+	// how long connecting took
+	var timing *connTiming
+	if reqMsg != nil {
+		proxyReq = withRedirectTrace(proxyReq, reqMsg, startTime)
+		proxyReq, timing = withConnTiming(proxyReq)
+	}
 
 	// Send request
 	resp, err := p.client.Do(proxyReq)
@@ -240,15 +694,20 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		// Log error and return
 		if reqMsg != nil {
 			errMsg := &store.Message{
-				TraceID:    p.traceID,
-				Timestamp:  time.Now(),
-				Direction:  "response",
-				URL:        targetURL,
-				Error:      err.Error(),
-				DurationMs: time.Since(startTime).Milliseconds(),
-				RequestID:  reqMsg.ID,
+				TraceID:         traceID,
+				Timestamp:       p.interceptor.Clock.Now(),
+				Direction:       "response",
+				URL:             targetURL,
+				Error:           err.Error(),
+				DurationMs:      time.Since(startTime).Milliseconds(),
+				OverheadMs:      overhead.Milliseconds(),
+				RequestID:       reqMsg.ID,
+				Source:          reqMsg.Source,
+				IngressPort:     reqMsg.IngressPort,
+				Timing:          timing.JSON(),
+				ClientAbandoned: r.Context().Err() != nil,
 			}
-			_ = p.store.SaveMessage(errMsg)
+			p.saveMessage(errMsg)
 			if p.onMessage != nil {
 				p.onMessage(errMsg)
 			}
@@ -258,33 +717,47 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	duration := time.Since(startTime)
-
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+	// Read response body, stopping just past the size limit
+	body := io.Reader(resp.Body)
+	if p.maxResponseSize > 0 {
+		body = io.LimitReader(resp.Body, p.maxResponseSize+1)
+	}
+	respBody, err := io.ReadAll(body)
 	if err != nil {
 		http.Error(w, "Failed to read response", http.StatusInternalServerError)
 		return
 	}
+	if p.maxResponseSize > 0 && int64(len(respBody)) > p.maxResponseSize {
+		p.relayOversized(w, r, resp, respBody, reqMsg, fault, startTime, overhead, timing)
+		return
+	}
+
+	// Upstream time covers sending the request through reading the full body
+	respEnd := time.Now()
+	duration := respEnd.Sub(startTime)
+
+	// The upstream is left to finish so its response is recorded, but a
+	// client that disconnected meanwhile can't be sent it
+	abandoned := r.Context().Err() != nil
 
 	// Parse response for A2A
 	if reqMsg != nil {
 		respMsg := p.interceptor.ParseResponse(resp, respBody, reqMsg, duration)
-		
-		// Store response
-		if err := p.store.SaveMessage(respMsg); err != nil {
-			log.Printf("Failed to save response: %v", err)
-		}
-		
-		// Notify handler
-		if p.onMessage != nil {
-			p.onMessage(respMsg)
+		p.recordResponse(respMsg, overhead+time.Since(respEnd), timing, abandoned, fault)
+
+		// Record what the agent produced
+		for _, artifact := range p.interceptor.ParseArtifacts(respBody) {
+			artifact.TraceID = traceID
+			artifact.MessageID = respMsg.ID
+			if err := p.store.SaveArtifact(artifact); err != nil {
+				log.Printf("Failed to save artifact: %v", err)
+			}
 		}
 
 		// Check if this is an agent card response (check targetURL, not r.URL.Path)
-		if strings.Contains(targetURL, "/.well-known/agent.json") {
+		if strings.Contains(targetURL, agentCardPath) {
 			if agent := p.interceptor.ParseAgentCard(respBody, targetURL); agent != nil {
-				if err := p.store.SaveAgent(agent); err != nil {
+				if err := p.saveAgent(agent); err != nil {
 					log.Printf("Failed to save agent: %v", err)
 				} else {
 					log.Printf("Discovered agent: %s (%s)", agent.Name, agent.URL)
@@ -296,29 +769,189 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Copy response headers
+	if abandoned {
+		return
+	}
+	writeResponseHeader(w, resp)
+	w.Write(respBody)
+	writeTrailers(w, resp)
+}
+
+// writeResponseHeader copies an upstream response's status and headers to
+// the client
+func writeResponseHeader(w http.ResponseWriter, resp *http.Response) {
 	for key, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
 
-	// Write status code and body
+	// Announce trailers, which carry grpc-status for gRPC calls, so the
+	// response is sent chunked and they reach the client
+	for key := range resp.Trailer {
+		w.Header().Add("Trailer", key)
+	}
+
 	w.WriteHeader(resp.StatusCode)
-	w.Write(respBody)
+}
+
+// writeTrailers forwards an upstream response's trailers once its body has
+// been written
+func writeTrailers(w http.ResponseWriter, resp *http.Response) {
+	for key, values := range resp.Trailer {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+}
+
+// relayOversized passes a response over the size limit on to the client,
+// the part already read and then the rest as it arrives, without buffering
+// it. The response is recorded with its body cut off at the limit. Once
+// the client has disconnected, the rest isn't read.
+func (p *Proxy) relayOversized(w http.ResponseWriter, r *http.Request, resp *http.Response, head []byte, reqMsg *store.Message, fault *FaultRule, startTime time.Time, overhead time.Duration, timing *connTiming) {
+	var rest int64
+	if r.Context().Err() == nil {
+		writeResponseHeader(w, resp)
+		w.Write(head)
+		rest, _ = io.Copy(w, resp.Body)
+		writeTrailers(w, resp)
+	}
+
+	if reqMsg == nil {
+		return
+	}
+	respMsg := p.interceptor.ParseResponse(resp, head[:p.maxResponseSize], reqMsg, time.Since(startTime))
+	respMsg.Size = int64(len(head)) + rest
+	respMsg.Truncated = true
+	p.recordResponse(respMsg, overhead, timing, r.Context().Err() != nil, fault)
+}
+
+// recordResponse saves the response to a forwarded request along with how
+// it was delivered: the time a2a-trace added, the upstream connection's
+// timing, whether the client was gone and any fault injected
+func (p *Proxy) recordResponse(respMsg *store.Message, overhead time.Duration, timing *connTiming, abandoned bool, fault *FaultRule) {
+	respMsg.OverheadMs = overhead.Milliseconds()
+	respMsg.Timing = timing.JSON()
+	respMsg.ClientAbandoned = abandoned
+	if fault != nil {
+		respMsg.Fault = fault.String()
+	}
+	p.offloadBody(respMsg)
+
+	// Store response
+	p.saveMessage(respMsg)
+
+	// Notify handler
+	if p.onMessage != nil {
+		p.onMessage(respMsg)
+	}
+}
+
+// offloadBody moves a body over the blob threshold out of the message into
+// the blob store, leaving its path in BodyPath
+func (p *Proxy) offloadBody(msg *store.Message) {
+	if p.blobs == nil || int64(len(msg.Body)) <= p.blobThreshold {
+		return
+	}
+	path, err := p.blobs.Put(strings.NewReader(msg.Body))
+	if err != nil {
+		log.Printf("Failed to store %s body: %v", msg.Direction, err)
+		return
+	}
+	msg.BodyPath = path
+	msg.Body = ""
+}
+
+// saveMessage saves a message to the store and every sink
+func (p *Proxy) saveMessage(msg *store.Message) {
+	if err := p.store.SaveMessage(msg); err != nil {
+		log.Printf("Failed to save %s: %v", msg.Direction, err)
+	}
+	for _, sink := range p.sinks {
+		if err := sink.SaveMessage(msg); err != nil {
+			log.Printf("Failed to write %s to sink: %v", msg.Direction, err)
+		}
+	}
+}
+
+// saveAgent saves an agent to the store and every sink, returning the store's error
+func (p *Proxy) saveAgent(agent *store.Agent) error {
+	err := p.store.SaveAgent(agent)
+	for _, sink := range p.sinks {
+		if err := sink.SaveAgent(agent); err != nil {
+			log.Printf("Failed to write agent to sink: %v", err)
+		}
+	}
+	return err
+}
+
+// targetURLOf returns the upstream URL a proxied request is for
+func targetURLOf(r *http.Request) string {
+	targetURL := r.URL.String()
+	if r.URL.Scheme != "unix" && !strings.HasPrefix(targetURL, "http") {
+		// If using as forward proxy, URL should be absolute. Redirected
+		// connections know where they were headed; otherwise, use Host header
+		host := r.Host
+		if dst := originalDstOf(r); dst != "" {
+			host = dst
+		}
+		targetURL = "http://" + host + r.URL.RequestURI()
+	}
+	return targetURL
+}
+
+// acquireSlot takes a slot under the concurrency limit, queuing until one is
+// free or refusing at once when configured to. It reports false if the request
+// was refused or abandoned while waiting.
+func (p *Proxy) acquireSlot(r *http.Request) bool {
+	if p.slots == nil {
+		return true
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if p.rejectOverLimit {
+		if p.onThrottle != nil {
+			p.onThrottle(targetURLOf(r), 0, true)
+		}
+		return false
+	}
+
+	queuedAt := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		if p.onThrottle != nil {
+			p.onThrottle(targetURLOf(r), time.Since(queuedAt), false)
+		}
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// releaseSlot frees a slot taken by acquireSlot
+func (p *Proxy) releaseSlot() {
+	if p.slots != nil {
+		<-p.slots
+	}
 }
 
 // handleConnect handles HTTPS CONNECT tunneling
 func (p *Proxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 	// For HTTPS, we just tunnel without intercepting
 	// (intercepting HTTPS requires certificate setup)
-	
+
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
 		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
 		return
 	}
-	
+
 	destConn, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
@@ -326,21 +959,39 @@ func (p *Proxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	
-	clientConn, _, err := hijacker.Hijack()
+
+	clientConn, clientBuf, err := hijacker.Hijack()
 	if err != nil {
 		destConn.Close() // Close destConn on hijack failure
 		return
 	}
 
-	go transfer(destConn, clientConn)
-	go transfer(clientConn, destConn)
+	// The handler's goroutine is free once hijacked, so it runs the tunnel
+	tunnel(clientConn, clientBuf.Reader, destConn)
 }
 
-func transfer(destination io.WriteCloser, source io.ReadCloser) {
-	defer destination.Close()
-	defer source.Close()
-	io.Copy(destination, source)
+// tunnel relays bytes both ways between a hijacked client connection and the
+// upstream. As soon as either direction ends, both connections are closed so
+// the other copy unblocks instead of leaking; tunnel returns once both have.
+// clientReader drains anything buffered during the hijack before reading from
+// client.
+func tunnel(client net.Conn, clientReader io.Reader, dest net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		client.Close()
+		dest.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(dest, clientReader)
+		once.Do(closeBoth)
+	}()
+
+	io.Copy(client, dest)
+	once.Do(closeBoth)
+	<-done
 }
 
 // API handlers for UI
@@ -351,11 +1002,33 @@ func (p *Proxy) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messages, err := p.store.GetMessages(p.traceID)
+	var messages []*store.Message
+	var err error
+	switch only := r.URL.Query().Get("only"); only {
+	case "":
+		messages, err = p.store.GetMessagesContext(r.Context(), p.TraceID())
+	case "errors", "insights":
+		messages, err = p.store.GetFlaggedMessagesContext(r.Context(), p.TraceID(), only)
+	default:
+		http.Error(w, "only must be errors or insights", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if messages == nil {
+		messages = []*store.Message{}
+	}
+
+	// Bodies are left out unless asked for, as they make up most of the
+	// list; each can be fetched on its own from body_url
+	if r.URL.Query().Get("include_body") != "true" {
+		for _, msg := range messages {
+			msg.Body = ""
+			msg.BodyURL = "/api/messages/" + url.PathEscape(msg.ID) + "/body"
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json, _ := json.Marshal(messages)
@@ -368,14 +1041,29 @@ func (p *Proxy) handleGetAgents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	agents, err := p.store.GetAgents()
+	agents, err := p.store.GetAgentsContext(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	type agentWithHealth struct {
+		*store.Agent
+		Health *store.AgentHealth `json:"health,omitempty"`
+	}
+	result := make([]agentWithHealth, 0, len(agents))
+	for _, agent := range agents {
+		entry := agentWithHealth{Agent: agent}
+		if p.healthProvider != nil {
+			if health, err := p.healthProvider.AgentHealth(agent.URL); err == nil {
+				entry.Health = health
+			}
+		}
+		result = append(result, entry)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json, _ := json.Marshal(agents)
+	json, _ := json.Marshal(result)
 	w.Write(json)
 }
 
@@ -385,14 +1073,181 @@ func (p *Proxy) handleGetTrace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	trace, err := p.store.GetTrace(p.traceID)
+	trace, err := p.store.GetTraceContext(r.Context(), p.TraceID())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// The trace's fields with the span of its messages alongside
+	var resp interface{} = trace
+	if trace != nil {
+		stats, err := p.store.GetTraceStatsContext(r.Context(), trace.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp = struct {
+			*store.Trace
+			*store.TraceStats
+		}{trace, stats}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json, _ := json.Marshal(trace)
+	json, _ := json.Marshal(resp)
+	w.Write(json)
+}
+
+// handleTraces lists traces (GET) or starts a new active trace (POST)
+func (p *Proxy) handleTraces(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		return
+
+	case "GET":
+		traces, err := p.store.ListTracesContext(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if traces == nil {
+			traces = []*store.Trace{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json, _ := json.Marshal(traces)
+		w.Write(json)
+
+	case "POST":
+		var req struct {
+			Command string `json:"command"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		trace, err := p.StartTrace(r.Context(), req.Command)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json, _ := json.Marshal(trace)
+		w.Write(json)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAnnotations lists the active trace's timeline markers (GET) or adds
+// one at the current time (POST)
+func (p *Proxy) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		return
+
+	case "GET":
+		annotations, err := p.store.GetAnnotationsContext(r.Context(), p.TraceID())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if annotations == nil {
+			annotations = []*store.Annotation{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json, _ := json.Marshal(annotations)
+		w.Write(json)
+
+	case "POST":
+		var req struct {
+			Label string `json:"label"`
+			Note  string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Label) == "" {
+			http.Error(w, "label is required", http.StatusBadRequest)
+			return
+		}
+
+		annotation := &store.Annotation{
+			TraceID:   p.TraceID(),
+			Timestamp: p.interceptor.Clock.Now(),
+			Label:     req.Label,
+			Note:      req.Note,
+		}
+		if err := p.store.SaveAnnotationContext(r.Context(), annotation); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if p.onAnnotation != nil {
+			p.onAnnotation(annotation)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json, _ := json.Marshal(annotation)
+		w.Write(json)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleControl reports (GET) or changes (POST {"action":"pause"|"resume"})
+// whether recording is paused
+func (p *Proxy) handleControl(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		return
+
+	case "GET":
+		// Reports the state below
+
+	case "POST":
+		var req struct {
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch req.Action {
+		case "pause":
+			p.SetPaused(true)
+		case "resume":
+			p.SetPaused(false)
+		default:
+			http.Error(w, `action must be "pause" or "resume"`, http.StatusBadRequest)
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(map[string]bool{"paused": p.Paused()})
 	w.Write(json)
 }
 
@@ -402,14 +1257,86 @@ func (p *Proxy) handleExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := p.store.ExportTrace(p.traceID)
+	traceID := p.TraceID()
+
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		http.Error(w, "invalid range: to is before from", http.StatusBadRequest)
+		return
+	}
+
+	// Only the JSON export can be cut to a range
+	format := r.URL.Query().Get("format")
+	if (format == "binary" || format == "chrome") && (!from.IsZero() || !to.IsZero()) {
+		http.Error(w, fmt.Sprintf("from and to aren't supported with format=%s", format), http.StatusBadRequest)
+		return
+	}
+
+	if format == "binary" {
+		data, err := p.store.ExportTraceBinaryContext(r.Context(), traceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%s.a2at", traceID))
+		w.Write(data)
+		return
+	}
+
+	if format == "chrome" {
+		data, err := p.store.ExportChromeTraceContext(r.Context(), traceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%s.chrome.json", traceID))
+		w.Write(data)
+		return
+	}
+
+	// Streamed, so a large trace isn't held in memory; a failure part way
+	// leaves the client with truncated JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%s.json", traceID))
+	if err := p.store.StreamExportRangeContext(r.Context(), traceID, from, to, w); err != nil {
+		log.Printf("Failed to export trace %s: %v", traceID, err)
+	}
+}
+
+// handleExportThread exports the messages of one task, with their insights
+// and agent cards, for sharing a single conversation
+func (p *Proxy) handleExportThread(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	taskID := r.PathValue("taskId")
+	data, err := p.store.ExportThreadContext(r.Context(), p.TraceID(), taskID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if data == nil {
+		http.Error(w, "no messages for task", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=trace-%s.json", p.traceID))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=thread-%s.json", taskID))
 	w.Write(data)
 }
 
@@ -419,17 +1346,267 @@ func (p *Proxy) handleGetInsights(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	insights, err := p.store.GetInsights(p.traceID)
+	insights, err := p.store.GetInsightsContext(r.Context(), p.TraceID())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Acknowledged insights are triaged; leave them out unless asked for
+	if r.URL.Query().Get("include_acked") != "true" {
+		active := insights[:0]
+		for _, insight := range insights {
+			if !insight.Acknowledged {
+				active = append(active, insight)
+			}
+		}
+		insights = active
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json, _ := json.Marshal(insights)
 	w.Write(json)
 }
 
+// handleAckInsight marks an insight as acknowledged, hiding it from
+// /api/insights by default
+func (p *Proxy) handleAckInsight(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	switch r.Method {
+	case "OPTIONS":
+		return
+	case "POST":
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	insight, err := p.store.AckInsightContext(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if insight == nil {
+		http.Error(w, "insight not found", http.StatusNotFound)
+		return
+	}
+	if p.onInsightAck != nil {
+		p.onInsightAck(insight)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(insight)
+	w.Write(json)
+}
+
+// handleGetBody serves a message's raw body, streaming it from the blob store
+// when it was too large for the database
+func (p *Proxy) handleGetBody(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	msg, err := p.store.GetMessageBodyContext(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	// Bodies come from untrusted agents and this is the UI's origin, so
+	// browsers must never render one, e.g. as HTML
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", "attachment")
+
+	if msg.BodyPath == "" {
+		w.Write([]byte(msg.Body))
+		return
+	}
+
+	file, err := os.Open(msg.BodyPath)
+	if err != nil {
+		http.Error(w, "body is no longer available", http.StatusGone)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// handleGetChildren lists the requests made while serving a request, the
+// next level down of its call tree
+func (p *Proxy) handleGetChildren(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	children, err := p.store.GetChildMessagesContext(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if children == nil {
+		children = []*store.Message{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(children)
+	w.Write(json)
+}
+
+func (p *Proxy) handleGetArtifacts(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	artifacts, err := p.store.GetArtifactsContext(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if artifacts == nil {
+		artifacts = []*store.Artifact{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(artifacts)
+	w.Write(json)
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter, returning the
+// zero time when it is absent
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: expected an RFC3339 timestamp", name)
+	}
+	return t, nil
+}
+
+func (p *Proxy) handleGetGraph(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	graph, err := p.store.BuildCallGraphContext(r.Context(), p.TraceID())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(graph)
+	w.Write(json)
+}
+
+func (p *Proxy) handleGetTopology(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	topology, err := p.store.TopoSortContext(r.Context(), p.TraceID())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(topology)
+	w.Write(json)
+}
+
+// handleGetSchema infers the shape of each method's params and results, to
+// help write mock definitions
+func (p *Proxy) handleGetSchema(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	schemas, err := p.store.InferSchemasContext(r.Context(), p.TraceID())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(schemas)
+	w.Write(json)
+}
+
+// handleGetSkills reports how each advertised or invoked agent skill was used
+func (p *Proxy) handleGetSkills(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	usage, err := p.store.GetSkillUsageContext(r.Context(), p.TraceID())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(usage)
+	w.Write(json)
+}
+
+// handleGetTimeSeries buckets the trace's traffic by ?bucket= (default 1s)
+func (p *Proxy) handleGetTimeSeries(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	bucket := time.Second
+	if value := r.URL.Query().Get("bucket"); value != "" {
+		var err error
+		bucket, err = time.ParseDuration(value)
+		if err != nil || bucket < time.Millisecond {
+			http.Error(w, "bucket must be a duration of at least 1ms, e.g. 500ms or 1s", http.StatusBadRequest)
+			return
+		}
+	}
+
+	series, err := p.store.GetTimeSeriesContext(r.Context(), p.TraceID(), bucket)
+	if errors.Is(err, store.ErrTooManyBuckets) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json, _ := json.Marshal(series)
+	w.Write(json)
+}
+
 func (p *Proxy) handleGetSummary(w http.ResponseWriter, r *http.Request) {
 	setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
@@ -457,14 +1634,12 @@ func setCORSHeaders(w http.ResponseWriter) {
 // CreateReverseProxy creates a reverse proxy for a specific target
 func CreateReverseProxy(target *url.URL) *httputil.ReverseProxy {
 	proxy := httputil.NewSingleHostReverseProxy(target)
-	
+
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
 		req.Host = target.Host
 	}
-	
+
 	return proxy
 }
-
-
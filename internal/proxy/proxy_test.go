@@ -0,0 +1,917 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/analyzer"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// newTestProxy returns a proxy recording into a fresh store and trace. cfg's
+// Store and TraceID are filled in.
+func newTestProxy(t *testing.T, cfg Config) (*Proxy, *store.Store, *store.Trace) {
+	t.Helper()
+
+	st, err := store.New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	trace, err := st.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Store = st
+	cfg.TraceID = trace.ID
+	return New(cfg), st, trace
+}
+
+// serveLocal sends a request to the proxy's API and UI handler
+func serveLocal(p *Proxy, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	p.LocalHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+// sendJSON sends a JSON POST through the proxy to url, as a client using it
+// as its HTTP proxy would
+func sendJSON(p *Proxy, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	p.handleProxy(rec, req)
+	return rec
+}
+
+// newJSONUpstream returns an agent answering every request with body
+func newJSONUpstream(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// messagesOf returns a trace's recorded messages
+func messagesOf(t *testing.T, st *store.Store, traceID string) []*store.Message {
+	t.Helper()
+	messages, err := st.GetMessages(traceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestOverheadExcludedFromDuration(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	p, st, trace := newTestProxy(t, Config{
+		// Stands in for slow parsing and storage of the request
+		OnMessage: func(msg *store.Message) {
+			if msg.Direction == "request" {
+				time.Sleep(50 * time.Millisecond)
+			}
+		},
+	})
+
+	sendJSON(p, upstream.URL+"/rpc", `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	resp := messages[1]
+	if resp.OverheadMs < 50 {
+		t.Errorf("OverheadMs = %d, want at least 50", resp.OverheadMs)
+	}
+	if resp.DurationMs < 0 || resp.DurationMs >= 50 {
+		t.Errorf("DurationMs = %d, want the upstream time alone", resp.DurationMs)
+	}
+}
+
+// startProxy serves the proxy on a free local port until the test ends,
+// returning its URL
+func startProxy(t *testing.T, p *Proxy) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = p.Serve(ln) }()
+	t.Cleanup(func() { _ = p.Stop() })
+	return "http://" + ln.Addr().String()
+}
+
+func TestUIAndProxyShareOnePort(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	p, _, _ := newTestProxy(t, Config{
+		UIHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ui:"+r.URL.Path)
+		}),
+	})
+	proxyURL := startProxy(t, p)
+
+	// The UI, on the proxy's own port
+	resp, err := http.Get(proxyURL + "/ui/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ui:index.html" {
+		t.Errorf("GET /ui/index.html = %q, want the UI handler", body)
+	}
+
+	// The API
+	resp, err = http.Get(proxyURL + "/api/messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "application/json" {
+		t.Errorf("GET /api/messages = %d %s, want 200 application/json", resp.StatusCode, ct)
+	}
+
+	// A proxied request through the same port
+	via, _ := url.Parse(proxyURL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(via)}}
+	resp, err = client.Post(upstream.URL+"/ui/", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"result"`) {
+		t.Errorf("proxied request got %q, want the upstream's response", body)
+	}
+}
+
+// freePort returns a TCP port nothing is listening on
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestExtraPortsTagIngress(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	ports := []int{freePort(t), freePort(t)}
+	p, st, trace := newTestProxy(t, Config{Port: ports[0], ExtraPorts: ports[1:]})
+	go func() { _ = p.Start() }()
+	t.Cleanup(func() { _ = p.Stop() })
+
+	for _, port := range ports {
+		via, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", port))
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(via)}}
+
+		// Until Start is listening
+		var resp *http.Response
+		var err error
+		for range 50 {
+			resp, err = client.Post(upstream.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`))
+			if err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	got := map[string][]int{}
+	for _, msg := range messagesOf(t, st, trace.ID) {
+		got[msg.Direction] = append(got[msg.Direction], msg.IngressPort)
+	}
+	for _, direction := range []string{"request", "response"} {
+		if fmt.Sprint(got[direction]) != fmt.Sprint(ports) {
+			t.Errorf("%ss came in on ports %v, want %v", direction, got[direction], ports)
+		}
+	}
+}
+
+// fakeProcess is a ProcessMonitor with a fixed state
+type fakeProcess bool
+
+func (f fakeProcess) IsRunning() bool { return bool(f) }
+
+// fakeClients is a ClientCounter with a fixed count
+type fakeClients int
+
+func (f fakeClients) ClientCount() int { return int(f) }
+
+func TestHealthzReportsStatus(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{Clients: fakeClients(2)})
+	p.SetProcess(fakeProcess(false))
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var health map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health["status"] != "ok" || health["store"] != "ok" || health["process_running"] != false || health["ws_clients"] != 2.0 {
+		t.Errorf("got %v", health)
+	}
+	if _, ok := health["uptime_seconds"]; !ok {
+		t.Error("missing uptime_seconds")
+	}
+}
+
+func TestHealthzUnavailableWithClosedStore(t *testing.T) {
+	p, st, _ := newTestProxy(t, Config{})
+	st.Close()
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var health map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health["status"] != "unavailable" || health["store"] == "ok" {
+		t.Errorf("got %v", health)
+	}
+}
+
+func TestConcurrencyNeverExceedsLimit(t *testing.T) {
+	var current, peak atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "{}")
+	}))
+	defer upstream.Close()
+
+	var throttled atomic.Int32
+	p, _, _ := newTestProxy(t, Config{
+		MaxConcurrency: 2,
+		OnThrottle: func(targetURL string, waited time.Duration, rejected bool) {
+			throttled.Add(1)
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`); rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200 once queued", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("%d requests reached the upstream at once, want at most 2", got)
+	}
+	if throttled.Load() == 0 {
+		t.Error("no request was reported as throttled")
+	}
+}
+
+func TestConcurrencyLimitRejects(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		io.WriteString(w, "{}")
+	}))
+	defer upstream.Close()
+
+	rejected := false
+	p, _, _ := newTestProxy(t, Config{
+		MaxConcurrency:  2,
+		RejectOverLimit: true,
+		OnThrottle: func(targetURL string, waited time.Duration, r bool) {
+			rejected = r
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendJSON(p, upstream.URL, `{}`)
+		}()
+	}
+	<-arrived
+	<-arrived
+
+	rec := sendJSON(p, upstream.URL, `{}`)
+	close(release)
+	wg.Wait()
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 over the limit", rec.Code)
+	}
+	if !rejected {
+		t.Error("the rejection wasn't reported")
+	}
+}
+
+func TestExportRejectsBadRange(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{})
+
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2025-13-01T00:00:00Z",
+		"from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z",
+		"from=2025-01-01T00:00:00Z&format=chrome",
+		"to=2025-01-01T00:00:00Z&format=binary",
+	} {
+		rec := serveLocal(p, httptest.NewRequest("GET", "/api/export?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestExportRange(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		msg := &store.Message{ID: fmt.Sprint(i), TraceID: trace.ID, Direction: "request", Timestamp: start.Add(time.Duration(i) * time.Hour)}
+		if err := st.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/export?from=2025-01-01T12:30:00Z", nil))
+	var export struct {
+		Messages []*store.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Messages) != 2 || export.Messages[0].ID != "1" {
+		t.Errorf("exported %d messages, want the 2 after from", len(export.Messages))
+	}
+}
+
+func TestRequestIDsComeFromStoreGenerator(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	st, err := store.New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	n := 0
+	st.NewID = func() string {
+		n++
+		return fmt.Sprintf("id-%d", n)
+	}
+	trace, err := st.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(Config{Store: st, TraceID: trace.ID})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if messages[0].ID != "id-2" || messages[1].ID != "id-3" {
+		t.Errorf("got ids %s and %s, want id-2 and id-3", messages[0].ID, messages[1].ID)
+	}
+}
+
+func TestTrailersRecordedAndForwarded(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer upstream.Close()
+	p, st, trace := newTestProxy(t, Config{})
+	via, _ := url.Parse(startProxy(t, p))
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(via)}}
+
+	resp, err := client.Post(upstream.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+		t.Errorf("client got trailer %q, want abc123", got)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if got := messages[1].Trailers; got != `{"X-Checksum":"abc123"}` {
+		t.Errorf("recorded trailers %s", got)
+	}
+}
+
+func TestStartTraceSwitchesAttribution(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	var started []string
+	p, st, first := newTestProxy(t, Config{
+		OnTrace: func(trace *store.Trace) { started = append(started, trace.Command) },
+	})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	var traces []*store.Trace
+	for _, label := range []string{"second", "third"} {
+		rec := serveLocal(p, httptest.NewRequest("POST", "/api/traces", strings.NewReader(`{"command":"`+label+`"}`)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST /api/traces = %d %s", rec.Code, rec.Body)
+		}
+		var trace store.Trace
+		if err := json.Unmarshal(rec.Body.Bytes(), &trace); err != nil {
+			t.Fatal(err)
+		}
+		if p.TraceID() != trace.ID {
+			t.Errorf("active trace %s, want the new %s", p.TraceID(), trace.ID)
+		}
+		traces = append(traces, &trace)
+
+		sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	}
+
+	for _, trace := range []*store.Trace{first, traces[0], traces[1]} {
+		if n := len(messagesOf(t, st, trace.ID)); n != 2 {
+			t.Errorf("trace %s has %d messages, want its own 2", trace.Command, n)
+		}
+	}
+	if strings.Join(started, ",") != "second,third" {
+		t.Errorf("OnTrace called for %q", started)
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/traces", nil))
+	var listed []*store.Trace
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 {
+		t.Errorf("GET /api/traces listed %d traces, want 3", len(listed))
+	}
+}
+
+// fakeSink is a store.MessageSink remembering what it was given
+type fakeSink struct {
+	mu       sync.Mutex
+	messages []*store.Message
+	agents   []*store.Agent
+}
+
+func (s *fakeSink) SaveMessage(msg *store.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *fakeSink) SaveAgent(agent *store.Agent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agents = append(s.agents, agent)
+	return nil
+}
+
+func (s *fakeSink) SaveInsight(insight *store.Insight) error { return nil }
+
+func TestSinksReceiveSavedMessages(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	sink := &fakeSink{}
+	p, st, trace := newTestProxy(t, Config{Sinks: []store.MessageSink{sink}})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	saved := messagesOf(t, st, trace.ID)
+	if len(sink.messages) != len(saved) || len(saved) != 2 {
+		t.Fatalf("sink got %d messages, store %d; want 2 each", len(sink.messages), len(saved))
+	}
+	for i, msg := range sink.messages {
+		if msg.ID != saved[i].ID {
+			t.Errorf("sink message %d is %s, want %s", i, msg.ID, saved[i].ID)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	var broadcast []string
+	p, _, trace := newTestProxy(t, Config{
+		OnAnnotation: func(annotation *store.Annotation) { broadcast = append(broadcast, annotation.Label) },
+	})
+
+	for _, body := range []string{
+		`{"label":"deployed v2","note":"after the fix"}`,
+		`{"label":"load test starts"}`,
+	} {
+		rec := serveLocal(p, httptest.NewRequest("POST", "/api/annotations", strings.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST %s = %d %s", body, rec.Code, rec.Body)
+		}
+	}
+	if rec := serveLocal(p, httptest.NewRequest("POST", "/api/annotations", strings.NewReader(`{"label":" "}`))); rec.Code != http.StatusBadRequest {
+		t.Errorf("blank label got %d, want 400", rec.Code)
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/annotations", nil))
+	var listed []*store.Annotation
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Label != "deployed v2" || listed[0].Note != "after the fix" || listed[0].TraceID != trace.ID {
+		t.Errorf("listed %+v", listed)
+	}
+	if strings.Join(broadcast, ",") != "deployed v2,load test starts" {
+		t.Errorf("OnAnnotation called for %q", broadcast)
+	}
+
+	rec = serveLocal(p, httptest.NewRequest("GET", "/api/export", nil))
+	var export struct {
+		Annotations []*store.Annotation `json:"annotations"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Annotations) != 2 {
+		t.Errorf("exported %d annotations, want 2", len(export.Annotations))
+	}
+}
+
+func TestAckInsightHidesIt(t *testing.T) {
+	var acked []string
+	p, st, trace := newTestProxy(t, Config{OnInsightAck: func(insight *store.Insight) { acked = append(acked, insight.ID) }})
+	for _, id := range []string{"insight-1", "insight-2"} {
+		if err := st.SaveInsight(&store.Insight{ID: id, TraceID: trace.ID, Category: "error", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rec := serveLocal(p, httptest.NewRequest("POST", "/api/insights/insight-1/ack", nil)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"acknowledged":true`) {
+		t.Fatalf("ack got %d %s", rec.Code, rec.Body)
+	}
+	if fmt.Sprint(acked) != "[insight-1]" {
+		t.Errorf("OnInsightAck called for %v, want insight-1", acked)
+	}
+
+	listed := func(query string) string {
+		t.Helper()
+		var insights []*store.Insight
+		rec := serveLocal(p, httptest.NewRequest("GET", "/api/insights"+query, nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &insights); err != nil {
+			t.Fatalf("got %d %s", rec.Code, rec.Body)
+		}
+		var ids []string
+		for _, insight := range insights {
+			ids = append(ids, insight.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+	if got := listed(""); got != "[insight-2]" {
+		t.Errorf("listed %s by default, want only the unacknowledged insight", got)
+	}
+	if got := listed("?include_acked=true"); !strings.Contains(got, "insight-1") || !strings.Contains(got, "insight-2") {
+		t.Errorf("listed %s with include_acked, want both", got)
+	}
+
+	if rec := serveLocal(p, httptest.NewRequest("POST", "/api/insights/missing/ack", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("acking an unknown insight got %d, want 404", rec.Code)
+	}
+	if rec := serveLocal(p, httptest.NewRequest("GET", "/api/insights/insight-2/ack", nil)); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET ack got %d, want 405", rec.Code)
+	}
+}
+
+func TestGetMessagesOmitsBodies(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	body := `{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{}}`
+	msg := &store.Message{ID: "req 1", TraceID: trace.ID, Direction: "request", Body: body, ContentType: "application/json", Size: int64(len(body)), Timestamp: time.Now()}
+	if err := st.SaveMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(query string) *store.Message {
+		t.Helper()
+		var messages []*store.Message
+		rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages"+query, nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &messages); err != nil || len(messages) != 1 {
+			t.Fatalf("got %d %s", rec.Code, rec.Body)
+		}
+		return messages[0]
+	}
+
+	got := list("")
+	if got.Body != "" || got.Size != int64(len(body)) || got.BodyURL != "/api/messages/req%201/body" {
+		t.Errorf("listed with body %q, size %d, body_url %q", got.Body, got.Size, got.BodyURL)
+	}
+	rec := serveLocal(p, httptest.NewRequest("GET", got.BodyURL, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != body || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("body_url served %d %s %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages/missing/body", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("missing message's body got %d, want 404", rec.Code)
+	}
+
+	if got := list("?include_body=true"); got.Body != body || got.BodyURL != "" {
+		t.Errorf("include_body listed body %q, body_url %q", got.Body, got.BodyURL)
+	}
+}
+
+func TestGetMessagesOnlyErrors(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	for _, msg := range []*store.Message{
+		{ID: "ok", Direction: "response", StatusCode: 200},
+		{ID: "failed", Direction: "response", StatusCode: 502},
+	} {
+		msg.TraceID = trace.ID
+		msg.Timestamp = time.Now()
+		if err := st.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages?only=errors", nil))
+	var messages []*store.Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID != "failed" {
+		t.Errorf("got %d messages, want only the failed one", len(messages))
+	}
+
+	if rec := serveLocal(p, httptest.NewRequest("GET", "/api/messages?only=slow", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown filter got %d, want 400", rec.Code)
+	}
+}
+
+func TestDebugRuntime(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{Debug: true})
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/debug/runtime", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200", rec.Code)
+	}
+	var stats RuntimeStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Goroutines == 0 || stats.HeapAlloc == 0 || stats.DBSize == 0 {
+		t.Errorf("got %+v, want goroutines, heap and database size", stats)
+	}
+
+	// Only served with --debug
+	p, _, _ = newTestProxy(t, Config{})
+	if rec := serveLocal(p, httptest.NewRequest("GET", "/api/debug/runtime", nil)); rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), "goroutines") {
+		t.Error("served runtime stats without Debug")
+	}
+}
+
+func TestLargeBodyOffloadedToDisk(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"result":{"text":"` + strings.Repeat("x", 100000) + `"}}`
+	upstream := newJSONUpstream(t, body)
+	blobDir := t.TempDir()
+	blobs, err := store.NewBlobStore(blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, st, trace := newTestProxy(t, Config{Blobs: blobs, BlobThreshold: 1024})
+
+	rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Body.String() != body {
+		t.Error("client didn't get the full body")
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	req, resp := messages[0], messages[1]
+	if req.BodyPath != "" || req.Body == "" {
+		t.Error("small request body moved out of the database")
+	}
+	if resp.Body != "" || !strings.HasPrefix(resp.BodyPath, blobDir) {
+		t.Fatalf("large response body kept in the database (path %q)", resp.BodyPath)
+	}
+
+	rec = serveLocal(p, httptest.NewRequest("GET", "/api/messages/"+resp.ID+"/body", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("GET body = %d with %d bytes, want the %d bytes from disk", rec.Code, rec.Body.Len(), len(body))
+	}
+}
+
+func TestOffloadedBodiesAnalyzed(t *testing.T) {
+	padding := strings.Repeat("x", 4096)
+	upstream := newJSONUpstream(t, `{"id":1,"result":{"text":"`+padding+`"}}`)
+	blobs, err := store.NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, st, trace := newTestProxy(t, Config{Blobs: blobs, BlobThreshold: 16})
+	a := analyzer.New(analyzer.Config{Store: st, TraceID: trace.ID})
+	t.Cleanup(a.Stop)
+	p.onMessage = func(msg *store.Message) { a.AnalyzeMessage(msg) }
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{"password":"hunter2-Xq9!vLp#4","text":"`+padding+`"}}`)
+
+	for _, msg := range messagesOf(t, st, trace.ID) {
+		if msg.BodyPath == "" {
+			t.Fatalf("%s body not offloaded", msg.Direction)
+		}
+	}
+	insights, err := st.GetInsights(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, insight := range insights {
+		found[insight.Category] = true
+	}
+	if !found["credential_leak"] || !found["protocol_violation"] {
+		t.Errorf("got insights %v, want credential_leak and protocol_violation from the offloaded bodies", found)
+	}
+}
+
+func TestTimeSeriesBucketParam(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{})
+
+	if rec := serveLocal(p, httptest.NewRequest("GET", "/api/timeseries?bucket=500ms", nil)); rec.Code != http.StatusOK || rec.Body.String() != "[]" {
+		t.Errorf("got %d %s, want an empty series", rec.Code, rec.Body)
+	}
+	for _, bucket := range []string{"soon", "100us", "-1s"} {
+		if rec := serveLocal(p, httptest.NewRequest("GET", "/api/timeseries?bucket="+bucket, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("bucket=%s got %d, want 400", bucket, rec.Code)
+		}
+	}
+}
+
+func TestGetTraceIncludesSpan(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{0, 90 * time.Second} {
+		msg := &store.Message{ID: fmt.Sprint(i), TraceID: trace.ID, Direction: "request", Timestamp: start.Add(offset)}
+		if err := st.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serveLocal(p, httptest.NewRequest("GET", "/api/trace", nil))
+	var got struct {
+		ID            string    `json:"id"`
+		LastMessageAt time.Time `json:"last_message_at"`
+		DurationMs    int64     `json:"duration_ms"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != trace.ID || !got.LastMessageAt.Equal(start.Add(90*time.Second)) || got.DurationMs != 90000 {
+		t.Errorf("got %s", rec.Body)
+	}
+}
+
+func TestClientAbandonedResponseRecorded(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(received)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	saved := make(chan *store.Message, 4)
+	p, _, _ := newTestProxy(t, Config{OnMessage: func(msg *store.Message) { saved <- msg }})
+	via, _ := url.Parse(startProxy(t, p))
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(via)}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "POST", upstream.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`))
+	req.Header.Set("Content-Type", "application/json")
+	go func() {
+		<-received
+		cancel() // The client times out while the agent is working
+	}()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("cancelled request succeeded")
+	}
+
+	<-saved // The request
+	// The proxy notices the disconnect asynchronously
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case resp := <-saved:
+		if resp.Direction != "response" || resp.StatusCode != 200 || !resp.ClientAbandoned {
+			t.Errorf("recorded %s %d, abandoned %v; want the agent's response marked abandoned", resp.Direction, resp.StatusCode, resp.ClientAbandoned)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("response not recorded")
+	}
+}
+
+// control posts a pause or resume action to /api/control
+func control(t *testing.T, p *Proxy, action string) {
+	t.Helper()
+	rec := serveLocal(p, httptest.NewRequest("POST", "/api/control", strings.NewReader(`{"action":"`+action+`"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != fmt.Sprintf(`{"paused":%v}`, action == "pause") {
+		t.Fatalf("%s got %d %s", action, rec.Code, rec.Body)
+	}
+}
+
+func TestPausedTrafficForwardedButNotRecorded(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	var changes []bool
+	var seen int
+	p, st, trace := newTestProxy(t, Config{
+		OnPause:   func(paused bool) { changes = append(changes, paused) },
+		OnMessage: func(*store.Message) { seen++ },
+	})
+
+	control(t, p, "pause")
+	control(t, p, "pause") // Already paused
+	rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"result"`) {
+		t.Errorf("paused request got %d %s, want it forwarded", rec.Code, rec.Body)
+	}
+	pushTo(p, "POST", `{"kind":"status-update","taskId":"t","status":{"state":"working"}}`)
+	if n := len(messagesOf(t, st, trace.ID)); n != 0 || seen != 0 {
+		t.Errorf("recorded %d and analyzed %d messages while paused", n, seen)
+	}
+
+	control(t, p, "resume")
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":2,"method":"message/send"}`)
+	if n := len(messagesOf(t, st, trace.ID)); n != 2 || seen != 2 {
+		t.Errorf("recorded %d and analyzed %d messages after resuming, want 2", n, seen)
+	}
+
+	if fmt.Sprint(changes) != "[true false]" {
+		t.Errorf("OnPause called with %v, want each change once", changes)
+	}
+	if rec := serveLocal(p, httptest.NewRequest("POST", "/api/control", strings.NewReader(`{"action":"stop"}`))); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown action got %d, want 400", rec.Code)
+	}
+}
+
+func TestOversizedResponseStreamedAndTruncated(t *testing.T) {
+	body := `{"jsonrpc":"2.0","id":1,"result":{"text":"` + strings.Repeat("x", 10000) + `"}}`
+	upstream := newJSONUpstream(t, body)
+	p, st, trace := newTestProxy(t, Config{MaxResponseSize: 1000})
+
+	rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("client got %d with %d bytes, want all %d", rec.Code, rec.Body.Len(), len(body))
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if resp := messages[1]; !resp.Truncated || len(resp.Body) != 1000 || resp.Size != int64(len(body)) {
+		t.Errorf("recorded truncated %v with %d of %d bytes, want the first 1000 of %d", resp.Truncated, len(resp.Body), resp.Size, len(body))
+	}
+
+	// Responses within the limit are recorded whole
+	small := newJSONUpstream(t, `{"jsonrpc":"2.0","id":2,"result":{}}`)
+	sendJSON(p, small.URL, `{"jsonrpc":"2.0","id":2,"method":"message/send"}`)
+	if resp := messagesOf(t, st, trace.ID)[3]; resp.Truncated {
+		t.Error("small response marked truncated")
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ReadOnlyHandler refuses requests that would change state, such as
+// starting a trace, adding an annotation or pausing recording, with 403.
+// Reads and CORS preflights of the API, and the UI, are served as usual.
+func ReadOnlyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && !isSafeMethod(r.Method) {
+			setCORSHeaders(w)
+			http.Error(w, "a2a-trace: read-only mode", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSafeMethod reports whether an HTTP method only reads
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
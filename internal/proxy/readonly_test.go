@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyRefusesChanges(t *testing.T) {
+	p, st, _ := newTestProxy(t, Config{ReadOnly: true})
+
+	for _, tt := range []struct{ method, path, body string }{
+		{"POST", "/api/traces", `{"name":"other"}`},
+		{"POST", "/api/replay", `{"message_id":"req-1"}`},
+		{"POST", "/api/control", `{"action":"pause"}`},
+		{"POST", "/api/annotations", `{"label":"deploy"}`},
+		{"DELETE", "/api/traces", ""},
+	} {
+		rec := serveLocal(p, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s answered %d, want 403", tt.method, tt.path, rec.Code)
+		}
+	}
+	if traces, err := st.ListTraces(); err != nil || len(traces) != 1 {
+		t.Errorf("got %d traces after refused requests, want 1", len(traces))
+	}
+
+	for _, path := range []string{"/api/messages", "/api/traces", "/api/annotations", "/api/summary"} {
+		if rec := serveLocal(p, httptest.NewRequest("GET", path, nil)); rec.Code != http.StatusOK {
+			t.Errorf("GET %s answered %d, want 200", path, rec.Code)
+		}
+	}
+	if rec := serveLocal(p, httptest.NewRequest("OPTIONS", "/api/traces", nil)); rec.Code == http.StatusForbidden {
+		t.Error("CORS preflight refused")
+	}
+}
+
+func TestWritableByDefault(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{})
+
+	rec := serveLocal(p, httptest.NewRequest("POST", "/api/annotations", strings.NewReader(`{"label":"deploy"}`)))
+	if rec.Code == http.StatusForbidden {
+		t.Error("annotation refused without --read-only")
+	}
+}
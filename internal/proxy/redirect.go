@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// maxRedirects matches the limit of Go's default redirect policy
+const maxRedirects = 10
+
+// redirectContextKey carries the captured request to checkRedirect
+type redirectContextKey struct{}
+
+// redirectTrace is the captured request a chain of redirects belongs to
+type redirectTrace struct {
+	request *store.Message
+	start   time.Time
+}
+
+// withRedirectTrace attaches the captured request to an outgoing request so
+// the redirects it follows can be recorded against it
+func withRedirectTrace(req *http.Request, reqMsg *store.Message, start time.Time) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), redirectContextKey{}, &redirectTrace{
+		request: reqMsg,
+		start:   start,
+	}))
+}
+
+// checkRedirect records each redirect hop as a response to the captured
+// request, flagged Redirect, before following it. With following disabled, the redirect itself
+// is returned to the client.
+func (p *Proxy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.noFollowRedirects {
+		return http.ErrUseLastResponse
+	}
+
+	if trace, ok := req.Context().Value(redirectContextKey{}).(*redirectTrace); ok && req.Response != nil {
+		hop := p.interceptor.ParseResponse(req.Response, nil, trace.request, time.Since(trace.start))
+		hop.URL = req.Response.Request.URL.String()
+		hop.Redirect = true
+		p.saveMessage(hop)
+		if p.onMessage != nil {
+			p.onMessage(hop)
+		}
+	}
+
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRedirectingUpstream returns an agent redirecting /old to /new, where it
+// answers
+func newRedirectingUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	})
+	upstream := httptest.NewServer(mux)
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestRedirectHopsRecorded(t *testing.T) {
+	upstream := newRedirectingUpstream(t)
+	p, st, trace := newTestProxy(t, Config{})
+
+	rec := sendJSON(p, upstream.URL+"/old", `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d, want the redirect followed to a 200", rec.Code)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want the request, the hop and the response", len(messages))
+	}
+	req, hop, resp := messages[0], messages[1], messages[2]
+	if !hop.Redirect || hop.StatusCode != http.StatusTemporaryRedirect || hop.RequestID != req.ID {
+		t.Errorf("hop = %d redirect %v for %s, want a 307 for %s", hop.StatusCode, hop.Redirect, hop.RequestID, req.ID)
+	}
+	if !strings.HasSuffix(hop.URL, "/old") || !strings.Contains(hop.Headers, `"Location":"/new"`) {
+		t.Errorf("hop from %s with headers %s, want /old to /new", hop.URL, hop.Headers)
+	}
+	if resp.Redirect || resp.StatusCode != http.StatusOK || resp.RequestID != req.ID {
+		t.Errorf("final response = %d redirect %v", resp.StatusCode, resp.Redirect)
+	}
+}
+
+func TestNoFollowRedirects(t *testing.T) {
+	upstream := newRedirectingUpstream(t)
+	p, st, trace := newTestProxy(t, Config{NoFollowRedirects: true})
+
+	rec := sendJSON(p, upstream.URL+"/old", `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+	if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != "/new" {
+		t.Errorf("got %d to %q, want the 307 passed back", rec.Code, rec.Header().Get("Location"))
+	}
+	if messages := messagesOf(t, st, trace.ID); len(messages) != 2 || messages[1].StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("got %d messages, want the request and the 307", len(messages))
+	}
+}
@@ -0,0 +1,412 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// replayDroppedHeaders are recorded request headers not sent again on replay;
+// the transport sets them for the new connection
+var replayDroppedHeaders = []string{
+	"Connection", "Content-Length", "Keep-Alive", "Proxy-Connection",
+	"Proxy-Authorization", "Te", "Transfer-Encoding", "Upgrade", "Via",
+}
+
+// Replayer resends recorded requests to the agents they were sent to
+type Replayer struct {
+	client  *http.Client
+	prepare func(req *http.Request) // Called on each request before it's sent, if set
+}
+
+// ReplayResult is the response to a replayed request
+type ReplayResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+}
+
+// NewReplayer creates a Replayer sending requests through client, or
+// http.DefaultClient if nil
+func NewReplayer(client *http.Client) *Replayer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Replayer{client: client}
+}
+
+// Replay resends a recorded request with its original URL, headers and body
+func (r *Replayer) Replay(ctx context.Context, msg *store.Message) (*ReplayResult, error) {
+	if msg.Direction != "request" {
+		return nil, fmt.Errorf("message %s is a %s, not a request", msg.ID, msg.Direction)
+	}
+	if msg.Transport != "" {
+		// gRPC bodies are kept as summaries and push notifications were sent
+		// to a2a-trace itself, so neither can be sent again
+		return nil, fmt.Errorf("can't replay %s messages", msg.Transport)
+	}
+
+	body, err := loadBody(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Older traces didn't record the HTTP method; A2A calls are POSTs and
+	// agent card fetches are GETs without a body
+	method := msg.HTTPMethod
+	switch {
+	case method != "":
+	case msg.Preflight:
+		method = http.MethodOptions
+	case len(body) == 0:
+		method = http.MethodGet
+	default:
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, msg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var headers map[string]string
+	if msg.Headers != "" {
+		if err := json.Unmarshal([]byte(msg.Headers), &headers); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded headers: %w", err)
+		}
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	for _, key := range replayDroppedHeaders {
+		req.Header.Del(key)
+	}
+
+	if r.prepare != nil {
+		r.prepare(req)
+	}
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &ReplayResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       respBody,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// replayRequest is the body of POST /api/replay
+type replayRequest struct {
+	MessageID    string          `json:"message_id"`
+	BodyOverride json.RawMessage `json:"body_override,omitempty"` // Sent instead of the recorded body
+}
+
+// replayResponse is the result of POST /api/replay
+type replayResponse struct {
+	RequestID          string        `json:"request_id,omitempty"`  // The replayed request, recorded in the current trace unless recording is paused
+	ResponseID         string        `json:"response_id,omitempty"` // Its response
+	OriginalResponseID string        `json:"original_response_id,omitempty"`
+	Diff               *ResponseDiff `json:"diff"`
+}
+
+// handleReplay resends a recorded request, optionally with another body,
+// records the new exchange in the current trace unless recording is paused and
+// returns how its response differs from the original's. An unreachable agent
+// is reported with 502.
+func (p *Proxy) handleReplay(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w)
+	switch r.Method {
+	case "OPTIONS":
+		return
+	case "POST":
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == "" {
+		http.Error(w, `expected {"message_id": "..."}`, http.StatusBadRequest)
+		return
+	}
+
+	original, err := p.store.GetMessageContext(r.Context(), req.MessageID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if original == nil {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+	if original.Direction != "request" || original.Transport != "" {
+		http.Error(w, "only recorded HTTP requests can be replayed", http.StatusBadRequest)
+		return
+	}
+
+	originalResp, err := p.store.GetResponseContext(r.Context(), original.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if originalResp != nil {
+		body, err := loadBody(originalResp)
+		if err != nil {
+			http.Error(w, "recorded response body is no longer available", http.StatusGone)
+			return
+		}
+		originalResp.Body = string(body)
+	}
+
+	body, err := loadBody(original)
+	if err != nil {
+		http.Error(w, "recorded request body is no longer available", http.StatusGone)
+		return
+	}
+	if len(req.BodyOverride) > 0 {
+		body = req.BodyOverride
+	}
+
+	recording := !p.Paused()
+	reqMsg := p.newReplayMessage(original, body)
+	if recording {
+		p.offloadBody(reqMsg)
+		p.saveMessage(reqMsg)
+		if p.onMessage != nil {
+			p.onMessage(reqMsg)
+		}
+	}
+
+	// The replay is sent with the configured upstream credentials, like
+	// forwarded requests
+	replayer := NewReplayer(p.client)
+	replayer.prepare = func(req *http.Request) {
+		p.injectUpstreamAuth(req, reqMsg.URL)
+	}
+
+	var respMsg *store.Message
+	status := http.StatusOK
+	result, err := replayer.Replay(r.Context(), reqMsg)
+	if err != nil {
+		respMsg = &store.Message{
+			TraceID:   reqMsg.TraceID,
+			Timestamp: p.interceptor.Clock.Now(),
+			Direction: "response",
+			URL:       reqMsg.URL,
+			Error:     err.Error(),
+			RequestID: reqMsg.ID,
+			Source:    reqMsg.Source,
+		}
+		status = http.StatusBadGateway
+	} else {
+		resp := &http.Response{StatusCode: result.StatusCode, Header: result.Header}
+		respMsg = p.interceptor.ParseResponse(resp, result.Body, reqMsg, result.Duration)
+	}
+	out := replayResponse{Diff: CompareResponses(originalResp, respMsg)}
+	if recording {
+		p.offloadBody(respMsg)
+		p.saveMessage(respMsg)
+		if p.onMessage != nil {
+			p.onMessage(respMsg)
+		}
+		out.RequestID, out.ResponseID = reqMsg.ID, respMsg.ID
+	}
+	if originalResp != nil {
+		out.OriginalResponseID = originalResp.ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json, _ := json.Marshal(out)
+	w.Write(json)
+}
+
+// newReplayMessage returns a request message for resending original with
+// body in the current trace. A changed body may call another method.
+func (p *Proxy) newReplayMessage(original *store.Message, body []byte) *store.Message {
+	msg := *original
+	msg.ID = p.interceptor.NewID()
+	msg.Seq = 0
+	msg.TraceID = p.TraceID()
+	msg.Timestamp = p.interceptor.Clock.Now()
+	msg.Body, msg.BodyPath, msg.BodyURL = string(body), "", ""
+	msg.Size = int64(len(body))
+	msg.RetryOf, msg.ParentID = "", ""
+
+	var a2aReq store.A2ARequest
+	if err := json.Unmarshal(body, &a2aReq); err == nil && a2aReq.Method != "" {
+		msg.Method = a2aReq.Method
+		msg.MethodLabel = p.interceptor.methodLabel(a2aReq.Method)
+		msg.IDType = jsonRPCIDType(body)
+		msg.RequestID = ""
+		if a2aReq.ID != nil {
+			msg.RequestID = formatRequestID(a2aReq.ID)
+		}
+		if original.CorrelationID == original.RequestID {
+			msg.CorrelationID = msg.RequestID
+		}
+	}
+	return &msg
+}
+
+// loadBody returns a message's body, reading it from the blob store if it was
+// offloaded there
+func loadBody(msg *store.Message) ([]byte, error) {
+	if msg.BodyPath == "" {
+		return []byte(msg.Body), nil
+	}
+	return os.ReadFile(msg.BodyPath)
+}
+
+// ResponseDiff compares a replayed response with the recorded one
+type ResponseDiff struct {
+	OriginalStatus     int         `json:"original_status"` // 0 if the original got no response
+	ReplayStatus       int         `json:"replay_status"`
+	StatusChanged      bool        `json:"status_changed"`
+	OriginalError      string      `json:"original_error,omitempty"`
+	ReplayError        string      `json:"replay_error,omitempty"`
+	OriginalDurationMs int64       `json:"original_duration_ms"`
+	ReplayDurationMs   int64       `json:"replay_duration_ms"`
+	DurationDeltaMs    int64       `json:"duration_delta_ms"` // Replay minus original; negative when faster
+	Fields             []FieldDiff `json:"fields"`            // Empty unless both got a response
+}
+
+// FieldDiff is one field of a JSON body that differs between two responses
+type FieldDiff struct {
+	Path   string      `json:"path"`   // e.g. "result.status.state" or "result.artifacts[0]"; "" for a whole non-JSON body
+	Change string      `json:"change"` // "added", "removed" or "changed"
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+}
+
+// CompareResponses diffs a replayed response against the original one, or
+// against no response if original is nil. Bodies are compared as they are
+// in Body, so offloaded ones must be loaded first, and only when both
+// requests got a response.
+func CompareResponses(original, replayed *store.Message) *ResponseDiff {
+	if original == nil {
+		original = &store.Message{}
+	}
+	diff := &ResponseDiff{
+		OriginalStatus:     original.StatusCode,
+		ReplayStatus:       replayed.StatusCode,
+		StatusChanged:      original.StatusCode != replayed.StatusCode,
+		OriginalError:      original.Error,
+		ReplayError:        replayed.Error,
+		OriginalDurationMs: original.DurationMs,
+		ReplayDurationMs:   replayed.DurationMs,
+		DurationDeltaMs:    replayed.DurationMs - original.DurationMs,
+	}
+	if original.StatusCode != 0 && replayed.StatusCode != 0 {
+		diff.Fields = DiffFields(original.Body, replayed.Body)
+	}
+	return diff
+}
+
+// DiffFields compares two JSON bodies field by field, returning the fields
+// added, removed or changed in newBody, sorted by path. Bodies that aren't
+// both JSON are compared whole.
+func DiffFields(oldBody, newBody string) []FieldDiff {
+	oldValue, oldErr := decodeJSON(oldBody)
+	newValue, newErr := decodeJSON(newBody)
+	if oldErr != nil || newErr != nil {
+		if oldBody == newBody {
+			return nil
+		}
+		return []FieldDiff{{Change: "changed", Old: oldBody, New: newBody}}
+	}
+
+	var diffs []FieldDiff
+	diffValues("", oldValue, newValue, &diffs)
+	return diffs
+}
+
+// decodeJSON parses a JSON body, keeping numbers as written
+func decodeJSON(body string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diffValues appends the differences between two decoded JSON values at path
+func diffValues(path string, oldValue, newValue interface{}, diffs *[]FieldDiff) {
+	switch oldTyped := oldValue.(type) {
+	case map[string]interface{}:
+		if newTyped, ok := newValue.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(oldTyped)+len(newTyped))
+			for key := range oldTyped {
+				keys = append(keys, key)
+			}
+			for key := range newTyped {
+				if _, ok := oldTyped[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				fieldPath := key
+				if path != "" {
+					fieldPath = path + "." + key
+				}
+				oldField, inOld := oldTyped[key]
+				newField, inNew := newTyped[key]
+				switch {
+				case !inOld:
+					*diffs = append(*diffs, FieldDiff{Path: fieldPath, Change: "added", New: newField})
+				case !inNew:
+					*diffs = append(*diffs, FieldDiff{Path: fieldPath, Change: "removed", Old: oldField})
+				default:
+					diffValues(fieldPath, oldField, newField, diffs)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if newTyped, ok := newValue.([]interface{}); ok {
+			for i := 0; i < max(len(oldTyped), len(newTyped)); i++ {
+				itemPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(oldTyped):
+					*diffs = append(*diffs, FieldDiff{Path: itemPath, Change: "added", New: newTyped[i]})
+				case i >= len(newTyped):
+					*diffs = append(*diffs, FieldDiff{Path: itemPath, Change: "removed", Old: oldTyped[i]})
+				default:
+					diffValues(itemPath, oldTyped[i], newTyped[i], diffs)
+				}
+			}
+			return
+		}
+	}
+
+	// Scalars, or values whose type changed
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Change: "changed", Old: oldValue, New: newValue})
+	}
+}
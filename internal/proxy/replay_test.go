@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestPutCapturedAndReplayedAsPut(t *testing.T) {
+	type call struct{ method, body string }
+	calls := make(chan call, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls <- call{r.Method, string(body)}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":5,"result":{}}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	p, st, trace := newTestProxy(t, Config{CaptureMethods: []string{"PUT"}})
+	body := `{"jsonrpc":"2.0","id":5,"method":"tasks/send","params":{}}`
+	req := httptest.NewRequest("PUT", upstream.URL+"/rpc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	p.handleProxy(httptest.NewRecorder(), req)
+	<-calls
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("recorded %d messages, want the PUT and its response", len(messages))
+	}
+	recorded := messages[0]
+	if recorded.HTTPMethod != "PUT" || recorded.Method != "tasks/send" {
+		t.Fatalf("recorded %s %q, want PUT tasks/send", recorded.HTTPMethod, recorded.Method)
+	}
+
+	if _, err := NewReplayer(nil).Replay(context.Background(), recorded); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-calls; got.method != "PUT" || got.body != body {
+		t.Errorf("replayed as %s %s, want PUT %s", got.method, got.body, body)
+	}
+}
+
+// recordExchange sends body through the proxy to url, returning the recorded
+// request
+func recordExchange(t *testing.T, p *Proxy, st *store.Store, traceID, url, body string) *store.Message {
+	t.Helper()
+	sendJSON(p, url, body)
+	for _, msg := range messagesOf(t, st, traceID) {
+		if msg.Direction == "request" {
+			return msg
+		}
+	}
+	t.Fatal("request not recorded")
+	return nil
+}
+
+// postReplay calls POST /api/replay
+func postReplay(t *testing.T, p *Proxy, body string) (*httptest.ResponseRecorder, replayResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.handleReplay(rec, httptest.NewRequest("POST", "/api/replay", strings.NewReader(body)))
+	var out replayResponse
+	if rec.Code == http.StatusOK || rec.Code == http.StatusBadGateway {
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+	}
+	return rec, out
+}
+
+func TestReplayDiffHighlightsChangedField(t *testing.T) {
+	var sends atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		state := "completed"
+		if sends.Add(1) > 1 {
+			state = "failed"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"id":"t1","status":{"state":%q}}}`, state)
+	}))
+	t.Cleanup(upstream.Close)
+	p, st, trace := newTestProxy(t, Config{})
+	original := recordExchange(t, p, st, trace.ID, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`)
+
+	rec, out := postReplay(t, p, fmt.Sprintf(`{"message_id":%q}`, original.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	want := []FieldDiff{{Path: "result.status.state", Change: "changed", Old: "completed", New: "failed"}}
+	if !reflect.DeepEqual(out.Diff.Fields, want) {
+		t.Errorf("fields %+v, want %+v", out.Diff.Fields, want)
+	}
+	if out.Diff.StatusChanged {
+		t.Error("status reported changed")
+	}
+	if out.RequestID == "" || out.ResponseID == "" || out.OriginalResponseID == "" {
+		t.Errorf("missing IDs in %+v", out)
+	}
+	if n := len(messagesOf(t, st, trace.ID)); n != 4 {
+		t.Errorf("%d messages recorded, want the original exchange and the replay", n)
+	}
+}
+
+func TestReplayBodyOverride(t *testing.T) {
+	bodies := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	t.Cleanup(upstream.Close)
+	p, st, trace := newTestProxy(t, Config{})
+	original := recordExchange(t, p, st, trace.ID, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`)
+	<-bodies
+
+	override := `{"jsonrpc":"2.0","id":2,"method":"tasks/cancel"}`
+	rec, out := postReplay(t, p, fmt.Sprintf(`{"message_id":%q,"body_override":%s}`, original.ID, override))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if got := <-bodies; got != override {
+		t.Errorf("agent got %s, want the override", got)
+	}
+	replayed, err := st.GetMessage(out.RequestID)
+	if err != nil || replayed == nil {
+		t.Fatalf("replayed request not recorded: %v", err)
+	}
+	if replayed.Method != "tasks/cancel" || replayed.RequestID != "2" {
+		t.Errorf("replay recorded as %s id %s, want tasks/cancel id 2", replayed.Method, replayed.RequestID)
+	}
+}
+
+func TestReplayUnreachableAgent(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	p, st, trace := newTestProxy(t, Config{})
+	original := recordExchange(t, p, st, trace.ID, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`)
+	upstream.Close()
+
+	rec, out := postReplay(t, p, fmt.Sprintf(`{"message_id":%q}`, original.ID))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status %d, want 502", rec.Code)
+	}
+	if out.Diff.ReplayError == "" || out.Diff.ReplayStatus != 0 || out.Diff.Fields != nil {
+		t.Errorf("diff %+v, want the replay's error and no fields", out.Diff)
+	}
+	resp, err := st.GetMessage(out.ResponseID)
+	if err != nil || resp == nil || resp.Error == "" {
+		t.Errorf("failed response not recorded with its error: %+v, %v", resp, err)
+	}
+}
+
+func TestReplayWhilePausedNotRecorded(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	p, st, trace := newTestProxy(t, Config{})
+	original := recordExchange(t, p, st, trace.ID, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`)
+	p.SetPaused(true)
+
+	rec, out := postReplay(t, p, fmt.Sprintf(`{"message_id":%q}`, original.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if out.RequestID != "" || out.ResponseID != "" || out.Diff == nil {
+		t.Errorf("got %+v, want a diff without recorded IDs", out)
+	}
+	if n := len(messagesOf(t, st, trace.ID)); n != 2 {
+		t.Errorf("%d messages recorded, want only the original exchange", n)
+	}
+}
+
+func TestReplaySendsUpstreamAuth(t *testing.T) {
+	users := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		user, pass, _ := r.BasicAuth()
+		users <- user + ":" + pass
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	t.Cleanup(upstream.Close)
+	u, _ := url.Parse(upstream.URL)
+	p, st, trace := newTestProxy(t, Config{UpstreamAuth: []*UpstreamAuth{mustParseAuth(t, u.Host+"=agent:s3cret")}})
+	original := recordExchange(t, p, st, trace.ID, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`)
+	<-users
+
+	if rec, _ := postReplay(t, p, fmt.Sprintf(`{"message_id":%q}`, original.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if got := <-users; got != "agent:s3cret" {
+		t.Errorf("replay sent credentials %q, want the configured ones", got)
+	}
+}
+
+func TestReplayRejectsBadRequests(t *testing.T) {
+	p, _, _ := newTestProxy(t, Config{})
+	for body, want := range map[string]int{
+		`{}`:                    http.StatusBadRequest,
+		`not json`:              http.StatusBadRequest,
+		`{"message_id":"nope"}`: http.StatusNotFound,
+	} {
+		if rec, _ := postReplay(t, p, body); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", body, rec.Code, want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	p.handleReplay(rec, httptest.NewRequest("GET", "/api/replay", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", rec.Code)
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []FieldDiff
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, nil},
+		{"added and removed", `{"a":1}`, `{"b":2}`, []FieldDiff{
+			{Path: "a", Change: "removed", Old: json.Number("1")},
+			{Path: "b", Change: "added", New: json.Number("2")},
+		}},
+		{"array item", `{"a":[1,2]}`, `{"a":[1,3,4]}`, []FieldDiff{
+			{Path: "a[1]", Change: "changed", Old: json.Number("2"), New: json.Number("3")},
+			{Path: "a[2]", Change: "added", New: json.Number("4")},
+		}},
+		{"type changed", `{"a":{"b":1}}`, `{"a":"x"}`, []FieldDiff{
+			{Path: "a", Change: "changed", Old: map[string]interface{}{"b": json.Number("1")}, New: "x"},
+		}},
+		{"not JSON", `ok`, `Bad Gateway`, []FieldDiff{{Change: "changed", Old: "ok", New: "Bad Gateway"}}},
+	}
+	for _, tt := range tests {
+		if got := DiffFields(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// writePEM writes a PEM block to a file in dir, returning its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert writes a self-signed client certificate and its key to dir,
+// returning their paths and the certificate
+func newClientCert(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "agent-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER), cert
+}
+
+func TestProxyPresentsClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := newClientCert(t, dir)
+
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	upstream.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	upstream.Config.ErrorLog = log.New(io.Discard, "", 0) // The rejected handshake
+	upstream.StartTLS()
+	defer upstream.Close()
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", upstream.Certificate().Raw)
+
+	tlsConfig, err := LoadTLSConfig(TLSOptions{CACert: caPath, ClientCert: certPath, ClientKey: keyPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, _ := newTestProxy(t, Config{TLSConfig: tlsConfig})
+	if rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`); rec.Code != http.StatusOK {
+		t.Errorf("with a client certificate got %d, want 200", rec.Code)
+	}
+
+	// Trusting the server isn't enough without a certificate to present
+	tlsConfig, err = LoadTLSConfig(TLSOptions{CACert: caPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, _ = newTestProxy(t, Config{TLSConfig: tlsConfig})
+	if rec := sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`); rec.Code == http.StatusOK {
+		t.Error("without a client certificate got 200")
+	}
+}
+
+func TestLoadTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := newClientCert(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{"missing CA file", TLSOptions{CACert: filepath.Join(dir, "missing.pem")}},
+		{"CA file without certificates", TLSOptions{CACert: notPEM}},
+		{"certificate without key", TLSOptions{ClientCert: certPath}},
+		{"key without certificate", TLSOptions{ClientKey: keyPath}},
+		{"mismatched key", TLSOptions{ClientCert: certPath, ClientKey: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadTLSConfig(tt.opts); err == nil {
+				t.Error("loaded without error")
+			}
+		})
+	}
+
+	cfg, err := LoadTLSConfig(TLSOptions{Insecure: true})
+	if err != nil || !cfg.InsecureSkipVerify {
+		t.Errorf("--insecure gave %+v, %v", cfg, err)
+	}
+}
+
+func TestTLSInfoRecorded(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer upstream.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(upstream.Certificate())
+	p, st, trace := newTestProxy(t, Config{TLSConfig: &tls.Config{RootCAs: roots}})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send"}`)
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	var info store.TLSConnection
+	if err := json.Unmarshal([]byte(messages[1].TLSInfo), &info); err != nil {
+		t.Fatalf("TLSInfo %q: %v", messages[1].TLSInfo, err)
+	}
+	if info.Version != "TLS 1.3" || info.CipherSuite == "" {
+		t.Errorf("recorded %s with %q, want TLS 1.3 and its cipher suite", info.Version, info.CipherSuite)
+	}
+	if !info.NotAfter.Equal(upstream.Certificate().NotAfter) {
+		t.Errorf("NotAfter = %v, want the server certificate's %v", info.NotAfter, upstream.Certificate().NotAfter)
+	}
+
+	// Plain HTTP has none
+	plain := newJSONUpstream(t, `{"jsonrpc":"2.0","id":2,"result":{}}`)
+	sendJSON(p, plain.URL, `{"jsonrpc":"2.0","id":2,"method":"message/send"}`)
+	if got := messagesOf(t, st, trace.ID)[3].TLSInfo; got != "" {
+		t.Errorf("plain HTTP response has TLSInfo %s", got)
+	}
+}
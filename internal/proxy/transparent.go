@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// originalDstKey is the context key holding the address a redirected
+// connection was originally sent to
+type originalDstKey struct{}
+
+// transparentListener accepts connections redirected to the proxy by the
+// firewall, e.g. with an iptables REDIRECT rule, from clients that ignore
+// HTTP_PROXY. Each connection remembers where it was originally headed.
+type transparentListener struct {
+	net.Listener
+}
+
+// transparentConn is a connection accepted in transparent mode
+type transparentConn struct {
+	net.Conn
+	originalDst string // host:port the client connected to, or "" if it connected to the proxy itself
+}
+
+// Accept waits for a connection and recovers its original destination
+func (l transparentListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections made straight to the proxy, e.g. for the API or UI, have
+	// no other destination
+	dst, err := originalDst(conn)
+	if err != nil || dst == conn.LocalAddr().String() {
+		dst = ""
+	}
+	return &transparentConn{Conn: conn, originalDst: dst}, nil
+}
+
+// withOriginalDst is the server's ConnContext, making a redirected
+// connection's original destination available to its requests
+func withOriginalDst(ctx context.Context, conn net.Conn) context.Context {
+	if tc, ok := conn.(*transparentConn); ok && tc.originalDst != "" {
+		return context.WithValue(ctx, originalDstKey{}, tc.originalDst)
+	}
+	return ctx
+}
+
+// originalDstOf returns where a redirected request was originally sent, or ""
+func originalDstOf(r *http.Request) string {
+	dst, _ := r.Context().Value(originalDstKey{}).(string)
+	return dst
+}
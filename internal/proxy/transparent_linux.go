@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// soOriginalDst is SO_ORIGINAL_DST from linux/netfilter_ipv4.h, and
+// IP6T_SO_ORIGINAL_DST for IPv6, which has the same value
+const soOriginalDst = 80
+
+// originalDst reads the address a connection redirected by netfilter was
+// sent to, from the SO_ORIGINAL_DST socket option
+func originalDst(conn net.Conn) (string, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return "", errors.New("not a TCP connection")
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+
+	local, _ := conn.LocalAddr().(*net.TCPAddr)
+	ipv4 := local != nil && local.IP.To4() != nil
+
+	var dst string
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if ipv4 {
+			// The option fills a sockaddr_in; IPv6Mreq is just a buffer
+			// large enough for it
+			mreq, err := syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+			if err != nil {
+				sockErr = err
+				return
+			}
+			addr := mreq.Multiaddr
+			port := binary.BigEndian.Uint16(addr[2:4])
+			dst = net.JoinHostPort(net.IP(addr[4:8]).String(), strconv.Itoa(int(port)))
+			return
+		}
+
+		// Likewise for a sockaddr_in6
+		info, err := syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.IPPROTO_IPV6, soOriginalDst)
+		if err != nil {
+			sockErr = err
+			return
+		}
+		var port [2]byte // In network byte order
+		binary.NativeEndian.PutUint16(port[:], info.Addr.Port)
+		dst = net.JoinHostPort(net.IP(info.Addr.Addr[:]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	})
+	if err != nil {
+		return "", err
+	}
+	return dst, sockErr
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package proxy
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestOriginalDstOfDirectConnection(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Without NAT, conntrack reports the address actually connected to
+	dst, err := originalDst(conn)
+	if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOPROTOOPT) {
+		t.Skipf("connection tracking unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst != ln.Addr().String() {
+		t.Errorf("original destination %s, want %s", dst, ln.Addr())
+	}
+
+	if _, err := originalDst(&net.UnixConn{}); err == nil {
+		t.Error("read an original destination from a unix socket")
+	}
+}
+
+func TestTransparentListenerLeavesDirectConnections(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl := transparentListener{ln}
+	defer tl.Close()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := tl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Connected to the proxy itself, e.g. for the UI, so not forwarded
+	if tc, ok := conn.(*transparentConn); !ok || tc.originalDst != "" {
+		t.Errorf("got %#v, want a transparentConn with no original destination", conn)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package proxy
+
+import (
+	"errors"
+	"net"
+)
+
+// originalDst needs netfilter's SO_ORIGINAL_DST, which only Linux has
+func originalDst(conn net.Conn) (string, error) {
+	return "", errors.New("transparent mode is only supported on Linux")
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// redirectListener stands in for a transparentListener behind an iptables
+// REDIRECT rule, sending every connection's original destination to dst
+type redirectListener struct {
+	net.Listener
+	dst string
+}
+
+func (l redirectListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &transparentConn{Conn: conn, originalDst: l.dst}, nil
+}
+
+func TestRedirectedConnectionForwardedToOriginalDst(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	dst := strings.TrimPrefix(upstream.URL, "http://")
+	p, st, trace := newTestProxy(t, Config{})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = p.Serve(redirectListener{ln, dst}) }()
+	t.Cleanup(func() { _ = p.Stop() })
+
+	// A client unaware of the proxy, connecting to what it thinks is the
+	// agent; /api/ paths belong to the agent too
+	req, _ := http.NewRequest("POST", "http://"+ln.Addr().String()+"/api/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tasks/get"}`))
+	req.Host = "agent.example"
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"result"`) {
+		t.Fatalf("got %d %s, want the agent's response", resp.StatusCode, body)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("recorded %d messages, want 2", len(messages))
+	}
+	if u, _ := url.Parse(messages[0].URL); u == nil || u.Host != dst || u.Path != "/api/rpc" {
+		t.Errorf("request recorded to %s, want the original destination %s", messages[0].URL, dst)
+	}
+	if messages[0].ToAgent != dst {
+		t.Errorf("request recorded as to %q, want %s", messages[0].ToAgent, dst)
+	}
+}
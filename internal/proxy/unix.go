@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixTransport forwards requests for unix:// targets. A target names the
+// socket followed by the HTTP path, e.g. unix:///run/agent.sock/a2a sends
+// POST /a2a to the agent listening on /run/agent.sock.
+type unixTransport struct {
+	transport *http.Transport
+}
+
+func newUnixTransport() *unixTransport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return &unixTransport{
+		transport: &http.Transport{
+			// The socket path travels hex-encoded in the host, so idle
+			// connections are only reused for the same socket
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				socketPath, err := hex.DecodeString(host)
+				if err != nil {
+					return nil, fmt.Errorf("invalid unix socket address %q", addr)
+				}
+				return dialer.DialContext(ctx, "unix", string(socketPath))
+			},
+			MaxIdleConns:    100,
+			IdleConnTimeout: 90 * time.Second,
+		},
+	}
+}
+
+// RoundTrip sends a unix:// request over its socket
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socketPath, path, err := splitUnixTarget(req.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = hex.EncodeToString([]byte(socketPath))
+	out.URL.Path = path
+	out.URL.RawPath = ""
+	out.Host = "localhost"
+
+	return t.transport.RoundTrip(out)
+}
+
+// splitUnixTarget splits the path of a unix:// URL into the socket file and
+// the HTTP path that follows it, by finding the first path prefix that is a
+// socket
+func splitUnixTarget(fullPath string) (socketPath, path string, err error) {
+	for i := 1; i <= len(fullPath); i++ {
+		if i < len(fullPath) && fullPath[i] != '/' {
+			continue
+		}
+		info, statErr := os.Stat(fullPath[:i])
+		if statErr != nil {
+			break
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			path = fullPath[i:]
+			if path == "" {
+				path = "/"
+			}
+			return fullPath[:i], path, nil
+		}
+	}
+	return "", "", fmt.Errorf("no unix socket found in %q", fullPath)
+}
+
+// listenUnix listens on a unix socket, replacing a stale socket file left by
+// an earlier run
+func listenUnix(socketPath string) (net.Listener, error) {
+	if info, err := os.Stat(socketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", socketPath)
+		}
+		_ = os.Remove(socketPath)
+	}
+	return net.Listen("unix", socketPath)
+}
+
+// socketPathFromURL returns the socket path of a unix:// URL for use as the
+// agent identifier, or "" for other URLs
+func socketPathFromURL(rawURL string) string {
+	rest, ok := strings.CutPrefix(rawURL, "unix://")
+	if !ok {
+		return ""
+	}
+	if socketPath, _, err := splitUnixTarget(rest); err == nil {
+		return socketPath
+	}
+	return rest
+}
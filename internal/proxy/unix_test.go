@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// shortTempDir returns a temporary directory with a path short enough for
+// unix sockets
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "a2at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestProxyOverUnixSockets(t *testing.T) {
+	dir := shortTempDir(t)
+
+	// An agent listening on a unix socket
+	agentSocket := filepath.Join(dir, "agent.sock")
+	agentLn, err := net.Listen("unix", agentSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"path":%q}}`, r.URL.Path)
+	})}
+	go func() { _ = agent.Serve(agentLn) }()
+	defer agent.Close()
+
+	// The proxy, listening on a unix socket too
+	p, st, trace := newTestProxy(t, Config{})
+	proxySocket := filepath.Join(dir, "proxy.sock")
+	proxyLn, err := listenUnix(proxySocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = p.Serve(proxyLn) }()
+	defer p.Stop()
+
+	conn, err := net.Dial("unix", proxySocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	target := "unix://" + agentSocket + "/a2a"
+	body := `{"jsonrpc":"2.0","id":1,"method":"message/send"}`
+	fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", target, len(body), body)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(respBody), `"path":"/a2a"`) {
+		t.Errorf("got %d %q, want the agent's response for /a2a", resp.StatusCode, respBody)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if messages[0].URL != target || messages[0].ToAgent != agentSocket || messages[0].Method != "message/send" {
+		t.Errorf("request recorded with URL %q, agent %q, method %q", messages[0].URL, messages[0].ToAgent, messages[0].Method)
+	}
+	if messages[1].StatusCode != http.StatusOK {
+		t.Errorf("response recorded with status %d", messages[1].StatusCode)
+	}
+}
+
+func TestSplitUnixTarget(t *testing.T) {
+	dir := shortTempDir(t)
+	socket := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	for fullPath, wantPath := range map[string]string{
+		socket:                socket + "|/",
+		socket + "/a2a":       socket + "|/a2a",
+		socket + "/v1/rpc?x=": socket + "|/v1/rpc?x=",
+	} {
+		socketPath, path, err := splitUnixTarget(fullPath)
+		if err != nil || socketPath+"|"+path != wantPath {
+			t.Errorf("splitUnixTarget(%q) = %q, %q, %v", fullPath, socketPath, path, err)
+		}
+	}
+
+	if _, _, err := splitUnixTarget(filepath.Join(dir, "missing.sock", "a2a")); err == nil {
+		t.Error("expected an error without a socket")
+	}
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+const (
+	// pushMethod is recorded as the method of push notifications that aren't
+	// JSON-RPC requests themselves
+	pushMethod = "push/notification"
+	// maxTrackedTasks bounds the task ID -> request map used to correlate
+	// push notifications
+	maxTrackedTasks = 4096
+	// maxPushBodySize bounds the push notification bodies read
+	maxPushBodySize = 10 << 20
+)
+
+// recordTask remembers the request that started a task; the first request
+// to mention a task wins
+func (i *Interceptor) recordTask(taskID, messageID string) {
+	if taskID == "" {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.tasks[taskID]; !ok && len(i.tasks) < maxTrackedTasks {
+		i.tasks[taskID] = messageID
+	}
+}
+
+// taskRequest returns the request that started a task, or ""
+func (i *Interceptor) taskRequest(taskID string) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.tasks[taskID]
+}
+
+// ParsePushNotification parses a push notification an agent sent to the
+// webhook capture listener. It is linked to the request that started its
+// task through RequestID.
+func (i *Interceptor) ParsePushNotification(r *http.Request, body []byte, traceID string) *store.Message {
+	msg := &store.Message{
+		ID:             i.NewID(),
+		TraceID:        traceID,
+		Timestamp:      i.Clock.Now(),
+		Direction:      "request",
+		URL:            r.URL.String(),
+		ContentType:    r.Header.Get("Content-Type"),
+		Size:           int64(len(body)),
+		Body:           string(body),
+		Transport:      "webhook",
+		Method:         pushMethod,
+		IsNotification: true, // Agents don't wait on an answer
+	}
+
+	// The agent is the remote end; its listening port isn't known
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		msg.FromAgent = host
+	}
+
+	headers := make(map[string]string)
+	for key, values := range r.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	headersJSON, _ := json.Marshal(headers)
+	msg.Headers = string(headersJSON)
+
+	// Payloads are a Task or status/artifact update event, either bare or
+	// wrapped in a JSON-RPC request
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return msg
+	}
+	if method := stringField(payload, "method"); method != "" {
+		msg.Method = method
+		msg.MethodLabel = i.methodLabel(method)
+	}
+	taskID := taskIDOf(payload)
+	if params, ok := payload["params"].(map[string]interface{}); ok && taskID == "" {
+		taskID = taskIDOf(params)
+	}
+
+	msg.CorrelationID = taskID
+	if taskID != "" {
+		msg.RequestID = i.taskRequest(taskID)
+	}
+	return msg
+}
+
+// taskIDOf returns the task a Task or task event object refers to, or ""
+func taskIDOf(obj map[string]interface{}) string {
+	if id := stringField(obj, "taskId"); id != "" {
+		return id
+	}
+	// Only Tasks carry their own id next to a status; messages have ids too
+	if _, ok := obj["status"]; ok || stringField(obj, "kind") == "task" {
+		return stringField(obj, "id")
+	}
+	return ""
+}
+
+// stringField returns a string field of a JSON object, or ""
+func stringField(obj map[string]interface{}, key string) string {
+	s, _ := obj[key].(string)
+	return s
+}
+
+// WebhookHandler records push notifications agents send to a2a-trace in
+// place of the traced process's webhook, answering each with 200
+func (p *Proxy) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if p.Paused() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPushBodySize))
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		msg := p.interceptor.ParsePushNotification(r, body, p.TraceID())
+		p.offloadBody(msg)
+		p.saveMessage(msg)
+		if p.onMessage != nil {
+			p.onMessage(msg)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// serveWebhook serves the webhook capture listener in the background until
+// Stop
+func (p *Proxy) serveWebhook(ln net.Listener) {
+	server := &http.Server{
+		Handler:      p.WebhookHandler(),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	p.serverMu.Lock()
+	p.webhookServer = server
+	p.serverMu.Unlock()
+
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("Webhook capture server error: %v", err)
+		}
+	}()
+	log.Printf("🔍 A2A Trace capturing push notifications on %s", ln.Addr())
+}
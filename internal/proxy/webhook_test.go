@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pushTo sends a push notification to the proxy's webhook capture handler
+func pushTo(p *Proxy, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://localhost:9100/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	p.WebhookHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPushNotificationCorrelatedToTask(t *testing.T) {
+	upstream := newJSONUpstream(t, `{"jsonrpc":"2.0","id":1,"result":{"kind":"task","id":"task-42","status":{"state":"submitted"}}}`)
+	p, st, trace := newTestProxy(t, Config{})
+
+	sendJSON(p, upstream.URL, `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{"message":{"role":"user","parts":[]}}}`)
+
+	// A bare status update event, then one wrapped in JSON-RPC
+	for _, body := range []string{
+		`{"kind":"status-update","taskId":"task-42","status":{"state":"working"}}`,
+		`{"jsonrpc":"2.0","method":"tasks/pushNotification","params":{"kind":"task","id":"task-42","status":{"state":"completed"}}}`,
+	} {
+		if rec := pushTo(p, "POST", body); rec.Code != http.StatusOK {
+			t.Fatalf("push got %d, want 200", rec.Code)
+		}
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want the call and two pushes", len(messages))
+	}
+	request := messages[0]
+	for i, method := range []string{pushMethod, "tasks/pushNotification"} {
+		push := messages[2+i]
+		if push.Transport != "webhook" || push.Method != method || !push.IsNotification {
+			t.Errorf("push %d recorded as %s %s", i, push.Transport, push.Method)
+		}
+		if push.RequestID != request.ID || push.CorrelationID != "task-42" {
+			t.Errorf("push %d linked to %s (%s), want %s", i, push.RequestID, push.CorrelationID, request.ID)
+		}
+	}
+}
+
+func TestPushNotificationForUnknownTask(t *testing.T) {
+	p, st, trace := newTestProxy(t, Config{})
+
+	pushTo(p, "POST", `{"kind":"status-update","taskId":"elsewhere","status":{"state":"working"}}`)
+	if rec := pushTo(p, "GET", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET got %d, want 405", rec.Code)
+	}
+
+	messages := messagesOf(t, st, trace.ID)
+	if len(messages) != 1 || messages[0].RequestID != "" || messages[0].CorrelationID != "elsewhere" {
+		t.Errorf("got %+v, want one uncorrelated push", messages)
+	}
+}
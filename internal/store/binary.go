@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// binaryMagic starts every binary trace export
+	binaryMagic = "A2AT"
+	// binaryVersion is the version of the binary format written. Readers
+	// refuse newer versions; within a version, gob tolerates fields being
+	// added or removed.
+	binaryVersion = 1
+)
+
+// binaryTrace is the content of a binary export, gob encoded and gzipped
+// after the magic and version byte
+type binaryTrace struct {
+	Trace       *Trace
+	Messages    []*Message
+	Insights    []*Insight
+	Annotations []*Annotation
+	Summary     []byte // JSON, as gob can't encode arbitrary interface values
+}
+
+// ExportTraceBinary exports a trace in the compact binary format, for
+// archiving. It holds the same data as ExportTrace, with bodies kept in the
+// blob store inlined, at a fraction of the size.
+func (s *Store) ExportTraceBinary(traceID string) ([]byte, error) {
+	return s.ExportTraceBinaryContext(context.Background(), traceID)
+}
+
+// ExportTraceBinaryContext exports a trace in the compact binary format,
+// aborting if ctx is cancelled
+func (s *Store) ExportTraceBinaryContext(ctx context.Context, traceID string) ([]byte, error) {
+	trace, err := s.GetTraceContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	if trace == nil {
+		return nil, fmt.Errorf("trace %s not found", traceID)
+	}
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	insights, err := s.GetInsightsContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := s.GetAnnotationsContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.GetTraceSummaryContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An archive shouldn't depend on files next to the database
+	for _, msg := range messages {
+		InlineBody(msg)
+	}
+
+	export := binaryTrace{
+		Trace:       trace,
+		Messages:    messages,
+		Insights:    insights,
+		Annotations: annotations,
+	}
+	if summary != nil {
+		if export.Summary, err = json.Marshal(summary); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	buf.WriteByte(binaryVersion)
+	gz := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(gz).Encode(&export); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportTraceBinary loads a trace exported by ExportTraceBinary, keeping its
+// ID, and returns it. It fails if the trace is already in the database.
+func (s *Store) ImportTraceBinary(data []byte) (*Trace, error) {
+	return s.ImportTraceBinaryContext(context.Background(), data)
+}
+
+// ImportTraceBinaryContext loads a trace exported by ExportTraceBinary,
+// aborting if ctx is cancelled
+func (s *Store) ImportTraceBinaryContext(ctx context.Context, data []byte) (*Trace, error) {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return nil, errors.New("not a binary trace export")
+	}
+	if version := data[len(binaryMagic)]; version > binaryVersion {
+		return nil, fmt.Errorf("binary trace version %d is newer than this a2a-trace supports (%d)", version, binaryVersion)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data[len(binaryMagic)+1:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary trace: %w", err)
+	}
+	var export binaryTrace
+	if err := gob.NewDecoder(gz).Decode(&export); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read binary trace: %w", err)
+	}
+	if export.Trace == nil {
+		return nil, errors.New("binary trace has no trace")
+	}
+
+	if export.Summary != nil {
+		var summary map[string]interface{}
+		if err := json.Unmarshal(export.Summary, &summary); err != nil {
+			return nil, fmt.Errorf("failed to read binary trace summary: %w", err)
+		}
+	}
+
+	existing, err := s.GetTraceContext(ctx, export.Trace.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("trace %s already exists", export.Trace.ID)
+	}
+
+	if err := s.importBinaryTrace(ctx, &export); err != nil {
+		return nil, err
+	}
+	return export.Trace, nil
+}
+
+// importBinaryTrace inserts everything in an export in one transaction, so
+// a failure part way leaves nothing behind and the import can be retried
+func (s *Store) importBinaryTrace(ctx context.Context, export *binaryTrace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := importTrace(ctx, tx, export.Trace); err != nil {
+		return err
+	}
+	for _, msg := range export.Messages {
+		if err := insertMessage(ctx, tx, msg); err != nil {
+			return err
+		}
+	}
+	for _, insight := range export.Insights {
+		if err := importInsight(ctx, tx, insight); err != nil {
+			return err
+		}
+	}
+	for _, annotation := range export.Annotations {
+		if err := insertAnnotation(ctx, tx, annotation); err != nil {
+			return err
+		}
+	}
+	if export.Summary != nil {
+		if err := s.insertTraceSummary(ctx, tx, export.Trace.ID, export.Summary); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// importTrace inserts a trace as it was recorded, unlike CreateTrace
+func importTrace(ctx context.Context, db execer, trace *Trace) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO traces (id, started_at, command, status) VALUES (?, ?, ?, ?)",
+		trace.ID, trace.StartedAt, trace.Command, trace.Status,
+	)
+	return err
+}
+
+// importInsight inserts an insight as it was recorded, keeping its
+// occurrences, unlike SaveInsight
+func importInsight(ctx context.Context, db execer, insight *Insight) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO insights (
+			id, trace_id, message_id, type, category, title, details, timestamp, severity,
+			fingerprint, occurrences, last_seen, subcategory, acknowledged
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		insight.ID, insight.TraceID, insight.MessageID, insight.Type, insight.Category,
+		insight.Title, insight.Details, insight.Timestamp, insight.Severity,
+		insight.Fingerprint, insight.Occurrences, insight.LastSeen, insight.Subcategory, insight.Acknowledged,
+	)
+	return err
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinaryExportRoundTrip(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	blobs, err := NewBlobStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := strings.Repeat("artifact ", 1000)
+	blobPath, err := blobs.Put(strings.NewReader(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []*Message
+	for i := 0; i < 50; i++ {
+		messages = append(messages,
+			&Message{
+				ID: fmt.Sprintf("req-%d", i), Direction: "request", Method: "tasks/send", MethodLabel: "Send Message",
+				URL: "http://planner:8080/rpc", ToAgent: "planner:8080", Headers: `{"Content-Type":["application/json"]}`,
+				Body:      fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tasks/send","params":{"message":{"role":"user"}}}`, i),
+				Timestamp: testTime.Add(time.Duration(i) * time.Second), IDType: "number", HTTPMethod: "POST",
+			},
+			&Message{
+				ID: fmt.Sprintf("resp-%d", i), Direction: "response", RequestID: fmt.Sprintf("req-%d", i),
+				StatusCode: 200, DurationMs: int64(i), ContentType: "application/json",
+				Body:      fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{}}`, i),
+				Timestamp: testTime.Add(time.Duration(i)*time.Second + 10*time.Millisecond), Timing: `{"reused":true}`,
+			},
+		)
+	}
+	messages = append(messages, &Message{ID: "artifact", Direction: "response", BodyPath: blobPath, Size: int64(len(large)), Truncated: true})
+	saveMessages(t, s, trace.ID, messages...)
+	for _, insight := range []*Insight{
+		{ID: "insight-1", TraceID: trace.ID, MessageID: "resp-3", Category: "slow_response", Severity: 40, Fingerprint: "slow", Timestamp: testTime},
+		{ID: "insight-2", TraceID: trace.ID, MessageID: "resp-4", Category: "error", Subcategory: "timeout", Timestamp: testTime},
+	} {
+		if err := s.SaveInsight(insight); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveInsight(&Insight{TraceID: trace.ID, Category: "slow_response", Fingerprint: "slow", Timestamp: testTime.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AckInsight("insight-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveAnnotation(&Annotation{TraceID: trace.ID, Label: "deploy", Note: "v2", Timestamp: testTime}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTraceSummary(trace.ID, map[string]interface{}{"total_messages": 101}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.ExportTraceBinary(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonExport, err := s.ExportTrace(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data)*5 > len(jsonExport) {
+		t.Errorf("binary export is %d bytes, want a fifth of the JSON's %d or less", len(data), len(jsonExport))
+	}
+
+	imported, err := New(filepath.Join(t.TempDir(), "imported.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	got, err := imported.ImportTraceBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != trace.ID {
+		t.Errorf("imported trace %s, want %s", got.ID, trace.ID)
+	}
+
+	// The same export, except the blob's body is inlined
+	want := decodeExport(t, jsonExport)
+	for _, msg := range want.Messages {
+		if msg.ID == "artifact" {
+			msg.Body, msg.BodyPath = large, ""
+		}
+	}
+	roundTripped, err := imported.ExportTrace(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(decodeExport(t, roundTripped))
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("round trip changed the trace:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+
+	if _, err := imported.ImportTraceBinary(data); err == nil {
+		t.Error("importing the same trace twice succeeded")
+	}
+}
+
+// traceExport is the content of a JSON trace export
+type traceExport struct {
+	Trace       *Trace                 `json:"trace"`
+	Messages    []*Message             `json:"messages"`
+	Insights    []*Insight             `json:"insights"`
+	Annotations []*Annotation          `json:"annotations"`
+	Summary     map[string]interface{} `json:"summary"`
+}
+
+// decodeExport decodes a JSON trace export
+func decodeExport(t *testing.T, data []byte) *traceExport {
+	t.Helper()
+	var export traceExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	return &export
+}
+
+func TestImportTraceBinaryRejectsUnknownData(t *testing.T) {
+	s, trace := newTestStore(t)
+	data, err := s.ExportTraceBinary(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer := append([]byte(nil), data...)
+	newer[len(binaryMagic)] = binaryVersion + 1
+
+	for name, data := range map[string][]byte{
+		"JSON":          []byte(`{"trace":{}}`),
+		"truncated":     data[:len(binaryMagic)+3],
+		"newer version": newer,
+	} {
+		if _, err := s.ImportTraceBinary(data); err == nil {
+			t.Errorf("%s imported", name)
+		}
+	}
+}
+
+func TestImportTraceBinaryLeavesNothingOnFailure(t *testing.T) {
+	source, trace := newTestStore(t)
+	saveMessages(t, source, trace.ID,
+		&Message{ID: "first", Direction: "request", Timestamp: testTime},
+		&Message{ID: "taken", Direction: "request", Timestamp: testTime},
+	)
+	if err := source.SaveInsight(&Insight{TraceID: trace.ID, MessageID: "first", Category: "error", Timestamp: testTime}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := source.ExportTraceBinary(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second message fails to save, as its ID is already used
+	s, other := newTestStore(t)
+	saveMessages(t, s, other.ID, &Message{ID: "taken", Direction: "request", Timestamp: testTime})
+	if _, err := s.ImportTraceBinary(data); err == nil {
+		t.Fatal("imported a message with a duplicate ID")
+	}
+	if got, err := s.GetTrace(trace.ID); err != nil || got != nil {
+		t.Fatalf("failed import left trace %+v (%v)", got, err)
+	}
+	if msg, _ := s.GetMessage("first"); msg != nil {
+		t.Error("failed import left a message")
+	}
+
+	// Once the conflict is gone, a retry succeeds
+	if _, err := s.db.Exec("DELETE FROM messages WHERE id = 'taken'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImportTraceBinary(data); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if messages, _ := s.GetMessages(trace.ID); len(messages) != 2 {
+		t.Errorf("retry imported %d messages, want 2", len(messages))
+	}
+	if insights, _ := s.GetInsights(trace.ID); len(insights) != 1 {
+		t.Errorf("retry imported %d insights, want 1", len(insights))
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BlobStore keeps bodies too large for the database as files named by the
+// SHA-256 of their content, so a body seen twice is only written once
+type BlobStore struct {
+	dir string
+}
+
+// NewBlobStore creates a BlobStore in dir, creating the directory if needed
+func NewBlobStore(dir string) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobStore{dir: abs}, nil
+}
+
+// Put streams r to the blob store and returns the path of the file holding it
+func (b *BlobStore) Put(r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(b.dir, ".blob-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	// Blobs are fanned out by the first byte of their hash
+	sum := hex.EncodeToString(hash.Sum(nil))
+	path := filepath.Join(b.dir, sum[:2], sum)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	return path, nil
+}
+
+// LoadBody returns a message's body, reading it from the blob store if it
+// was offloaded there
+func LoadBody(msg *Message) (string, error) {
+	if msg.BodyPath == "" {
+		return msg.Body, nil
+	}
+	body, err := os.ReadFile(msg.BodyPath)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// InlineBody moves a body offloaded to the blob store back into its message,
+// for output that shouldn't depend on files next to the database. A body
+// whose file is gone is left where it was.
+func InlineBody(msg *Message) {
+	if msg.BodyPath == "" {
+		return
+	}
+	if body, err := LoadBody(msg); err == nil {
+		msg.Body = body
+		msg.BodyPath = ""
+	}
+}
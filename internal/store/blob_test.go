@@ -0,0 +1,56 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlobStoreIsContentAddressed(t *testing.T) {
+	dir := t.TempDir()
+	blobs, err := NewBlobStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.Repeat("artifact ", 10000)
+	first, err := blobs.Put(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := blobs.Put(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("same body stored as %s and %s", first, second)
+	}
+	other, err := blobs.Put(strings.NewReader("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("different bodies share a blob")
+	}
+
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Error("blob doesn't hold the body")
+	}
+
+	// Nothing is left behind but the two blobs
+	var files []string
+	filepath.WalkDir(filepath.Join(dir, "blobs"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if len(files) != 2 {
+		t.Errorf("blob directory holds %v, want 2 blobs", files)
+	}
+}
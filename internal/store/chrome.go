@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// chromeTraceEvent is one entry of the Chrome trace-event format understood by
+// chrome://tracing and Perfetto. Timestamps and durations are in microseconds.
+type chromeTraceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    int64                  `json:"ts"`
+	Dur   int64                  `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// ExportChromeTrace exports a trace in the Chrome trace-event format
+func (s *Store) ExportChromeTrace(traceID string) ([]byte, error) {
+	return s.ExportChromeTraceContext(context.Background(), traceID)
+}
+
+// ExportChromeTraceContext exports a trace in the Chrome trace-event format,
+// aborting if ctx is cancelled. Each request becomes a duration event spanning
+// its response time, on a track per agent; requests without a measured
+// duration become instant events.
+func (s *Store) ExportChromeTraceContext(ctx context.Context, traceID string) ([]byte, error) {
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[string]*Message)
+	for _, msg := range messages {
+		if msg.Direction == "response" && msg.RequestID != "" && !msg.Redirect {
+			responses[msg.RequestID] = msg
+		}
+	}
+
+	events := []chromeTraceEvent{}
+	tracks := make(map[string]int) // agent -> tid
+
+	for _, msg := range messages {
+		if msg.Direction != "request" {
+			continue
+		}
+
+		agent := chromeTraceAgent(msg)
+		tid, ok := tracks[agent]
+		if !ok {
+			tid = len(tracks) + 1
+			tracks[agent] = tid
+			events = append(events, chromeTraceEvent{
+				Name: "thread_name",
+				Ph:   "M",
+				Pid:  1,
+				Tid:  tid,
+				Args: map[string]interface{}{"name": agent},
+			})
+		}
+
+		method := msg.Method
+		if method == "" {
+			method = "unknown"
+		}
+
+		event := chromeTraceEvent{
+			Name: method,
+			Cat:  agent + "," + method,
+			Ts:   msg.Timestamp.UnixMicro(),
+			Pid:  1,
+			Tid:  tid,
+			Args: map[string]interface{}{
+				"message_id": msg.ID,
+				"url":        msg.URL,
+			},
+		}
+
+		resp := responses[msg.ID]
+		if resp != nil && resp.DurationMs > 0 {
+			event.Ph = "X"
+			event.Dur = resp.DurationMs * 1000
+			event.Args["status_code"] = resp.StatusCode
+			if resp.Error != "" {
+				event.Args["error"] = resp.Error
+			}
+		} else {
+			event.Ph = "i"
+			event.Scope = "t"
+			if resp != nil {
+				event.Args["status_code"] = resp.StatusCode
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+// chromeTraceAgent names the track a request is drawn on
+func chromeTraceAgent(msg *Message) string {
+	if msg.ToAgent != "" {
+		return msg.ToAgent
+	}
+	if u, err := url.Parse(msg.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "unknown"
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportChromeTrace(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	var messages []*Message
+	messages = append(messages, call("1", "host", "planner:8080", 200, 100)...)
+	messages = append(messages, call("2", "planner", "search:8080", 500, 30)...)
+	// Still in flight, so drawn as an instant event
+	messages = append(messages, &Message{ID: "3", Direction: "request", ToAgent: "search:8080", Method: "tasks/get"})
+	saveMessages(t, s, trace.ID, messages...)
+
+	data, err := s.ExportChromeTrace(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	phases := make(map[string]int)
+	events := make(map[string]chromeTraceEvent)
+	for _, event := range out.TraceEvents {
+		phases[event.Ph]++
+		if id, ok := event.Args["message_id"].(string); ok {
+			events[id] = event
+		}
+	}
+	if phases["X"] != 2 || phases["i"] != 1 {
+		t.Errorf("got %d duration and %d instant events, want 2 and 1", phases["X"], phases["i"])
+	}
+	if phases["M"] != 2 {
+		t.Errorf("got %d agent tracks, want 2", phases["M"])
+	}
+
+	first := events["1"]
+	if first.Ts != testTime.UnixMicro() || first.Dur != 100000 {
+		t.Errorf("ts, dur = %d, %d; want %d, 100000 microseconds", first.Ts, first.Dur, testTime.UnixMicro())
+	}
+	if first.Cat != "planner:8080,unknown" {
+		t.Errorf("cat = %q", first.Cat)
+	}
+	if events["2"].Args["status_code"] != float64(500) {
+		t.Errorf("status_code = %v, want 500", events["2"].Args["status_code"])
+	}
+	if events["3"].Tid != events["2"].Tid {
+		t.Error("requests to one agent drawn on different tracks")
+	}
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// exportPageSize is how many messages StreamExport holds in memory at a time
+const exportPageSize = 500
+
+// exportRange returns whether a span from start to end overlaps the range
+// from..to, where a zero from or to leaves that end open
+func exportRange(from, to time.Time) func(start, end time.Time) bool {
+	return func(start, end time.Time) bool {
+		return (from.IsZero() || !end.Before(from)) && (to.IsZero() || !start.After(to))
+	}
+}
+
+// insightsInRange filters insights to a range. A deduplicated insight is
+// included if any occurrence falls in the range.
+func insightsInRange(insights []*Insight, inRange func(start, end time.Time) bool) []*Insight {
+	var filtered []*Insight
+	for _, insight := range insights {
+		if inRange(insight.Timestamp, insight.LastSeen) {
+			filtered = append(filtered, insight)
+		}
+	}
+	return filtered
+}
+
+// annotationsInRange filters annotations to a range
+func annotationsInRange(annotations []*Annotation, inRange func(start, end time.Time) bool) []*Annotation {
+	var filtered []*Annotation
+	for _, annotation := range annotations {
+		if inRange(annotation.Timestamp, annotation.Timestamp) {
+			filtered = append(filtered, annotation)
+		}
+	}
+	return filtered
+}
+
+// StreamExport writes a trace as JSON to w, like ExportTrace, without
+// loading all its messages at once
+func (s *Store) StreamExport(traceID string, w io.Writer) error {
+	return s.StreamExportRangeContext(context.Background(), traceID, time.Time{}, time.Time{}, w)
+}
+
+// StreamExportContext writes a trace as JSON to w, like ExportTrace, without
+// loading all its messages at once, aborting if ctx is cancelled
+func (s *Store) StreamExportContext(ctx context.Context, traceID string, w io.Writer) error {
+	return s.StreamExportRangeContext(ctx, traceID, time.Time{}, time.Time{}, w)
+}
+
+// StreamExportRange writes the part of a trace between from and to as JSON
+// to w, like ExportTraceRange
+func (s *Store) StreamExportRange(traceID string, from, to time.Time, w io.Writer) error {
+	return s.StreamExportRangeContext(context.Background(), traceID, from, to, w)
+}
+
+// StreamExportRangeContext writes the part of a trace between from and to as
+// JSON to w, aborting if ctx is cancelled. The output is the same as
+// ExportTraceRange's, but messages are read and written a page at a time,
+// so memory use doesn't grow with the trace. Trace metadata, insights and
+// annotations are small and loaded up front.
+func (s *Store) StreamExportRangeContext(ctx context.Context, traceID string, from, to time.Time, w io.Writer) error {
+	trace, err := s.GetTraceContext(ctx, traceID)
+	if err != nil {
+		return err
+	}
+	insights, err := s.GetInsightsContext(ctx, traceID)
+	if err != nil {
+		return err
+	}
+	annotations, err := s.GetAnnotationsContext(ctx, traceID)
+	if err != nil {
+		return err
+	}
+	summary, err := s.GetTraceSummaryContext(ctx, traceID)
+	if err != nil {
+		return err
+	}
+
+	ranged := !from.IsZero() || !to.IsZero()
+	inRange := exportRange(from, to)
+	if ranged {
+		insights = insightsInRange(insights, inRange)
+		annotations = annotationsInRange(annotations, inRange)
+	}
+
+	// Fields are written in the sorted key order json.MarshalIndent gives
+	// the in-memory export. A write error sticks to bw, so it's returned by
+	// the final Flush rather than checked on every write.
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("{\n")
+	if err := writeExportField(bw, "annotations", annotations); err != nil {
+		return err
+	}
+	if ranged && !from.IsZero() {
+		if err := writeExportField(bw, "from", from); err != nil {
+			return err
+		}
+	}
+	if err := writeExportField(bw, "insights", insights); err != nil {
+		return err
+	}
+
+	_, _ = bw.WriteString(`  "messages": `)
+	written := 0
+	for offset := 0; ; offset += exportPageSize {
+		page, err := s.queryMessages(ctx, `
+			SELECT `+messageColumns+`
+			FROM messages WHERE trace_id = ? ORDER BY seq ASC, timestamp ASC
+			LIMIT ? OFFSET ?`,
+			traceID, exportPageSize, offset,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range page {
+			if ranged && !inRange(msg.Timestamp, msg.Timestamp) {
+				continue
+			}
+			InlineBody(msg)
+			data, err := json.MarshalIndent(msg, "    ", "  ")
+			if err != nil {
+				return err
+			}
+			if written == 0 {
+				_, _ = bw.WriteString("[\n    ")
+			} else {
+				_, _ = bw.WriteString(",\n    ")
+			}
+			_, _ = bw.Write(data)
+			written++
+		}
+
+		// Messages are only ever appended, so offsets stay valid while the
+		// trace is still being recorded
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+	if written == 0 {
+		_, _ = bw.WriteString("null,\n") // As a nil slice marshals
+	} else {
+		_, _ = bw.WriteString("\n  ],\n")
+	}
+
+	if summary != nil {
+		if err := writeExportField(bw, "summary", summary); err != nil {
+			return err
+		}
+	}
+
+	if ranged && !to.IsZero() {
+		if err := writeExportField(bw, "to", to); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(trace, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = bw.WriteString(`  "trace": `)
+	_, _ = bw.Write(data)
+	_, _ = bw.WriteString("\n}")
+
+	return bw.Flush()
+}
+
+// writeExportField writes one field of the top-level export object, followed
+// by a comma since trace always comes last
+func writeExportField(bw *bufio.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = bw.WriteString(`  "` + name + `": `)
+	_, _ = bw.Write(data)
+	_, err = bw.WriteString(",\n")
+	return err
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamExportMatchesExportTrace(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	// Checked empty, then with enough messages to span pages
+	check := func(name string, from, to time.Time) {
+		t.Helper()
+		want, err := s.ExportTraceRange(trace.ID, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := s.StreamExportRange(trace.ID, from, to, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%s: streamed export differs:\n%s\nwant:\n%s", name, got.Bytes(), want)
+		}
+	}
+	check("empty trace", time.Time{}, time.Time{})
+
+	for i := 0; i < exportPageSize+10; i++ {
+		saveMessages(t, s, trace.ID, &Message{
+			ID:        fmt.Sprintf("msg-%d", i),
+			Direction: "request",
+			Method:    "tasks/send",
+			Body:      `{"jsonrpc":"2.0","id":1,"method":"tasks/send","params":{"text":"<hi>"}}`,
+			Timestamp: testTime.Add(time.Duration(i) * time.Second),
+		})
+	}
+	if err := s.SaveInsight(&Insight{TraceID: trace.ID, Category: "slow_response", Timestamp: testTime}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveAnnotation(&Annotation{TraceID: trace.ID, Label: "deploy", Timestamp: testTime.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	check("whole trace", time.Time{}, time.Time{})
+	check("range", testTime.Add(time.Minute), testTime.Add(2*time.Minute))
+	check("open range", testTime.Add(time.Hour), time.Time{})
+}
+
+// offload writes body to a file as the blob store would, returning its path
+func offload(t *testing.T, body string) string {
+	t.Helper()
+	blobs, err := NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := blobs.Put(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExportsInlineOffloadedBodies(t *testing.T) {
+	s, trace := newTestStore(t)
+	body := `{"jsonrpc":"2.0","id":1,"result":{"text":"large"}}`
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "resp", Direction: "response", BodyPath: offload(t, body), Timestamp: testTime},
+		&Message{ID: "gone", Direction: "response", BodyPath: filepath.Join(t.TempDir(), "missing"), Timestamp: testTime},
+	)
+
+	exported, err := s.ExportTrace(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed bytes.Buffer
+	if err := s.StreamExport(trace.ID, &streamed); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"export": exported, "streamed export": streamed.Bytes()} {
+		var export struct {
+			Messages []*Message `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			t.Fatal(err)
+		}
+		if len(export.Messages) != 2 {
+			t.Fatalf("%s has %d messages, want 2", name, len(export.Messages))
+		}
+		if msg := export.Messages[0]; msg.Body != body || msg.BodyPath != "" {
+			t.Errorf("%s: body %q at %q, want it inline", name, msg.Body, msg.BodyPath)
+		}
+		// A body whose file is gone can't be inlined, so where it was is kept
+		if msg := export.Messages[1]; msg.BodyPath == "" {
+			t.Errorf("%s dropped the path of a missing body", name)
+		}
+	}
+}
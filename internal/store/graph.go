@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"sort"
+)
+
+// defaultCaller labels requests that were not tagged with a source process
+const defaultCaller = "client"
+
+// BuildCallGraph derives who-called-whom from a trace's messages
+func (s *Store) BuildCallGraph(traceID string) (*CallGraph, error) {
+	return s.BuildCallGraphContext(context.Background(), traceID)
+}
+
+// BuildCallGraphContext derives who-called-whom from a trace's messages, aborting if ctx is cancelled
+func (s *Store) BuildCallGraphContext(ctx context.Context, traceID string) (*CallGraph, error) {
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.GetAgentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Friendly names for hosts that served an agent card
+	names := make(map[string]string)
+	for _, agent := range agents {
+		if u, err := url.Parse(agent.URL); err == nil && agent.Name != "" {
+			names[u.Host] = agent.Name
+		}
+	}
+
+	type edgeKey struct{ from, to string }
+	type edgeStats struct {
+		edge          *GraphEdge
+		totalDuration int64
+		responses     int64
+	}
+
+	nodes := make(map[string]*GraphNode)
+	edges := make(map[edgeKey]*edgeStats)
+	requestEdges := make(map[string]edgeKey) // request message ID -> edge
+
+	addNode := func(id string) {
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = &GraphNode{ID: id, Name: names[id]}
+		}
+	}
+
+	for _, msg := range messages {
+		// CORS preflights aren't calls between agents, and redirect hops
+		// don't answer one
+		if msg.Preflight || msg.Redirect {
+			continue
+		}
+		switch msg.Direction {
+		case "request":
+			from := msg.Source
+			if from == "" {
+				from = defaultCaller
+			}
+			to := msg.ToAgent
+			if to == "" {
+				continue
+			}
+			addNode(from)
+			addNode(to)
+
+			key := edgeKey{from, to}
+			stats, ok := edges[key]
+			if !ok {
+				stats = &edgeStats{edge: &GraphEdge{From: from, To: to}}
+				edges[key] = stats
+			}
+			stats.edge.Count++
+			requestEdges[msg.ID] = key
+
+		case "response":
+			key, ok := requestEdges[msg.RequestID]
+			if !ok {
+				continue
+			}
+			stats := edges[key]
+			stats.totalDuration += msg.DurationMs
+			stats.responses++
+			if msg.Error != "" || msg.StatusCode >= 400 {
+				stats.edge.ErrorCount++
+			}
+		}
+	}
+
+	graph := &CallGraph{
+		Nodes: make([]*GraphNode, 0, len(nodes)),
+		Edges: make([]*GraphEdge, 0, len(edges)),
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for _, stats := range edges {
+		if stats.responses > 0 {
+			stats.edge.AvgDurationMs = stats.totalDuration / stats.responses
+		}
+		graph.Edges = append(graph.Edges, stats.edge)
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph, nil
+}
+
+// TopoSort orders a trace's agents so each comes before the agents it calls,
+// or finds the cycles that make that impossible
+func (s *Store) TopoSort(traceID string) (*Topology, error) {
+	return s.TopoSortContext(context.Background(), traceID)
+}
+
+// TopoSortContext orders a trace's agents so each comes before the agents it
+// calls, or finds the cycles that make that impossible, aborting if ctx is
+// cancelled
+func (s *Store) TopoSortContext(ctx context.Context, traceID string) (*Topology, error) {
+	graph, err := s.BuildCallGraphContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	return topoSort(graph), nil
+}
+
+// topoSort orders a call graph with Kahn's algorithm, taking ready nodes in
+// ID order so the result is stable. Nodes left over sit on or behind a
+// cycle; the cycles among them are its strongly connected components.
+func topoSort(graph *CallGraph) *Topology {
+	callees := make(map[string][]string)
+	inDegree := make(map[string]int, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		inDegree[node.ID] = 0
+	}
+	for _, edge := range graph.Edges {
+		callees[edge.From] = append(callees[edge.From], edge.To)
+		inDegree[edge.To]++
+	}
+
+	var ready []string
+	for _, node := range graph.Nodes {
+		if inDegree[node.ID] == 0 {
+			ready = append(ready, node.ID)
+		}
+	}
+
+	order := make([]string, 0, len(graph.Nodes))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, callee := range callees[id] {
+			inDegree[callee]--
+			if inDegree[callee] == 0 {
+				ready = append(ready, callee)
+			}
+		}
+	}
+
+	if len(order) == len(graph.Nodes) {
+		return &Topology{Acyclic: true, Order: order}
+	}
+	return &Topology{Order: []string{}, Cycles: findCycles(graph, callees)}
+}
+
+// findCycles returns the strongly connected components of a call graph that
+// contain a cycle, using Tarjan's algorithm. Each is sorted, as is the list.
+func findCycles(graph *CallGraph, callees map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		lowLink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for _, callee := range callees[id] {
+			if callee == id {
+				selfLoop = true
+			}
+			if _, seen := index[callee]; !seen {
+				visit(callee)
+				lowLink[id] = min(lowLink[id], lowLink[callee])
+			} else if onStack[callee] {
+				lowLink[id] = min(lowLink[id], index[callee])
+			}
+		}
+
+		if lowLink[id] != index[id] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, node := range graph.Nodes {
+		if _, seen := index[node.ID]; !seen {
+			visit(node.ID)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
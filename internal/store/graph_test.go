@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// call returns a request from one node to another and its response
+func call(id, from, to string, status int, durationMs int64) []*Message {
+	return []*Message{
+		{ID: id, Direction: "request", Source: from, ToAgent: to},
+		{ID: id + "-resp", Direction: "response", RequestID: id, StatusCode: status, DurationMs: durationMs},
+	}
+}
+
+func TestBuildCallGraph(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	var messages []*Message
+	messages = append(messages, call("1", "host", "planner", 200, 100)...)
+	messages = append(messages, call("2", "host", "planner", 500, 300)...)
+	messages = append(messages, call("3", "planner", "search", 200, 50)...)
+	messages = append(messages, call("4", "search", "search", 200, 10)...)
+	saveMessages(t, s, trace.ID, messages...)
+
+	graph, err := s.BuildCallGraph(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(graph.Nodes) != 3 {
+		t.Errorf("got %d nodes, want 3", len(graph.Nodes))
+	}
+	want := []GraphEdge{
+		{From: "host", To: "planner", Count: 2, ErrorCount: 1, AvgDurationMs: 200},
+		{From: "planner", To: "search", Count: 1, AvgDurationMs: 50},
+		{From: "search", To: "search", Count: 1, AvgDurationMs: 10}, // Self-edge
+	}
+	if len(graph.Edges) != len(want) {
+		t.Fatalf("got %d edges, want %d", len(graph.Edges), len(want))
+	}
+	for i, edge := range graph.Edges {
+		if *edge != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, *edge, want[i])
+		}
+	}
+}
+
+func TestTopoSort(t *testing.T) {
+	tests := []struct {
+		name   string
+		calls  [][2]string
+		order  string
+		cycles string
+	}{
+		{
+			name:  "chain",
+			calls: [][2]string{{"planner", "search"}, {"host", "planner"}, {"search", "db"}},
+			order: "[host planner search db]",
+		},
+		{
+			name:   "cycle",
+			calls:  [][2]string{{"host", "planner"}, {"planner", "search"}, {"search", "planner"}, {"search", "db"}},
+			order:  "[]",
+			cycles: "[[planner search]]",
+		},
+		{
+			name:   "self call",
+			calls:  [][2]string{{"host", "search"}, {"search", "search"}},
+			order:  "[]",
+			cycles: "[[search]]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, trace := newTestStore(t)
+			var messages []*Message
+			for i, c := range tt.calls {
+				messages = append(messages, call(fmt.Sprint(i), c[0], c[1], 200, 10)...)
+			}
+			saveMessages(t, s, trace.ID, messages...)
+
+			topology, err := s.TopoSort(trace.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if topology.Acyclic != (tt.cycles == "") {
+				t.Errorf("Acyclic = %v", topology.Acyclic)
+			}
+			if got := fmt.Sprint(topology.Order); got != tt.order {
+				t.Errorf("order %s, want %s", got, tt.order)
+			}
+			if tt.cycles != "" {
+				if got := fmt.Sprint(topology.Cycles); got != tt.cycles {
+					t.Errorf("cycles %s, want %s", got, tt.cycles)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// JSONLWriter appends messages to a file as one JSON object per line, so every
+// line has the same shape; agents and insights are left to the store. It is
+// safe for concurrent use.
+type JSONLWriter struct {
+	path    string
+	maxSize int64 // Rotate once the file would grow past this many bytes (0: never)
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+}
+
+// NewJSONLWriter opens path for appending, creating it if needed. With a
+// positive maxSize, a full file is renamed aside with a timestamp suffix and a
+// fresh one is started.
+func NewJSONLWriter(path string, maxSize int64) (*JSONLWriter, error) {
+	w := &JSONLWriter{path: path, maxSize: maxSize}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the current file and records its size
+func (w *JSONLWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", w.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// SaveMessage appends a message as a line
+func (w *JSONLWriter) SaveMessage(msg *Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("%s is closed", w.path)
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// SaveAgent does nothing; only messages are archived
+func (w *JSONLWriter) SaveAgent(agent *Agent) error {
+	return nil
+}
+
+// SaveInsight does nothing; only messages are archived
+func (w *JSONLWriter) SaveInsight(insight *Insight) error {
+	return nil
+}
+
+// rotate moves the full file aside and starts a new one; callers must hold w.mu
+func (w *JSONLWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	stamp := time.Now().Format("20060102T150405.000")
+	rotated := fmt.Sprintf("%s.%s", w.path, stamp)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s.%s-%d", w.path, stamp, n)
+	}
+	if err := os.Rename(w.path, rotated); err != nil {
+		_ = w.open()
+		return fmt.Errorf("failed to rotate %s: %w", w.path, err)
+	}
+	return w.open()
+}
+
+// Close closes the file
+func (w *JSONLWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
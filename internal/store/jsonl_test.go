@@ -0,0 +1,96 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readJSONL decodes the messages in a JSONL file
+func readJSONL(t *testing.T, path string) []*Message {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var messages []*Message
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, &msg)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestJSONLWriterWritesALinePerMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	w, err := NewJSONLWriter(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := w.SaveMessage(&Message{ID: id, Direction: "request", Body: "{\n}"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.SaveInsight(&Insight{ID: "insight"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := readJSONL(t, path)
+	if len(messages) != 3 {
+		t.Fatalf("got %d lines, want 3", len(messages))
+	}
+	for i, msg := range messages {
+		if want := []string{"1", "2", "3"}[i]; msg.ID != want || msg.Body != "{\n}" {
+			t.Errorf("line %d = %s %q, want message %s", i, msg.ID, msg.Body, want)
+		}
+	}
+
+	if err := w.SaveMessage(&Message{ID: "4"}); err == nil {
+		t.Error("saved after Close")
+	}
+}
+
+func TestJSONLWriterRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.jsonl")
+	w, err := NewJSONLWriter(path, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	body := strings.Repeat("x", 100)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := w.SaveMessage(&Message{ID: id, Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("got files %v, want the current one and two rotated", files)
+	}
+	if messages := readJSONL(t, path); len(messages) != 1 || messages[0].ID != "3" {
+		t.Errorf("current file holds %d messages, want only the last", len(messages))
+	}
+}
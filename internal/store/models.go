@@ -9,38 +9,104 @@ type Trace struct {
 	ID        string    `json:"id"`
 	StartedAt time.Time `json:"started_at"`
 	Command   string    `json:"command"`
-	Status    string    `json:"status"` // "running", "completed", "error"
+	Status    string    `json:"status"` // "running", "completed", "error", or "interrupted" when the session crashed
+
+	MessageCount int `json:"message_count,omitempty"` // Only set by ListTraces
+}
+
+// TraceStats describes the span of a trace's messages. A trace without
+// messages has no first or last message and a zero duration.
+type TraceStats struct {
+	FirstMessageAt *time.Time `json:"first_message_at,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	DurationMs     int64      `json:"duration_ms"` // From the first message to the last
 }
 
 // Message represents an A2A protocol message (request or response)
 type Message struct {
-	ID          string    `json:"id"`
-	TraceID     string    `json:"trace_id"`
-	Timestamp   time.Time `json:"timestamp"`
-	Direction   string    `json:"direction"` // "request" or "response"
-	FromAgent   string    `json:"from_agent"`
-	ToAgent     string    `json:"to_agent"`
-	Method      string    `json:"method"` // A2A method like "tasks/create"
-	URL         string    `json:"url"`
-	Headers     string    `json:"headers"` // JSON string
-	Body        string    `json:"body"`    // Full JSON body
-	DurationMs  int64     `json:"duration_ms"`
-	StatusCode  int       `json:"status_code"`
-	Error       string    `json:"error,omitempty"`
-	RequestID   string    `json:"request_id,omitempty"` // Links response to request
-	ContentType string    `json:"content_type"`
-	Size        int64     `json:"size"`
+	ID              string    `json:"id"`
+	Seq             int64     `json:"seq"` // Monotonic order of saving, stable within the same millisecond
+	TraceID         string    `json:"trace_id"`
+	Timestamp       time.Time `json:"timestamp"`
+	Direction       string    `json:"direction"` // "request" or "response"
+	FromAgent       string    `json:"from_agent"`
+	ToAgent         string    `json:"to_agent"`
+	FromName        string    `json:"from_name,omitempty"`    // Display name of FromAgent, from --agent-alias or its agent card
+	ToName          string    `json:"to_name,omitempty"`      // Display name of ToAgent
+	Method          string    `json:"method"`                 // A2A method like "tasks/create"
+	HTTPMethod      string    `json:"http_method,omitempty"`  // HTTP method a request was sent with, e.g. "PUT"
+	MethodLabel     string    `json:"method_label,omitempty"` // Display name of Method, e.g. "Create Task", see --method-labels
+	URL             string    `json:"url"`
+	Headers         string    `json:"headers"`            // JSON string
+	Body            string    `json:"body,omitempty"`     // Full JSON body
+	BodyURL         string    `json:"body_url,omitempty"` // Where to fetch Body when a message list leaves it out; not stored
+	DurationMs      int64     `json:"duration_ms"`
+	OverheadMs      int64     `json:"overhead_ms"` // Time a2a-trace spent parsing and storing, excluded from DurationMs
+	StatusCode      int       `json:"status_code"`
+	Error           string    `json:"error,omitempty"`
+	RequestID       string    `json:"request_id,omitempty"` // Links response to request, and push notification to the request that started its task
+	IDType          string    `json:"id_type,omitempty"`    // JSON type of the body's JSON-RPC id, e.g. "number" or "string"
+	ContentType     string    `json:"content_type"`
+	Size            int64     `json:"size"`
+	IsNotification  bool      `json:"is_notification,omitempty"`  // JSON-RPC request without an id; no response expected
+	Source          string    `json:"source,omitempty"`           // Traced process that sent the request, when several run together
+	Transport       string    `json:"transport,omitempty"`        // "grpc" for gRPC calls, "webhook" for captured push notifications, empty for JSON-RPC over HTTP
+	RetryOf         string    `json:"retry_of,omitempty"`         // ID of the earlier identical request this one repeats
+	Trailers        string    `json:"trailers,omitempty"`         // JSON string of HTTP trailers, sent after the body
+	CorrelationID   string    `json:"correlation_id,omitempty"`   // Correlation header value, or the JSON-RPC id without one
+	Fault           string    `json:"fault,omitempty"`            // Fault rule injected into this response, see --fault-inject
+	BodyPath        string    `json:"body_path,omitempty"`        // File holding a body too large to keep in Body, see BlobStore
+	Preflight       bool      `json:"preflight,omitempty"`        // CORS preflight OPTIONS request or its response, left out of latency and error stats
+	Truncated       bool      `json:"truncated,omitempty"`        // Body cut off at the response size limit; Size is the full size
+	TLSInfo         string    `json:"tls_info,omitempty"`         // JSON string of the TLSConnection a response arrived over, for HTTPS upstreams
+	Timing          string    `json:"timing,omitempty"`           // JSON string of the ConnectionTiming of the upstream connection a response came over
+	IngressPort     int       `json:"ingress_port,omitempty"`     // Proxy port the request came in on, or its request's for a response; 0 over a unix socket
+	ParentID        string    `json:"parent_id,omitempty"`        // ID of the inbound request being served when this request was made, which likely caused it
+	ClientAbandoned bool      `json:"client_abandoned,omitempty"` // The client disconnected before this response arrived, so it was never delivered
+	Redirect        bool      `json:"redirect,omitempty"`         // Redirect the proxy followed on the way to the response, left out of latency and error stats
+}
+
+// MessageBody is a message's body without the rest of the message, see
+// GetMessageBody
+type MessageBody struct {
+	Body        string // Empty when the body is in BodyPath
+	BodyPath    string
+	ContentType string
+}
+
+// TLSConnection describes the TLS connection to an HTTPS upstream and the
+// certificate it presented
+type TLSConnection struct {
+	Version     string    `json:"version"`      // e.g. "TLS 1.3"
+	CipherSuite string    `json:"cipher_suite"` // e.g. "TLS_AES_128_GCM_SHA256"
+	ServerName  string    `json:"server_name,omitempty"`
+	Subject     string    `json:"subject,omitempty"` // Of the leaf certificate
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"not_after,omitempty"` // Leaf certificate expiry
+}
+
+// ConnectionTiming breaks down the time spent getting a connection to an
+// upstream, before the request was sent. A reused connection has none.
+type ConnectionTiming struct {
+	DNSMs          int64 `json:"dns_ms"`
+	ConnectMs      int64 `json:"connect_ms"`
+	TLSHandshakeMs int64 `json:"tls_handshake_ms"`
+	Reused         bool  `json:"reused"` // Connection kept alive from an earlier request
 }
 
 // Agent represents a discovered A2A agent
 type Agent struct {
-	ID          string `json:"id"`
-	URL         string `json:"url"`
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-	Version     string `json:"version,omitempty"`
-	Skills      string `json:"skills,omitempty"` // JSON array
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	Skills      string    `json:"skills,omitempty"` // JSON array
 	FirstSeen   time.Time `json:"first_seen"`
+
+	// PreviousSkills is set by SaveAgent to the skills the save replaced,
+	// or "" for an agent not seen before. It isn't stored.
+	PreviousSkills string `json:"-"`
 }
 
 // A2ARequest represents a parsed A2A JSON-RPC request
@@ -68,13 +134,13 @@ type A2AError struct {
 
 // AgentCard represents the A2A agent card (/.well-known/agent.json)
 type AgentCard struct {
-	Name            string       `json:"name"`
-	Description     string       `json:"description,omitempty"`
-	URL             string       `json:"url"`
-	Version         string       `json:"version,omitempty"`
-	ProtocolVersion string       `json:"protocol_version,omitempty"`
+	Name            string        `json:"name"`
+	Description     string        `json:"description,omitempty"`
+	URL             string        `json:"url"`
+	Version         string        `json:"version,omitempty"`
+	ProtocolVersion string        `json:"protocol_version,omitempty"`
 	Capabilities    *Capabilities `json:"capabilities,omitempty"`
-	Skills          []Skill      `json:"skills,omitempty"`
+	Skills          []Skill       `json:"skills,omitempty"`
 }
 
 // Capabilities represents agent capabilities
@@ -93,21 +159,133 @@ type Skill struct {
 	Examples    []string `json:"examples,omitempty"`
 }
 
-// Insight represents an automatically detected issue or pattern
-type Insight struct {
+// Artifact summarizes an output an agent attached to a task result
+type Artifact struct {
+	ID          string `json:"id"`
+	TraceID     string `json:"trace_id"`
+	MessageID   string `json:"message_id"` // Response the artifact arrived in
+	Index       int    `json:"index"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Types       string `json:"types"`               // Part kinds, e.g. "text" or "text,file"
+	MimeType    string `json:"mime_type,omitempty"` // Of the first file part
+	Size        int64  `json:"size"`                // Bytes of text, file and data content
+	PartCount   int    `json:"part_count"`
+}
+
+// Annotation is a named marker a user placed on a trace's timeline
+type Annotation struct {
 	ID        string    `json:"id"`
 	TraceID   string    `json:"trace_id"`
-	MessageID string    `json:"message_id,omitempty"`
-	Type      string    `json:"type"` // "error", "warning", "info"
-	Category  string    `json:"category"` // "slow_response", "retry_loop", "protocol_violation"
-	Title     string    `json:"title"`
-	Details   string    `json:"details"`
 	Timestamp time.Time `json:"timestamp"`
+	Label     string    `json:"label"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// Insight represents an automatically detected issue or pattern
+type Insight struct {
+	ID           string    `json:"id"`
+	TraceID      string    `json:"trace_id"`
+	MessageID    string    `json:"message_id,omitempty"`
+	Type         string    `json:"type"`                  // "error", "warning", "info"
+	Category     string    `json:"category"`              // "slow_response", "retry_loop", "protocol_violation"
+	Subcategory  string    `json:"subcategory,omitempty"` // Finer kind within the category, e.g. "timeout" for errors
+	Severity     int       `json:"severity"`              // 0-100, higher is more important
+	Title        string    `json:"title"`
+	Details      string    `json:"details"`
+	Timestamp    time.Time `json:"timestamp"` // First seen
+	LastSeen     time.Time `json:"last_seen"`
+	Occurrences  int       `json:"occurrences"`            // Times this insight was detected
+	Fingerprint  string    `json:"fingerprint,omitempty"`  // Repeats with the same fingerprint are collapsed
+	Acknowledged bool      `json:"acknowledged,omitempty"` // Marked as triaged; repeats stay acknowledged
+}
+
+// AgentHealth scores an agent's behavior over a trace from 0 (unusable) to
+// 100 (healthy), along with the figures the score was derived from
+type AgentHealth struct {
+	Score         int     `json:"score"`
+	Responses     int     `json:"responses"`
+	ErrorRate     float64 `json:"error_rate"`
+	AvgDurationMs int64   `json:"avg_duration_ms"`
+	Violations    int     `json:"violations"` // Protocol violations, counting repeats
+}
+
+// CallGraph represents which agents called which during a trace
+type CallGraph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is an agent (or calling process) in the call graph
+type GraphNode struct {
+	ID   string `json:"id"`             // Host for agents, source process name for callers
+	Name string `json:"name,omitempty"` // Agent card name, if discovered
+}
+
+// GraphEdge aggregates the calls from one node to another
+type GraphEdge struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Count         int    `json:"count"`
+	ErrorCount    int    `json:"error_count"`
+	AvgDurationMs int64  `json:"avg_duration_ms"`
+}
+
+// Topology orders the nodes of a call graph by dependency. When the calls
+// form cycles there is no order, and the cycles are listed instead.
+type Topology struct {
+	Acyclic bool       `json:"acyclic"`
+	Order   []string   `json:"order"`            // Callers before the agents they call; empty unless Acyclic
+	Cycles  [][]string `json:"cycles,omitempty"` // Sets of nodes that call each other, directly or through others
+}
+
+// MethodSchema is the inferred shape of one method's request params and
+// response results, see InferSchemas
+type MethodSchema struct {
+	Method    string  `json:"method"`
+	Requests  int     `json:"requests"`         // Requests whose params were inferred from
+	Responses int     `json:"responses"`        // Responses whose results were inferred from
+	Params    *Schema `json:"params,omitempty"` // Omitted when no request had params
+	Result    *Schema `json:"result,omitempty"` // Omitted when no response had a result
+}
+
+// Schema describes the JSON values seen in one place. Values that differed
+// in type between messages list every type seen.
+type Schema struct {
+	Types      []string           `json:"types"`                // Sorted: "array", "boolean", "null", "number", "object", "string"
+	Optional   bool               `json:"optional,omitempty"`   // Property missing from some of the objects it was seen in
+	Properties map[string]*Schema `json:"properties,omitempty"` // For objects
+	Items      *Schema            `json:"items,omitempty"`      // For arrays, merged across all their elements
+
+	objects int // Objects merged in, to tell which properties are optional
+	present int // Objects of the parent this property appeared in
+}
+
+// SkillUsage is how one agent skill was used in a trace, see GetSkillUsage
+type SkillUsage struct {
+	Agent         string `json:"agent"`                // Host of the agent
+	AgentName     string `json:"agent_name,omitempty"` // Agent card name, if discovered
+	SkillID       string `json:"skill_id"`
+	Name          string `json:"name,omitempty"` // From the agent card
+	Advertised    bool   `json:"advertised"`     // Listed in the agent card
+	Unused        bool   `json:"unused"`         // Advertised but never invoked
+	Calls         int    `json:"calls"`
+	ErrorCount    int    `json:"error_count"`
+	AvgDurationMs int64  `json:"avg_duration_ms"`
+	MaxDurationMs int64  `json:"max_duration_ms"`
+}
+
+// TimeSeriesBucket aggregates the messages of one time interval
+type TimeSeriesBucket struct {
+	Start         time.Time `json:"start"`
+	Requests      int       `json:"requests"`
+	Responses     int       `json:"responses"`
+	Errors        int       `json:"errors"`
+	AvgDurationMs int64     `json:"avg_duration_ms"` // Of the responses in the bucket
 }
 
 // WebSocketMessage represents a message sent to the UI
 type WebSocketMessage struct {
-	Type    string      `json:"type"` // "message", "agent", "insight", "trace_status"
+	Type    string      `json:"type"` // "message", "agent", "insight", "annotation", "trace_status", "recording"
 	Payload interface{} `json:"payload"`
 }
-
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// InferSchemas infers, per method, the structure of the params and results
+// seen in a trace
+func (s *Store) InferSchemas(traceID string) ([]*MethodSchema, error) {
+	return s.InferSchemasContext(context.Background(), traceID)
+}
+
+// InferSchemasContext infers, per method, the structure of the params and
+// results seen in a trace, aborting if ctx is cancelled. Bodies that aren't
+// JSON, or were moved to the blob store, are skipped.
+func (s *Store) InferSchemasContext(ctx context.Context, traceID string) ([]*MethodSchema, error) {
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make(map[string]*MethodSchema)
+	schemaFor := func(method string) *MethodSchema {
+		if _, ok := schemas[method]; !ok {
+			schemas[method] = &MethodSchema{Method: method}
+		}
+		return schemas[method]
+	}
+
+	// Responses carry no method; they take their request's
+	methods := make(map[string]string)
+	for _, msg := range messages {
+		if msg.Direction == "request" && msg.Method != "" {
+			methods[msg.ID] = msg.Method
+		}
+	}
+
+	for _, msg := range messages {
+		var body struct {
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if msg.Preflight || json.Unmarshal([]byte(msg.Body), &body) != nil {
+			continue
+		}
+
+		switch msg.Direction {
+		case "request":
+			var params interface{}
+			if msg.Method == "" || json.Unmarshal(body.Params, &params) != nil {
+				continue
+			}
+			schema := schemaFor(msg.Method)
+			schema.Params = mergeSchema(schema.Params, params)
+			schema.Requests++
+		case "response":
+			var result interface{}
+			method := methods[msg.RequestID]
+			if method == "" || json.Unmarshal(body.Result, &result) != nil {
+				continue
+			}
+			schema := schemaFor(method)
+			schema.Result = mergeSchema(schema.Result, result)
+			schema.Responses++
+		}
+	}
+
+	result := make([]*MethodSchema, 0, len(schemas))
+	for _, schema := range schemas {
+		markOptional(schema.Params)
+		markOptional(schema.Result)
+		result = append(result, schema)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Method < result[j].Method
+	})
+	return result, nil
+}
+
+// mergeSchema adds a decoded JSON value to a schema, creating it if nil
+func mergeSchema(schema *Schema, value interface{}) *Schema {
+	if schema == nil {
+		schema = &Schema{}
+	}
+	schema.addType(jsonType(value))
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		schema.objects++
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]*Schema)
+		}
+		for name, field := range v {
+			property := mergeSchema(schema.Properties[name], field)
+			property.present++
+			schema.Properties[name] = property
+		}
+	case []interface{}:
+		for _, item := range v {
+			schema.Items = mergeSchema(schema.Items, item)
+		}
+	}
+	return schema
+}
+
+// addType records a JSON type, keeping Types sorted and unique
+func (s *Schema) addType(t string) {
+	i := sort.SearchStrings(s.Types, t)
+	if i < len(s.Types) && s.Types[i] == t {
+		return
+	}
+	s.Types = append(s.Types, "")
+	copy(s.Types[i+1:], s.Types[i:])
+	s.Types[i] = t
+}
+
+// markOptional flags the properties that some objects lacked, once every
+// value has been merged
+func markOptional(schema *Schema) {
+	if schema == nil {
+		return
+	}
+	for _, property := range schema.Properties {
+		property.Optional = property.present < schema.objects
+		markOptional(property)
+	}
+	markOptional(schema.Items)
+}
+
+// jsonType names the JSON type of a value decoded into an interface{}
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInferSchemasMergesShapes(t *testing.T) {
+	s, trace := newTestStore(t)
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "1", Direction: "request", Method: "tasks/get", Body: `{"jsonrpc":"2.0","id":1,"method":"tasks/get","params":{"id":"t1"}}`},
+		&Message{ID: "1-resp", Direction: "response", RequestID: "1", Body: `{"jsonrpc":"2.0","id":1,"result":{"id":"t1","status":{"state":"working"},"history":[]}}`},
+		&Message{ID: "2", Direction: "request", Method: "tasks/get", Body: `{"jsonrpc":"2.0","id":2,"method":"tasks/get","params":{"id":"t2","historyLength":3}}`},
+		&Message{ID: "2-resp", Direction: "response", RequestID: "2", Body: `{"jsonrpc":"2.0","id":2,"result":{"id":"t2","status":null,"history":[{"role":"user"},"x"]}}`},
+		&Message{ID: "3", Direction: "request", Method: "tasks/cancel", Body: `not json`},
+	)
+
+	schemas, err := s.InferSchemas(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 {
+		t.Fatalf("got %d schemas, want one for tasks/get", len(schemas))
+	}
+	got, err := json.Marshal(schemas[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"method":"tasks/get","requests":2,"responses":2,` +
+		`"params":{"types":["object"],"properties":{"historyLength":{"types":["number"],"optional":true},"id":{"types":["string"]}}},` +
+		`"result":{"types":["object"],"properties":{` +
+		`"history":{"types":["array"],"items":{"types":["object","string"],"properties":{"role":{"types":["string"]}}}},` +
+		`"id":{"types":["string"]},` +
+		`"status":{"types":["null","object"],"properties":{"state":{"types":["string"]}}}}}}`
+	if string(got) != want {
+		t.Errorf("got schema\n%s\nwant\n%s", got, want)
+	}
+}
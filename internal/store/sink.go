@@ -0,0 +1,15 @@
+package store
+
+// MessageSink receives captured messages, discovered agents and insights.
+// Store is the primary sink; others, such as JSONLWriter, archive or forward
+// the same data alongside it.
+type MessageSink interface {
+	SaveMessage(msg *Message) error
+	SaveAgent(agent *Agent) error
+	SaveInsight(insight *Insight) error
+}
+
+var (
+	_ MessageSink = (*Store)(nil)
+	_ MessageSink = (*JSONLWriter)(nil)
+)
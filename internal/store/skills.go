@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+)
+
+// GetSkillUsage reports, per agent skill, how often and how slowly it was
+// invoked in a trace. Skills advertised in agent cards but never invoked are
+// included and flagged unused; skills invoked without being advertised are
+// included too.
+func (s *Store) GetSkillUsage(traceID string) ([]*SkillUsage, error) {
+	return s.GetSkillUsageContext(context.Background(), traceID)
+}
+
+// GetSkillUsageContext reports how each agent skill was used in a trace,
+// aborting if ctx is cancelled
+func (s *Store) GetSkillUsageContext(ctx context.Context, traceID string) ([]*SkillUsage, error) {
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.GetAgentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type skillKey struct{ agent, skill string }
+	type skillStats struct {
+		usage         *SkillUsage
+		totalDuration int64
+		responses     int64
+	}
+	skills := make(map[skillKey]*skillStats)
+
+	names := make(map[string]string)
+	for _, agent := range agents {
+		u, err := url.Parse(agent.URL)
+		if err != nil {
+			continue
+		}
+		names[u.Host] = agent.Name
+
+		// Agents store their card's skills as JSON; skip any that don't parse
+		var advertised []Skill
+		if agent.Skills == "" || json.Unmarshal([]byte(agent.Skills), &advertised) != nil {
+			continue
+		}
+		for _, skill := range advertised {
+			skills[skillKey{u.Host, skill.ID}] = &skillStats{usage: &SkillUsage{
+				Agent:      u.Host,
+				AgentName:  agent.Name,
+				SkillID:    skill.ID,
+				Name:       skill.Name,
+				Advertised: true,
+			}}
+		}
+	}
+
+	responses := make(map[string]*Message)
+	for _, msg := range messages {
+		if msg.Direction == "response" && msg.RequestID != "" && !msg.Redirect {
+			responses[msg.RequestID] = msg
+		}
+	}
+
+	for _, msg := range messages {
+		if msg.Direction != "request" {
+			continue
+		}
+		skillID := bodySkillID(msg.Body)
+		if skillID == "" {
+			continue
+		}
+		key := skillKey{msg.ToAgent, skillID}
+		stats, ok := skills[key]
+		if !ok {
+			stats = &skillStats{usage: &SkillUsage{
+				Agent:     msg.ToAgent,
+				AgentName: names[msg.ToAgent],
+				SkillID:   skillID,
+			}}
+			skills[key] = stats
+		}
+		stats.usage.Calls++
+
+		resp, ok := responses[msg.ID]
+		if !ok {
+			continue
+		}
+		if resp.Error != "" || resp.StatusCode >= 400 {
+			stats.usage.ErrorCount++
+		}
+		stats.totalDuration += resp.DurationMs
+		stats.responses++
+		stats.usage.MaxDurationMs = max(stats.usage.MaxDurationMs, resp.DurationMs)
+	}
+
+	usage := make([]*SkillUsage, 0, len(skills))
+	for _, stats := range skills {
+		if stats.responses > 0 {
+			stats.usage.AvgDurationMs = stats.totalDuration / stats.responses
+		}
+		stats.usage.Unused = stats.usage.Calls == 0
+		usage = append(usage, stats.usage)
+	}
+
+	// Busiest skills first within each agent
+	sort.Slice(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		if a.Agent != b.Agent {
+			return a.Agent < b.Agent
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.SkillID < b.SkillID
+	})
+	return usage, nil
+}
+
+// bodySkillID returns the skill a JSON-RPC request names, as params.skillId
+// or in the metadata of the params or the message sent, or "" if none
+func bodySkillID(body string) string {
+	var req struct {
+		Params struct {
+			SkillID  string                 `json:"skillId"`
+			Metadata map[string]interface{} `json:"metadata"`
+			Message  struct {
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"message"`
+		} `json:"params"`
+	}
+	if json.Unmarshal([]byte(body), &req) != nil {
+		return ""
+	}
+	if req.Params.SkillID != "" {
+		return req.Params.SkillID
+	}
+	for _, metadata := range []map[string]interface{}{req.Params.Metadata, req.Params.Message.Metadata} {
+		if id, _ := metadata["skillId"].(string); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetSkillUsage(t *testing.T) {
+	s, trace := newTestStore(t)
+	if err := s.SaveAgent(&Agent{
+		URL:    "http://planner:8080",
+		Name:   "Planner",
+		Skills: `[{"id":"plan","name":"Plan"},{"id":"replan","name":"Replan"}]`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Skills named each way a request can, one of them not advertised
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "1", Direction: "request", ToAgent: "planner:8080", Body: `{"jsonrpc":"2.0","id":1,"method":"tasks/send","params":{"skillId":"plan"}}`},
+		&Message{ID: "1-resp", Direction: "response", RequestID: "1", StatusCode: 200, DurationMs: 100},
+		&Message{ID: "2", Direction: "request", ToAgent: "planner:8080", Body: `{"jsonrpc":"2.0","id":2,"method":"message/send","params":{"message":{"metadata":{"skillId":"plan"}}}}`},
+		&Message{ID: "2-resp", Direction: "response", RequestID: "2", StatusCode: 500, DurationMs: 300},
+		&Message{ID: "3", Direction: "request", ToAgent: "planner:8080", Body: `{"jsonrpc":"2.0","id":3,"method":"tasks/send","params":{"metadata":{"skillId":"summarize"}}}`},
+		&Message{ID: "4", Direction: "request", ToAgent: "planner:8080", Body: `{"jsonrpc":"2.0","id":4,"method":"tasks/get","params":{"id":"t1"}}`},
+	)
+
+	usage, err := s.GetSkillUsage(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range usage {
+		got = append(got, fmt.Sprintf("%s advertised=%v unused=%v calls=%d errors=%d avg=%d max=%d",
+			u.SkillID, u.Advertised, u.Unused, u.Calls, u.ErrorCount, u.AvgDurationMs, u.MaxDurationMs))
+	}
+	want := []string{
+		"plan advertised=true unused=false calls=2 errors=1 avg=200 max=300",
+		"summarize advertised=false unused=false calls=1 errors=0 avg=0 max=0",
+		"replan advertised=true unused=true calls=0 errors=0 avg=0 max=0",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got usage\n%v\nwant\n%v", got, want)
+	}
+	if usage[0].AgentName != "Planner" || usage[0].Name != "Plan" {
+		t.Errorf("plan listed as %q of %q, want Plan of Planner", usage[0].Name, usage[0].AgentName)
+	}
+}
@@ -1,13 +1,18 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/harry-kp/a2a-trace/internal/clock"
 	_ "modernc.org/sqlite"
 )
 
@@ -15,20 +20,40 @@ import (
 type Store struct {
 	db *sql.DB
 	mu sync.RWMutex
+
+	namesMu sync.Mutex
+	names   map[string]string // Cached agent_names, nil until loaded or after a change
+
+	// NewID generates ids for records saved without one. It defaults to
+	// random UUIDs; tests can swap in a predictable generator.
+	NewID func() string
+
+	// Clock stamps new traces (default: the system clock)
+	Clock clock.Clock
 }
 
-// New creates a new Store instance with an in-memory or file-based SQLite database
+// New creates a new Store instance with an in-memory or file-based SQLite database.
+//
+// File databases are opened in WAL mode with a busy timeout so the message
+// save path and API reads don't fail with "database is locked". WAL keeps
+// recent writes in <db>-wal and <db>-shm files next to the database until
+// they are checkpointed, so copy all three files when moving a live trace.
 func New(dbPath string) (*Store, error) {
 	if dbPath == "" {
 		dbPath = ":memory:"
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	store := &Store{db: db}
+	if dbPath == ":memory:" {
+		// Every connection to :memory: gets its own empty database, so share one
+		db.SetMaxOpenConns(1)
+	}
+
+	store := &Store{db: db, NewID: newUUID, Clock: clock.Real{}}
 	if err := store.migrate(); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
@@ -36,6 +61,35 @@ func New(dbPath string) (*Store, error) {
 	return store, nil
 }
 
+// MarkInterrupted marks traces still running as interrupted, returning how
+// many there were. Call it only when starting a new tracing session on the
+// database: a trace left running was then left by a session that crashed
+// and won't be completed, see ResumeTrace. Other processes opening the
+// database, e.g. to replay or compact it, may share it with a live session.
+func (s *Store) MarkInterrupted() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec("UPDATE traces SET status = 'interrupted' WHERE status = 'running'")
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark interrupted traces: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// dsn adds per-connection pragmas to a file database path
+func dsn(dbPath string) string {
+	if dbPath == ":memory:" {
+		return dbPath
+	}
+
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
 // migrate creates the database schema
 func (s *Store) migrate() error {
 	statements := []string{
@@ -62,6 +116,27 @@ func (s *Store) migrate() error {
 			request_id TEXT,
 			content_type TEXT,
 			size INTEGER DEFAULT 0,
+			is_notification INTEGER DEFAULT 0,
+			source TEXT,
+			seq INTEGER DEFAULT 0,
+			overhead_ms INTEGER DEFAULT 0,
+			transport TEXT,
+			retry_of TEXT,
+			trailers TEXT,
+			correlation_id TEXT,
+			fault TEXT,
+			body_path TEXT,
+			preflight INTEGER DEFAULT 0,
+			truncated INTEGER DEFAULT 0,
+			tls_info TEXT,
+			method_label TEXT,
+			ingress_port INTEGER DEFAULT 0,
+			timing TEXT,
+			id_type TEXT,
+			parent_id TEXT,
+			client_abandoned INTEGER DEFAULT 0,
+			redirect INTEGER DEFAULT 0,
+			http_method TEXT,
 			FOREIGN KEY (trace_id) REFERENCES traces(id)
 		)`,
 		`CREATE TABLE IF NOT EXISTS agents (
@@ -82,11 +157,51 @@ func (s *Store) migrate() error {
 			title TEXT NOT NULL,
 			details TEXT,
 			timestamp TIMESTAMP NOT NULL,
+			severity INTEGER DEFAULT 0,
+			fingerprint TEXT,
+			occurrences INTEGER DEFAULT 1,
+			last_seen TIMESTAMP,
+			subcategory TEXT,
+			acknowledged INTEGER DEFAULT 0,
+			FOREIGN KEY (trace_id) REFERENCES traces(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS artifacts (
+			id TEXT PRIMARY KEY,
+			trace_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			idx INTEGER DEFAULT 0,
+			name TEXT,
+			description TEXT,
+			types TEXT,
+			mime_type TEXT,
+			size INTEGER DEFAULT 0,
+			part_count INTEGER DEFAULT 0,
+			FOREIGN KEY (message_id) REFERENCES messages(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS annotations (
+			id TEXT PRIMARY KEY,
+			trace_id TEXT NOT NULL,
+			timestamp TIMESTAMP NOT NULL,
+			label TEXT NOT NULL,
+			note TEXT,
+			FOREIGN KEY (trace_id) REFERENCES traces(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS agent_names (
+			host TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			manual INTEGER DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS trace_summary (
+			trace_id TEXT PRIMARY KEY,
+			summary TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
 			FOREIGN KEY (trace_id) REFERENCES traces(id)
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_trace_id ON messages(trace_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_artifacts_message_id ON artifacts(message_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp)`,
 		`CREATE INDEX IF NOT EXISTS idx_insights_trace_id ON insights(trace_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_annotations_trace_id ON annotations(trace_id)`,
 	}
 
 	for _, stmt := range statements {
@@ -94,22 +209,109 @@ func (s *Store) migrate() error {
 			return fmt.Errorf("migration failed on statement: %w", err)
 		}
 	}
+
+	// Columns added after the initial schema, applied to older database files
+	columns := []struct {
+		table, name, definition string
+	}{
+		{"messages", "is_notification", "INTEGER DEFAULT 0"},
+		{"messages", "source", "TEXT"},
+		{"messages", "seq", "INTEGER DEFAULT 0"},
+		{"messages", "overhead_ms", "INTEGER DEFAULT 0"},
+		{"messages", "transport", "TEXT"},
+		{"messages", "retry_of", "TEXT"},
+		{"messages", "trailers", "TEXT"},
+		{"messages", "correlation_id", "TEXT"},
+		{"messages", "fault", "TEXT"},
+		{"messages", "body_path", "TEXT"},
+		{"messages", "preflight", "INTEGER DEFAULT 0"},
+		{"messages", "truncated", "INTEGER DEFAULT 0"},
+		{"messages", "tls_info", "TEXT"},
+		{"messages", "method_label", "TEXT"},
+		{"messages", "ingress_port", "INTEGER DEFAULT 0"},
+		{"messages", "timing", "TEXT"},
+		{"messages", "id_type", "TEXT"},
+		{"messages", "parent_id", "TEXT"},
+		{"messages", "client_abandoned", "INTEGER DEFAULT 0"},
+		{"messages", "redirect", "INTEGER DEFAULT 0"},
+		{"messages", "http_method", "TEXT"},
+		{"insights", "severity", "INTEGER DEFAULT 0"},
+		{"insights", "fingerprint", "TEXT"},
+		{"insights", "occurrences", "INTEGER DEFAULT 1"},
+		{"insights", "last_seen", "TIMESTAMP"},
+		{"insights", "subcategory", "TEXT"},
+		{"insights", "acknowledged", "INTEGER DEFAULT 0"},
+	}
+
+	for _, col := range columns {
+		if err := s.ensureColumn(col.table, col.name, col.definition); err != nil {
+			return fmt.Errorf("migration failed adding %s.%s: %w", col.table, col.name, err)
+		}
+	}
+
+	// Indexes on added columns must be created after the columns exist
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages(trace_id, seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_global_seq ON messages(seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_insights_fingerprint ON insights(trace_id, fingerprint)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id)`,
+	}
+
+	for _, stmt := range indexes {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("migration failed on index: %w", err)
+		}
+	}
 	return nil
 }
 
+// ensureColumn adds a column to a table if it does not exist yet
+func (s *Store) ensureColumn(table, name, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			colName, colType string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if colName == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, definition))
+	return err
+}
+
 // CreateTrace creates a new trace session
 func (s *Store) CreateTrace(command string) (*Trace, error) {
+	return s.CreateTraceContext(context.Background(), command)
+}
+
+// CreateTraceContext creates a new trace session, aborting if ctx is cancelled
+func (s *Store) CreateTraceContext(ctx context.Context, command string) (*Trace, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	trace := &Trace{
-		ID:        uuid.New().String(),
-		StartedAt: time.Now(),
+		ID:        s.NewID(),
+		StartedAt: s.Clock.Now(),
 		Command:   command,
 		Status:    "running",
 	}
 
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		"INSERT INTO traces (id, started_at, command, status) VALUES (?, ?, ?, ?)",
 		trace.ID, trace.StartedAt, trace.Command, trace.Status,
 	)
@@ -120,22 +322,59 @@ func (s *Store) CreateTrace(command string) (*Trace, error) {
 	return trace, nil
 }
 
+// ResumeTrace marks an interrupted trace as running again, so a new session
+// can continue appending to it, and returns it
+func (s *Store) ResumeTrace(traceID string) (*Trace, error) {
+	return s.ResumeTraceContext(context.Background(), traceID)
+}
+
+// ResumeTraceContext marks an interrupted trace as running again and returns
+// it, aborting if ctx is cancelled
+func (s *Store) ResumeTraceContext(ctx context.Context, traceID string) (*Trace, error) {
+	trace, err := s.GetTraceContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	if trace == nil {
+		return nil, fmt.Errorf("trace %s not found", traceID)
+	}
+	if trace.Status != "interrupted" {
+		return nil, fmt.Errorf("trace %s is %s, not interrupted", traceID, trace.Status)
+	}
+
+	if err := s.UpdateTraceStatusContext(ctx, traceID, "running"); err != nil {
+		return nil, err
+	}
+	trace.Status = "running"
+	return trace, nil
+}
+
 // UpdateTraceStatus updates the status of a trace
 func (s *Store) UpdateTraceStatus(traceID, status string) error {
+	return s.UpdateTraceStatusContext(context.Background(), traceID, status)
+}
+
+// UpdateTraceStatusContext updates the status of a trace, aborting if ctx is cancelled
+func (s *Store) UpdateTraceStatusContext(ctx context.Context, traceID, status string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.Exec("UPDATE traces SET status = ? WHERE id = ?", status, traceID)
+	_, err := s.db.ExecContext(ctx, "UPDATE traces SET status = ? WHERE id = ?", status, traceID)
 	return err
 }
 
 // GetTrace retrieves a trace by ID
 func (s *Store) GetTrace(traceID string) (*Trace, error) {
+	return s.GetTraceContext(context.Background(), traceID)
+}
+
+// GetTraceContext retrieves a trace by ID, aborting if ctx is cancelled
+func (s *Store) GetTraceContext(ctx context.Context, traceID string) (*Trace, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	trace := &Trace{}
-	err := s.db.QueryRow(
+	err := s.db.QueryRowContext(ctx,
 		"SELECT id, started_at, command, status FROM traces WHERE id = ?",
 		traceID,
 	).Scan(&trace.ID, &trace.StartedAt, &trace.Command, &trace.Status)
@@ -150,40 +389,351 @@ func (s *Store) GetTrace(traceID string) (*Trace, error) {
 	return trace, nil
 }
 
+// SaveTraceSummary stores the final summary of a trace, replacing any
+// saved before
+func (s *Store) SaveTraceSummary(traceID string, summary map[string]interface{}) error {
+	return s.SaveTraceSummaryContext(context.Background(), traceID, summary)
+}
+
+// SaveTraceSummaryContext stores the final summary of a trace, replacing any
+// saved before, aborting if ctx is cancelled
+func (s *Store) SaveTraceSummaryContext(ctx context.Context, traceID string, summary map[string]interface{}) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertTraceSummary(ctx, s.db, traceID, data)
+}
+
+// insertTraceSummary saves a trace's summary as JSON, replacing any earlier
+// one; callers must hold s.mu
+func (s *Store) insertTraceSummary(ctx context.Context, db execer, traceID string, data []byte) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO trace_summary (trace_id, summary, created_at)
+		VALUES (?, ?, ?)`,
+		traceID, string(data), s.Clock.Now(),
+	)
+	return err
+}
+
+// GetTraceSummary retrieves the final summary of a trace, or nil if none was
+// saved
+func (s *Store) GetTraceSummary(traceID string) (map[string]interface{}, error) {
+	return s.GetTraceSummaryContext(context.Background(), traceID)
+}
+
+// GetTraceSummaryContext retrieves the final summary of a trace, or nil if
+// none was saved, aborting if ctx is cancelled
+func (s *Store) GetTraceSummaryContext(ctx context.Context, traceID string) (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var data string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT summary FROM trace_summary WHERE trace_id = ?", traceID,
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var summary map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// GetTraceStats returns the span of a trace's messages
+func (s *Store) GetTraceStats(traceID string) (*TraceStats, error) {
+	return s.GetTraceStatsContext(context.Background(), traceID)
+}
+
+// GetTraceStatsContext returns the span of a trace's messages, aborting if
+// ctx is cancelled
+func (s *Store) GetTraceStatsContext(ctx context.Context, traceID string) (*TraceStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Timestamps are stored in Go's time format, which doesn't sort as
+	// text, so the earliest and latest are found here
+	rows, err := s.db.QueryContext(ctx, "SELECT timestamp FROM messages WHERE trace_id = ?", traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &TraceStats{}
+	for rows.Next() {
+		var timestamp time.Time
+		if err := rows.Scan(&timestamp); err != nil {
+			return nil, err
+		}
+		if stats.FirstMessageAt == nil || timestamp.Before(*stats.FirstMessageAt) {
+			stats.FirstMessageAt = &timestamp
+		}
+		if stats.LastMessageAt == nil || timestamp.After(*stats.LastMessageAt) {
+			stats.LastMessageAt = &timestamp
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.FirstMessageAt != nil {
+		stats.DurationMs = stats.LastMessageAt.Sub(*stats.FirstMessageAt).Milliseconds()
+	}
+	return stats, nil
+}
+
+// ListTraces returns all traces with their message counts, oldest first
+func (s *Store) ListTraces() ([]*Trace, error) {
+	return s.ListTracesContext(context.Background())
+}
+
+// ListTracesContext returns all traces with their message counts, oldest
+// first, aborting if ctx is cancelled
+func (s *Store) ListTracesContext(ctx context.Context) ([]*Trace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id, t.started_at, t.command, t.status, COALESCE(m.count, 0)
+		FROM traces t
+		LEFT JOIN (
+			SELECT trace_id, COUNT(*) AS count FROM messages GROUP BY trace_id
+		) m ON m.trace_id = t.id
+		ORDER BY t.started_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var traces []*Trace
+	for rows.Next() {
+		trace := &Trace{}
+		if err := rows.Scan(&trace.ID, &trace.StartedAt, &trace.Command, &trace.Status, &trace.MessageCount); err != nil {
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return traces, nil
+}
+
 // SaveMessage saves an A2A message to the database
 func (s *Store) SaveMessage(msg *Message) error {
+	return s.SaveMessageContext(context.Background(), msg)
+}
+
+// SaveMessageContext saves an A2A message to the database, aborting if ctx is cancelled
+func (s *Store) SaveMessageContext(ctx context.Context, msg *Message) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if msg.ID == "" {
-		msg.ID = uuid.New().String()
+		msg.ID = s.NewID()
+	}
+	if err := insertMessage(ctx, s.db, msg); err != nil {
+		return err
 	}
 
-	_, err := s.db.Exec(`
+	names, err := s.agentNames(ctx)
+	if err != nil {
+		return err
+	}
+	resolveAgentNames(msg, names)
+	return nil
+}
+
+// execer is what inserts need of a database, so they can run in a
+// transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertMessage inserts a message, setting its Seq; callers must hold s.mu
+func insertMessage(ctx context.Context, db execer, msg *Message) error {
+	// The next seq is taken in the insert itself, so processes sharing the
+	// database never hand out the same one
+	return db.QueryRowContext(ctx, `
 		INSERT INTO messages (
 			id, trace_id, timestamp, direction, from_agent, to_agent,
 			method, url, headers, body, duration_ms, status_code, error,
-			request_id, content_type, size
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			request_id, content_type, size, is_notification, source, seq,
+			overhead_ms, transport, retry_of, trailers, correlation_id, fault, body_path,
+			preflight, truncated, tls_info, method_label, ingress_port, timing, id_type, parent_id,
+			client_abandoned, redirect, http_method
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM messages),
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING seq`,
 		msg.ID, msg.TraceID, msg.Timestamp, msg.Direction, msg.FromAgent, msg.ToAgent,
 		msg.Method, msg.URL, msg.Headers, msg.Body, msg.DurationMs, msg.StatusCode, msg.Error,
-		msg.RequestID, msg.ContentType, msg.Size,
-	)
-	return err
+		msg.RequestID, msg.ContentType, msg.Size, msg.IsNotification, msg.Source,
+		msg.OverheadMs, msg.Transport, msg.RetryOf, msg.Trailers, msg.CorrelationID, msg.Fault, msg.BodyPath,
+		msg.Preflight, msg.Truncated, msg.TLSInfo, msg.MethodLabel, msg.IngressPort, msg.Timing, msg.IDType, msg.ParentID,
+		msg.ClientAbandoned, msg.Redirect, msg.HTTPMethod,
+	).Scan(&msg.Seq)
 }
 
 // GetMessages retrieves all messages for a trace
 func (s *Store) GetMessages(traceID string) ([]*Message, error) {
+	return s.GetMessagesContext(context.Background(), traceID)
+}
+
+// GetMessagesContext retrieves all messages for a trace, aborting if ctx is cancelled
+func (s *Store) GetMessagesContext(ctx context.Context, traceID string) ([]*Message, error) {
+	return s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE trace_id = ? ORDER BY seq ASC, timestamp ASC`,
+		traceID,
+	)
+}
+
+// GetMessage retrieves a message by ID, or nil if there is none
+func (s *Store) GetMessage(id string) (*Message, error) {
+	return s.GetMessageContext(context.Background(), id)
+}
+
+// GetMessageContext retrieves a message by ID, or nil if there is none,
+// aborting if ctx is cancelled
+func (s *Store) GetMessageContext(ctx context.Context, id string) (*Message, error) {
+	messages, err := s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE id = ?`,
+		id,
+	)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// GetResponse retrieves the final response to a request, or nil if there is
+// none
+func (s *Store) GetResponse(requestID string) (*Message, error) {
+	return s.GetResponseContext(context.Background(), requestID)
+}
+
+// GetResponseContext retrieves the final response to a request, or nil if
+// there is none, aborting if ctx is cancelled. Redirect hops recorded on the
+// way to it are skipped.
+func (s *Store) GetResponseContext(ctx context.Context, requestID string) (*Message, error) {
+	messages, err := s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE request_id = ? AND direction = 'response' AND redirect = 0
+		ORDER BY seq DESC LIMIT 1`,
+		requestID,
+	)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// GetChildMessages retrieves the requests made while serving a request, see
+// Message.ParentID
+func (s *Store) GetChildMessages(parentID string) ([]*Message, error) {
+	return s.GetChildMessagesContext(context.Background(), parentID)
+}
+
+// GetChildMessagesContext retrieves the requests made while serving a
+// request, aborting if ctx is cancelled
+func (s *Store) GetChildMessagesContext(ctx context.Context, parentID string) ([]*Message, error) {
+	return s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE parent_id = ?
+		ORDER BY seq ASC, timestamp ASC`,
+		parentID,
+	)
+}
+
+// GetFlaggedMessages retrieves the messages of a trace that failed or drew an
+// insight. only is "errors" for failed responses, "insights" for messages an
+// insight points at, or "" for both.
+func (s *Store) GetFlaggedMessages(traceID, only string) ([]*Message, error) {
+	return s.GetFlaggedMessagesContext(context.Background(), traceID, only)
+}
+
+// GetFlaggedMessagesContext retrieves the messages of a trace that failed or
+// drew an insight, aborting if ctx is cancelled
+func (s *Store) GetFlaggedMessagesContext(ctx context.Context, traceID, only string) ([]*Message, error) {
+	const failed = `(error != '' OR (status_code != 0 AND (status_code < 200 OR status_code >= 300)))`
+	const flagged = `id IN (SELECT message_id FROM insights WHERE trace_id = ? AND message_id != '')`
+
+	var where string
+	args := []interface{}{traceID}
+	switch only {
+	case "errors":
+		where = failed
+	case "insights":
+		where = flagged
+		args = append(args, traceID)
+	case "":
+		where = "(" + failed + " OR " + flagged + ")"
+		args = append(args, traceID)
+	default:
+		return nil, fmt.Errorf("unknown message filter %q", only)
+	}
+
+	return s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE trace_id = ? AND `+where+`
+		ORDER BY seq ASC, timestamp ASC`,
+		args...,
+	)
+}
+
+// GetMessageBody retrieves a message's body, or nil if there is no such
+// message
+func (s *Store) GetMessageBody(id string) (*MessageBody, error) {
+	return s.GetMessageBodyContext(context.Background(), id)
+}
+
+// GetMessageBodyContext retrieves a message's body, or nil if there is no
+// such message, aborting if ctx is cancelled
+func (s *Store) GetMessageBodyContext(ctx context.Context, id string) (*MessageBody, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rows, err := s.db.Query(`
-		SELECT id, trace_id, timestamp, direction, from_agent, to_agent,
+	var body, bodyPath, contentType sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		"SELECT body, body_path, content_type FROM messages WHERE id = ?", id,
+	).Scan(&body, &bodyPath, &contentType)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &MessageBody{Body: body.String, BodyPath: bodyPath.String, ContentType: contentType.String}, nil
+}
+
+// messageColumns lists the messages columns in the order queryMessages scans them
+const messageColumns = `id, trace_id, timestamp, direction, from_agent, to_agent,
 			method, url, headers, body, duration_ms, status_code, error,
-			request_id, content_type, size
-		FROM messages WHERE trace_id = ? ORDER BY timestamp ASC`,
-		traceID,
-	)
+			request_id, content_type, size, is_notification, source, seq,
+			overhead_ms, transport, retry_of, trailers, correlation_id, fault, body_path,
+			preflight, truncated, tls_info, method_label, ingress_port, timing, id_type, parent_id,
+			client_abandoned, redirect, http_method`
+
+// queryMessages runs a query selecting messageColumns and scans the results
+func (s *Store) queryMessages(ctx context.Context, query string, args ...interface{}) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -192,12 +742,15 @@ func (s *Store) GetMessages(traceID string) ([]*Message, error) {
 	var messages []*Message
 	for rows.Next() {
 		msg := &Message{}
-		var fromAgent, toAgent, method, url, headers, body, errStr, requestID, contentType sql.NullString
+		var fromAgent, toAgent, method, url, headers, body, errStr, requestID, contentType, source, transport, retryOf, trailers, correlationID, fault, bodyPath, tlsInfo, methodLabel, timing, idType, parentID, httpMethod sql.NullString
 		err := rows.Scan(
 			&msg.ID, &msg.TraceID, &msg.Timestamp, &msg.Direction,
 			&fromAgent, &toAgent, &method, &url, &headers, &body,
 			&msg.DurationMs, &msg.StatusCode, &errStr, &requestID,
-			&contentType, &msg.Size,
+			&contentType, &msg.Size, &msg.IsNotification, &source, &msg.Seq,
+			&msg.OverheadMs, &transport, &retryOf, &trailers, &correlationID, &fault, &bodyPath,
+			&msg.Preflight, &msg.Truncated, &tlsInfo, &methodLabel, &msg.IngressPort, &timing, &idType, &parentID,
+			&msg.ClientAbandoned, &msg.Redirect, &httpMethod,
 		)
 		if err != nil {
 			return nil, err
@@ -211,22 +764,77 @@ func (s *Store) GetMessages(traceID string) ([]*Message, error) {
 		msg.Error = errStr.String
 		msg.RequestID = requestID.String
 		msg.ContentType = contentType.String
+		msg.Source = source.String
+		msg.Transport = transport.String
+		msg.RetryOf = retryOf.String
+		msg.Trailers = trailers.String
+		msg.TLSInfo = tlsInfo.String
+		msg.MethodLabel = methodLabel.String
+		msg.Timing = timing.String
+		msg.IDType = idType.String
+		msg.ParentID = parentID.String
+		msg.HTTPMethod = httpMethod.String
+		msg.CorrelationID = correlationID.String
+		msg.Fault = fault.String
+		msg.BodyPath = bodyPath.String
 		messages = append(messages, msg)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names, err := s.agentNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		resolveAgentNames(msg, names)
+	}
 
 	return messages, nil
 }
 
 // SaveAgent saves or updates an agent
 func (s *Store) SaveAgent(agent *Agent) error {
+	return s.SaveAgentContext(context.Background(), agent)
+}
+
+// SaveAgentContext saves or updates an agent, aborting if ctx is cancelled
+func (s *Store) SaveAgentContext(ctx context.Context, agent *Agent) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if agent.ID == "" {
-		agent.ID = uuid.New().String()
+		agent.ID = s.NewID()
+	}
+
+	// An agent reached over both http and https keeps the URL it was first
+	// seen with
+	agent.URL = normalizeAgentURL(agent.URL)
+	if variant := otherSchemeURL(agent.URL); variant != "" {
+		var existing string
+		err := s.db.QueryRowContext(ctx, "SELECT url FROM agents WHERE url = ?", variant).Scan(&existing)
+		if err == nil {
+			agent.URL = existing
+		} else if err != sql.ErrNoRows {
+			return err
+		}
 	}
 
-	_, err := s.db.Exec(`
+	var previous sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT skills FROM agents WHERE url = ?", agent.URL).Scan(&previous)
+	switch {
+	case err == sql.ErrNoRows:
+		agent.PreviousSkills = ""
+	case err != nil:
+		return err
+	case previous.String == "":
+		agent.PreviousSkills = "[]" // Seen before, with no skills
+	default:
+		agent.PreviousSkills = previous.String
+	}
+
+	_, err = s.db.ExecContext(ctx, `
 		INSERT INTO agents (id, url, name, description, version, skills, first_seen)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(url) DO UPDATE SET
@@ -236,15 +844,139 @@ func (s *Store) SaveAgent(agent *Agent) error {
 			skills = excluded.skills`,
 		agent.ID, agent.URL, agent.Name, agent.Description, agent.Version, agent.Skills, agent.FirstSeen,
 	)
+	if err != nil {
+		return err
+	}
+
+	// Messages to the agent's host are shown under its card name, unless an
+	// alias was set for it
+	u, err := url.Parse(agent.URL)
+	if err != nil || u.Host == "" || agent.Name == "" {
+		return nil
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO agent_names (host, name, manual) VALUES (?, ?, 0)
+		ON CONFLICT(host) DO UPDATE SET name = excluded.name
+		WHERE agent_names.manual = 0`,
+		u.Host, agent.Name,
+	)
+	s.invalidateAgentNames()
+	return err
+}
+
+// defaultPorts are the ports normalizeAgentURL drops for each scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// normalizeAgentURL puts an agent URL in canonical form: lowercase scheme and
+// host, no default port and no trailing slash. URLs that don't parse are
+// returned unchanged.
+func normalizeAgentURL(agentURL string) string {
+	u, err := url.Parse(agentURL)
+	if err != nil || u.Host == "" {
+		return agentURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	u.Host = host
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// otherSchemeURL returns a normalized http URL as https and vice versa, or ""
+// for other schemes
+func otherSchemeURL(agentURL string) string {
+	switch {
+	case strings.HasPrefix(agentURL, "http://"):
+		return "https://" + strings.TrimPrefix(agentURL, "http://")
+	case strings.HasPrefix(agentURL, "https://"):
+		return "http://" + strings.TrimPrefix(agentURL, "https://")
+	}
+	return ""
+}
+
+// SetAgentAlias sets the display name of an agent host, taking precedence
+// over the name from its agent card
+func (s *Store) SetAgentAlias(host, name string) error {
+	return s.SetAgentAliasContext(context.Background(), host, name)
+}
+
+// SetAgentAliasContext sets the display name of an agent host, aborting if
+// ctx is cancelled
+func (s *Store) SetAgentAliasContext(ctx context.Context, host, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO agent_names (host, name, manual) VALUES (?, ?, 1)
+		ON CONFLICT(host) DO UPDATE SET name = excluded.name, manual = 1`,
+		host, name,
+	)
+	s.invalidateAgentNames()
 	return err
 }
 
+// agentNames returns the display name of each named agent host, read from
+// agent_names once and cached until a name changes. Callers must hold s.mu
+// and mustn't modify the map.
+func (s *Store) agentNames(ctx context.Context) (map[string]string, error) {
+	s.namesMu.Lock()
+	defer s.namesMu.Unlock()
+	if s.names != nil {
+		return s.names, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT host, name FROM agent_names")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var host, name string
+		if err := rows.Scan(&host, &name); err != nil {
+			return nil, err
+		}
+		names[host] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.names = names
+	return names, nil
+}
+
+// invalidateAgentNames drops the cached agent names after agent_names changed
+func (s *Store) invalidateAgentNames() {
+	s.namesMu.Lock()
+	s.names = nil
+	s.namesMu.Unlock()
+}
+
+// resolveAgentNames fills in the display names of a message's agents
+func resolveAgentNames(msg *Message, names map[string]string) {
+	msg.FromName = names[msg.FromAgent]
+	msg.ToName = names[msg.ToAgent]
+}
+
 // GetAgents retrieves all discovered agents
 func (s *Store) GetAgents() ([]*Agent, error) {
+	return s.GetAgentsContext(context.Background())
+}
+
+// GetAgentsContext retrieves all discovered agents, aborting if ctx is cancelled
+func (s *Store) GetAgentsContext(ctx context.Context) ([]*Agent, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, url, name, description, version, skills, first_seen
 		FROM agents ORDER BY first_seen DESC`,
 	)
@@ -267,38 +999,263 @@ func (s *Store) GetAgents() ([]*Agent, error) {
 		agent.Skills = skills.String
 		agents = append(agents, agent)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return agents, nil
 }
 
-// SaveInsight saves an insight to the database
+// SaveArtifact saves an artifact to the database
+func (s *Store) SaveArtifact(artifact *Artifact) error {
+	return s.SaveArtifactContext(context.Background(), artifact)
+}
+
+// SaveArtifactContext saves an artifact to the database, aborting if ctx is cancelled
+func (s *Store) SaveArtifactContext(ctx context.Context, artifact *Artifact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if artifact.ID == "" {
+		artifact.ID = s.NewID()
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO artifacts (id, trace_id, message_id, idx, name, description, types, mime_type, size, part_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		artifact.ID, artifact.TraceID, artifact.MessageID, artifact.Index, artifact.Name,
+		artifact.Description, artifact.Types, artifact.MimeType, artifact.Size, artifact.PartCount,
+	)
+	return err
+}
+
+// SaveAnnotation saves a timeline marker, stamping it with the current time if unset
+func (s *Store) SaveAnnotation(annotation *Annotation) error {
+	return s.SaveAnnotationContext(context.Background(), annotation)
+}
+
+// SaveAnnotationContext saves a timeline marker, stamping it with the current
+// time if unset, aborting if ctx is cancelled
+func (s *Store) SaveAnnotationContext(ctx context.Context, annotation *Annotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if annotation.ID == "" {
+		annotation.ID = s.NewID()
+	}
+	if annotation.Timestamp.IsZero() {
+		annotation.Timestamp = s.Clock.Now()
+	}
+	return insertAnnotation(ctx, s.db, annotation)
+}
+
+// insertAnnotation inserts an annotation; callers must hold s.mu
+func insertAnnotation(ctx context.Context, db execer, annotation *Annotation) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO annotations (id, trace_id, timestamp, label, note) VALUES (?, ?, ?, ?, ?)",
+		annotation.ID, annotation.TraceID, annotation.Timestamp, annotation.Label, annotation.Note,
+	)
+	return err
+}
+
+// GetAnnotations retrieves a trace's timeline markers, oldest first
+func (s *Store) GetAnnotations(traceID string) ([]*Annotation, error) {
+	return s.GetAnnotationsContext(context.Background(), traceID)
+}
+
+// GetAnnotationsContext retrieves a trace's timeline markers, oldest first, aborting if ctx is cancelled
+func (s *Store) GetAnnotationsContext(ctx context.Context, traceID string) ([]*Annotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, trace_id, timestamp, label, note FROM annotations WHERE trace_id = ? ORDER BY timestamp ASC",
+		traceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var annotations []*Annotation
+	for rows.Next() {
+		annotation := &Annotation{}
+		var note sql.NullString
+		err := rows.Scan(&annotation.ID, &annotation.TraceID, &annotation.Timestamp, &annotation.Label, &note)
+		if err != nil {
+			return nil, err
+		}
+		annotation.Note = note.String
+		annotations = append(annotations, annotation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return annotations, nil
+}
+
+// GetArtifacts retrieves the artifacts that arrived in a message
+func (s *Store) GetArtifacts(messageID string) ([]*Artifact, error) {
+	return s.GetArtifactsContext(context.Background(), messageID)
+}
+
+// GetArtifactsContext retrieves the artifacts that arrived in a message, aborting if ctx is cancelled
+func (s *Store) GetArtifactsContext(ctx context.Context, messageID string) ([]*Artifact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trace_id, message_id, idx, name, description, types, mime_type, size, part_count
+		FROM artifacts WHERE message_id = ? ORDER BY idx ASC`,
+		messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artifacts []*Artifact
+	for rows.Next() {
+		artifact := &Artifact{}
+		var name, desc, types, mimeType sql.NullString
+		err := rows.Scan(
+			&artifact.ID, &artifact.TraceID, &artifact.MessageID, &artifact.Index,
+			&name, &desc, &types, &mimeType, &artifact.Size, &artifact.PartCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		artifact.Name = name.String
+		artifact.Description = desc.String
+		artifact.Types = types.String
+		artifact.MimeType = mimeType.String
+		artifacts = append(artifacts, artifact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// SaveInsight saves an insight to the database. An insight whose Fingerprint
+// matches an earlier one in the same trace is folded into that row instead,
+// bumping its occurrence count and last-seen time; insight is updated to match.
 func (s *Store) SaveInsight(insight *Insight) error {
+	return s.SaveInsightContext(context.Background(), insight)
+}
+
+// SaveInsightContext saves an insight to the database, aborting if ctx is cancelled
+func (s *Store) SaveInsightContext(ctx context.Context, insight *Insight) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if insight.ID == "" {
-		insight.ID = uuid.New().String()
+		insight.ID = s.NewID()
 	}
+	insight.LastSeen = insight.Timestamp
 
-	_, err := s.db.Exec(`
-		INSERT INTO insights (id, trace_id, message_id, type, category, title, details, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+	if insight.Fingerprint != "" {
+		var existingID string
+		var occurrences, severity int
+		var firstSeen time.Time
+		var acknowledged bool
+		err := s.db.QueryRowContext(ctx,
+			"SELECT id, occurrences, severity, timestamp, acknowledged FROM insights WHERE trace_id = ? AND fingerprint = ?",
+			insight.TraceID, insight.Fingerprint,
+		).Scan(&existingID, &occurrences, &severity, &firstSeen, &acknowledged)
+
+		switch {
+		case err == nil:
+			_, err = s.db.ExecContext(ctx, `
+				UPDATE insights SET occurrences = occurrences + 1, last_seen = ?, severity = MAX(severity, ?)
+				WHERE id = ?`,
+				insight.LastSeen, insight.Severity, existingID,
+			)
+			if err != nil {
+				return err
+			}
+			insight.ID = existingID
+			insight.Occurrences = occurrences + 1
+			insight.Timestamp = firstSeen
+			insight.Acknowledged = acknowledged
+			if severity > insight.Severity {
+				insight.Severity = severity
+			}
+			return nil
+		case err != sql.ErrNoRows:
+			return err
+		}
+	}
+
+	insight.Occurrences = 1
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO insights (
+			id, trace_id, message_id, type, category, title, details, timestamp, severity,
+			fingerprint, occurrences, last_seen, subcategory
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		insight.ID, insight.TraceID, insight.MessageID, insight.Type, insight.Category,
-		insight.Title, insight.Details, insight.Timestamp,
+		insight.Title, insight.Details, insight.Timestamp, insight.Severity,
+		insight.Fingerprint, insight.Occurrences, insight.LastSeen, insight.Subcategory,
 	)
 	return err
 }
 
 // GetInsights retrieves all insights for a trace
 func (s *Store) GetInsights(traceID string) ([]*Insight, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.GetInsightsContext(context.Background(), traceID)
+}
 
-	rows, err := s.db.Query(`
-		SELECT id, trace_id, message_id, type, category, title, details, timestamp
-		FROM insights WHERE trace_id = ? ORDER BY timestamp DESC`,
+// GetInsightsContext retrieves all insights for a trace, aborting if ctx is cancelled
+func (s *Store) GetInsightsContext(ctx context.Context, traceID string) ([]*Insight, error) {
+	return s.queryInsights(ctx, `
+		SELECT `+insightColumns+`
+		FROM insights WHERE trace_id = ? ORDER BY severity DESC, timestamp DESC`,
 		traceID,
 	)
+}
+
+// AckInsight marks an insight as acknowledged and returns it, or nil if
+// there is no such insight
+func (s *Store) AckInsight(id string) (*Insight, error) {
+	return s.AckInsightContext(context.Background(), id)
+}
+
+// AckInsightContext marks an insight as acknowledged and returns it, or nil
+// if there is no such insight, aborting if ctx is cancelled
+func (s *Store) AckInsightContext(ctx context.Context, id string) (*Insight, error) {
+	s.mu.Lock()
+	result, err := s.db.ExecContext(ctx, "UPDATE insights SET acknowledged = 1 WHERE id = ?", id)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	insights, err := s.queryInsights(ctx, `
+		SELECT `+insightColumns+`
+		FROM insights WHERE id = ?`,
+		id,
+	)
+	if err != nil || len(insights) == 0 {
+		return nil, err
+	}
+	return insights[0], nil
+}
+
+// insightColumns lists the insights columns in the order queryInsights scans them
+const insightColumns = `id, trace_id, message_id, type, category, title, details, timestamp, severity,
+			fingerprint, occurrences, last_seen, subcategory, acknowledged`
+
+// queryInsights runs a query selecting insightColumns and scans the results
+func (s *Store) queryInsights(ctx context.Context, query string, args ...interface{}) ([]*Insight, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -307,49 +1264,171 @@ func (s *Store) GetInsights(traceID string) ([]*Insight, error) {
 	var insights []*Insight
 	for rows.Next() {
 		insight := &Insight{}
-		var messageID sql.NullString
+		var messageID, fingerprint, subcategory sql.NullString
+		var lastSeen sql.NullTime
 		err := rows.Scan(
 			&insight.ID, &insight.TraceID, &messageID, &insight.Type,
 			&insight.Category, &insight.Title, &insight.Details, &insight.Timestamp,
+			&insight.Severity, &fingerprint, &insight.Occurrences, &lastSeen, &subcategory,
+			&insight.Acknowledged,
 		)
 		if err != nil {
 			return nil, err
 		}
 		insight.MessageID = messageID.String
+		insight.Fingerprint = fingerprint.String
+		insight.Subcategory = subcategory.String
+		insight.LastSeen = insight.Timestamp
+		if lastSeen.Valid {
+			insight.LastSeen = lastSeen.Time
+		}
 		insights = append(insights, insight)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return insights, nil
 }
 
 // ExportTrace exports a trace as JSON
 func (s *Store) ExportTrace(traceID string) ([]byte, error) {
-	trace, err := s.GetTrace(traceID)
+	return s.ExportTraceContext(context.Background(), traceID)
+}
+
+// ExportTraceContext exports a trace as JSON, aborting if ctx is cancelled
+func (s *Store) ExportTraceContext(ctx context.Context, traceID string) ([]byte, error) {
+	return s.ExportTraceRangeContext(ctx, traceID, time.Time{}, time.Time{})
+}
+
+// ExportTraceRange exports the part of a trace between from and to as JSON.
+// A zero from or to leaves that end of the range open.
+func (s *Store) ExportTraceRange(traceID string, from, to time.Time) ([]byte, error) {
+	return s.ExportTraceRangeContext(context.Background(), traceID, from, to)
+}
+
+// ExportTraceRangeContext exports the part of a trace between from and to as
+// JSON, aborting if ctx is cancelled
+func (s *Store) ExportTraceRangeContext(ctx context.Context, traceID string, from, to time.Time) ([]byte, error) {
+	trace, err := s.GetTraceContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	insights, err := s.GetInsightsContext(ctx, traceID)
 	if err != nil {
 		return nil, err
 	}
 
-	messages, err := s.GetMessages(traceID)
+	annotations, err := s.GetAnnotationsContext(ctx, traceID)
 	if err != nil {
 		return nil, err
 	}
 
-	insights, err := s.GetInsights(traceID)
+	summary, err := s.GetTraceSummaryContext(ctx, traceID)
 	if err != nil {
 		return nil, err
 	}
 
+	// An export should stand alone, without files next to the database
+	for _, msg := range messages {
+		InlineBody(msg)
+	}
+
 	export := map[string]interface{}{
-		"trace":    trace,
-		"messages": messages,
-		"insights": insights,
+		"trace":       trace,
+		"messages":    messages,
+		"insights":    insights,
+		"annotations": annotations,
+	}
+	if summary != nil {
+		export["summary"] = summary
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		inRange := exportRange(from, to)
+
+		var rangeMessages []*Message
+		for _, msg := range messages {
+			if inRange(msg.Timestamp, msg.Timestamp) {
+				rangeMessages = append(rangeMessages, msg)
+			}
+		}
+
+		export["messages"] = rangeMessages
+		export["insights"] = insightsInRange(insights, inRange)
+		export["annotations"] = annotationsInRange(annotations, inRange)
+		if !from.IsZero() {
+			export["from"] = from
+		}
+		if !to.IsZero() {
+			export["to"] = to
+		}
 	}
 
 	return json.MarshalIndent(export, "", "  ")
 }
 
+// Ping checks that the database is reachable
+func (s *Store) Ping() error {
+	return s.PingContext(context.Background())
+}
+
+// PingContext checks that the database is reachable, aborting if ctx is cancelled
+func (s *Store) PingContext(ctx context.Context) error {
+	var one int
+	return s.db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// Size returns the size of the database in bytes
+func (s *Store) Size() (int64, error) {
+	return s.SizeContext(context.Background())
+}
+
+// SizeContext returns the size of the database in bytes, aborting if ctx is
+// cancelled. For an in-memory database it is the memory its pages take up.
+func (s *Store) SizeContext(ctx context.Context) (int64, error) {
+	var size int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()",
+	).Scan(&size)
+	return size, err
+}
+
+// Compact rebuilds the database to return the space of deleted rows to the
+// filesystem
+func (s *Store) Compact() error {
+	return s.CompactContext(context.Background())
+}
+
+// CompactContext rebuilds the database to return the space of deleted rows
+// to the filesystem, aborting if ctx is cancelled. VACUUM writes the rebuilt
+// database through the WAL, which is then checkpointed and truncated so the
+// files actually shrink.
+func (s *Store) CompactContext(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return nil
+}
+
+// newUUID returns a random UUID string
+func newUUID() string {
+	return uuid.New().String()
+}
+
 // Close closes the database connection
 func (s *Store) Close() error {
 	return s.db.Close()
 }
-
@@ -0,0 +1,692 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/clock"
+)
+
+// newTestStore returns a store over a fresh database file with one trace
+func newTestStore(t *testing.T) (*Store, *Trace) {
+	t.Helper()
+
+	s, err := New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	trace, err := s.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, trace
+}
+
+// testTime is a fixed timestamp for saved records
+var testTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSaveMessageAssignsIncreasingSeq(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	// All in the same millisecond, from several goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := &Message{ID: fmt.Sprintf("msg-%d", i), TraceID: trace.ID, Timestamp: testTime, Direction: "request"}
+			if err := s.SaveMessage(msg); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	messages, err := s.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 100 {
+		t.Fatalf("got %d messages, want 100", len(messages))
+	}
+	for i := 1; i < len(messages); i++ {
+		if messages[i].Seq <= messages[i-1].Seq {
+			t.Fatalf("seq %d follows %d", messages[i].Seq, messages[i-1].Seq)
+		}
+	}
+}
+
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.db")
+	writer, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	// A second process, such as the UI of another session, on the same file
+	reader, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var mode string
+	if err := writer.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal_mode = %s, want wal", mode)
+	}
+
+	trace, err := writer.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				msg := &Message{ID: fmt.Sprintf("msg-%d-%d", w, i), TraceID: trace.ID, Timestamp: testTime, Direction: "request"}
+				if err := writer.SaveMessage(msg); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if _, err := reader.GetMessages(trace.ID); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := reader.GetTraceStats(trace.ID); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages, err := reader.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 100 {
+		t.Errorf("got %d messages, want 100", len(messages))
+	}
+}
+
+func TestCancelledContextAbortsQuery(t *testing.T) {
+	s, trace := newTestStore(t)
+	if err := s.SaveMessage(&Message{ID: "msg-1", TraceID: trace.ID, Timestamp: testTime, Direction: "request"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.GetMessagesContext(ctx, trace.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetMessagesContext error = %v, want context.Canceled", err)
+	}
+	if err := s.SaveMessageContext(ctx, &Message{ID: "msg-2", TraceID: trace.ID, Timestamp: testTime}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SaveMessageContext error = %v, want context.Canceled", err)
+	}
+
+	// Nothing was written by the cancelled save
+	messages, err := s.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("got %d messages, want 1", len(messages))
+	}
+}
+
+// saveMessages saves messages to a trace in order, stamping them with
+// testTime unless they have a timestamp
+func saveMessages(t *testing.T, s *Store, traceID string, messages ...*Message) {
+	t.Helper()
+	for _, msg := range messages {
+		msg.TraceID = traceID
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = testTime
+		}
+		if err := s.SaveMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetInsightsOrderedBySeverity(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	for i, severity := range []int{30, 80, 50} {
+		insight := &Insight{
+			TraceID:   trace.ID,
+			Type:      "warning",
+			Category:  fmt.Sprintf("category-%d", i),
+			Severity:  severity,
+			Timestamp: testTime.Add(time.Duration(i) * time.Second),
+		}
+		if err := s.SaveInsight(insight); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insights, err := s.GetInsights(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, insight := range insights {
+		got = append(got, insight.Severity)
+	}
+	if fmt.Sprint(got) != "[80 50 30]" {
+		t.Errorf("severities in order %v, want [80 50 30]", got)
+	}
+}
+
+func TestExportTraceRange(t *testing.T) {
+	s, trace := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		saveMessages(t, s, trace.ID, &Message{
+			ID:        fmt.Sprintf("msg-%d", i),
+			Direction: "request",
+			Timestamp: testTime.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	for i, minute := range []int{0, 2} {
+		insight := &Insight{TraceID: trace.ID, Category: fmt.Sprint(i), Timestamp: testTime.Add(time.Duration(minute) * time.Minute)}
+		if err := s.SaveInsight(insight); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := s.ExportTraceRange(trace.ID, testTime.Add(time.Minute), testTime.Add(3*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		Trace    *Trace     `json:"trace"`
+		Messages []*Message `json:"messages"`
+		Insights []*Insight `json:"insights"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+
+	if export.Trace == nil || export.Trace.ID != trace.ID {
+		t.Error("export lacks the trace metadata")
+	}
+	var ids []string
+	for _, msg := range export.Messages {
+		ids = append(ids, msg.ID)
+	}
+	if fmt.Sprint(ids) != "[msg-1 msg-2 msg-3]" {
+		t.Errorf("exported messages %v, want msg-1 to msg-3", ids)
+	}
+	if len(export.Insights) != 1 || export.Insights[0].Category != "1" {
+		t.Errorf("exported %d insights, want the one in range", len(export.Insights))
+	}
+}
+
+// counterIDs returns an id generator yielding prefix-1, prefix-2, ...
+func counterIDs(prefix string) func() string {
+	var mu sync.Mutex
+	n := 0
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return fmt.Sprintf("%s-%d", prefix, n)
+	}
+}
+
+func TestInjectedIDGenerator(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.NewID = counterIDs("id")
+
+	trace, err := s.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &Message{TraceID: trace.ID, Timestamp: testTime, Direction: "request"}
+	if err := s.SaveMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	insight := &Insight{TraceID: trace.ID, Timestamp: testTime}
+	if err := s.SaveInsight(insight); err != nil {
+		t.Fatal(err)
+	}
+
+	if trace.ID != "id-1" || msg.ID != "id-2" || insight.ID != "id-3" {
+		t.Errorf("got ids %s, %s, %s; want id-1 to id-3", trace.ID, msg.ID, insight.ID)
+	}
+}
+
+func TestStoreClockStampsTraces(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Clock = clock.NewFake(testTime)
+
+	trace, err := s.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trace.StartedAt.Equal(testTime) {
+		t.Errorf("StartedAt = %v, want the clock's %v", trace.StartedAt, testTime)
+	}
+}
+
+func TestListTracesWithMessageCounts(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	clk := clock.NewFake(testTime)
+	s.Clock = clk
+
+	counts := map[string]int{"first": 3, "second": 0, "third": 1}
+	for _, command := range []string{"first", "second", "third"} {
+		trace, err := s.CreateTrace(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < counts[command]; i++ {
+			saveMessages(t, s, trace.ID, &Message{Direction: "request"})
+		}
+		clk.Advance(time.Minute)
+	}
+
+	traces, err := s.ListTraces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, trace := range traces {
+		got = append(got, fmt.Sprintf("%s:%d", trace.Command, trace.MessageCount))
+	}
+	if want := []string{"first:3", "second:0", "third:1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetFlaggedMessages(t *testing.T) {
+	s, trace := newTestStore(t)
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "ok", Direction: "response", StatusCode: 200},
+		&Message{ID: "failed", Direction: "response", StatusCode: 500},
+		&Message{ID: "refused", Direction: "response", Error: "connection refused"},
+		&Message{ID: "slow", Direction: "response", StatusCode: 200},
+		&Message{ID: "request", Direction: "request"},
+	)
+	if err := s.SaveInsight(&Insight{TraceID: trace.ID, MessageID: "slow", Category: "slow_response", Timestamp: testTime}); err != nil {
+		t.Fatal(err)
+	}
+	// Trace-wide insights point at no message
+	if err := s.SaveInsight(&Insight{TraceID: trace.ID, Category: "summary", Timestamp: testTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		only string
+		want []string
+	}{
+		{"errors", []string{"failed", "refused"}},
+		{"insights", []string{"slow"}},
+		{"", []string{"failed", "refused", "slow"}},
+	}
+	for _, tt := range tests {
+		messages, err := s.GetFlaggedMessages(trace.ID, tt.only)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("only=%q got %v, want %v", tt.only, ids, tt.want)
+		}
+	}
+
+	if _, err := s.GetFlaggedMessages(trace.ID, "warnings"); err == nil {
+		t.Error("unknown filter accepted")
+	}
+}
+
+func TestDiscoveredCardNamesMessages(t *testing.T) {
+	s, trace := newTestStore(t)
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "req", Direction: "request", ToAgent: "planner:8080"},
+		&Message{ID: "resp", Direction: "response", FromAgent: "planner:8080"},
+	)
+
+	messages, err := s.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].ToName != "" {
+		t.Errorf("named %q before the card was seen", messages[0].ToName)
+	}
+
+	if err := s.SaveAgent(&Agent{URL: "http://planner:8080/", Name: "Trip Planner", FirstSeen: testTime}); err != nil {
+		t.Fatal(err)
+	}
+	messages, err = s.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].ToName != "Trip Planner" || messages[1].FromName != "Trip Planner" {
+		t.Errorf("got %q and %q, want the card's name", messages[0].ToName, messages[1].FromName)
+	}
+
+	// Later messages are named as they are saved
+	later := &Message{ID: "later", TraceID: trace.ID, Timestamp: testTime, Direction: "request", ToAgent: "planner:8080"}
+	if err := s.SaveMessage(later); err != nil {
+		t.Fatal(err)
+	}
+	if later.ToName != "Trip Planner" {
+		t.Errorf("saved message named %q", later.ToName)
+	}
+}
+
+func TestAgentAliasOverridesCardName(t *testing.T) {
+	s, trace := newTestStore(t)
+	saveMessages(t, s, trace.ID, &Message{ID: "req", Direction: "request", ToAgent: "planner:8080"})
+
+	if err := s.SetAgentAlias("planner:8080", "Planner"); err != nil {
+		t.Fatal(err)
+	}
+	// A card seen afterwards doesn't replace the alias
+	if err := s.SaveAgent(&Agent{URL: "http://planner:8080", Name: "Trip Planner", FirstSeen: testTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := s.GetMessages(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].ToName != "Planner" {
+		t.Errorf("named %q, want the alias", messages[0].ToName)
+	}
+}
+
+func TestNormalizeAgentURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"HTTP://Planner.Example:80/", "http://planner.example"},
+		{"https://planner.example:443/a2a/", "https://planner.example/a2a"},
+		{"http://planner.example:8080", "http://planner.example:8080"},
+		{"http://[::1]:80/", "http://[::1]"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := normalizeAgentURL(tt.in); got != tt.want {
+			t.Errorf("normalizeAgentURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSchemeVariantsCollapseToOneAgent(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	for _, agent := range []*Agent{
+		{URL: "http://Planner.Example:80/", Name: "Planner", FirstSeen: testTime},
+		{URL: "https://planner.example", Name: "Planner v2", FirstSeen: testTime},
+	} {
+		if err := s.SaveAgent(agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	agents, err := s.GetAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 1 {
+		t.Fatalf("got %d agents, want 1", len(agents))
+	}
+	if agents[0].URL != "http://planner.example" || agents[0].Name != "Planner v2" {
+		t.Errorf("got %s %q, want the first URL with the latest card", agents[0].URL, agents[0].Name)
+	}
+}
+
+// fileSize returns the size on disk of a database with its WAL file
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	var size int64
+	for _, file := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+func TestCompactShrinksFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	trace, err := s.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.Repeat("x", 10000)
+	for i := 0; i < 200; i++ {
+		saveMessages(t, s, trace.ID, &Message{Direction: "request", Body: body})
+	}
+	// Pruned, as retention would
+	if _, err := s.db.Exec("DELETE FROM messages"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		t.Fatal(err)
+	}
+	before := fileSize(t, path)
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if after := fileSize(t, path); after >= before/10 {
+		t.Errorf("file went from %d to %d bytes, want it to shrink", before, after)
+	}
+
+	// Still usable
+	saveMessages(t, s, trace.ID, &Message{Direction: "request"})
+}
+
+func TestGetTraceStats(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	stats, err := s.GetTraceStats(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FirstMessageAt != nil || stats.LastMessageAt != nil || stats.DurationMs != 0 {
+		t.Errorf("empty trace has stats %+v", stats)
+	}
+
+	// Saved out of order, as concurrent requests can be
+	last := testTime.Add(2*time.Minute + 31*time.Second)
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "2", Direction: "request", Timestamp: testTime.Add(time.Minute)},
+		&Message{ID: "3", Direction: "response", Timestamp: last},
+		&Message{ID: "1", Direction: "request", Timestamp: testTime},
+	)
+
+	stats, err = s.GetTraceStats(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FirstMessageAt == nil || !stats.FirstMessageAt.Equal(testTime) {
+		t.Errorf("first message at %v, want %v", stats.FirstMessageAt, testTime)
+	}
+	if stats.LastMessageAt == nil || !stats.LastMessageAt.Equal(last) {
+		t.Errorf("last message at %v, want %v", stats.LastMessageAt, last)
+	}
+	if stats.DurationMs != 151000 {
+		t.Errorf("duration %dms, want 151000ms", stats.DurationMs)
+	}
+}
+
+func TestTraceSummaryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace, err := s.CreateTrace("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary, err := s.GetTraceSummary(trace.ID); err != nil || summary != nil {
+		t.Fatalf("got summary %v before one was saved", summary)
+	}
+	for _, count := range []int{3, 5} {
+		if err := s.SaveTraceSummary(trace.ID, map[string]interface{}{"total_messages": count}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	// Re-read from a fresh open, as after the session ended
+	s, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	summary, err := s.GetTraceSummary(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary["total_messages"] != float64(5) {
+		t.Errorf("got summary %v, want the last one saved", summary)
+	}
+
+	data, err := s.ExportTrace(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		Summary map[string]interface{} `json:"summary"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Summary["total_messages"] != float64(5) {
+		t.Errorf("export carries summary %v", export.Summary)
+	}
+}
+
+func TestAckInsight(t *testing.T) {
+	s, trace := newTestStore(t)
+	insight := &Insight{ID: "insight-1", TraceID: trace.ID, Category: "slow_response", Timestamp: testTime}
+	if err := s.SaveInsight(insight); err != nil {
+		t.Fatal(err)
+	}
+
+	acked, err := s.AckInsight("insight-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acked == nil || acked.ID != "insight-1" || !acked.Acknowledged {
+		t.Fatalf("got %+v, want the acknowledged insight", acked)
+	}
+	insights, err := s.GetInsights(trace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insights) != 1 || !insights[0].Acknowledged {
+		t.Error("acknowledgement not stored")
+	}
+
+	if acked, err := s.AckInsight("missing"); err != nil || acked != nil {
+		t.Errorf("acking an unknown insight got %v, %v; want nil", acked, err)
+	}
+}
+
+func TestInterruptedTraceResumed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed, err := s.CreateTrace("crashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveMessages(t, s, crashed.ID, &Message{ID: "before", Direction: "request"})
+	completed, err := s.CreateTrace("completed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTraceStatus(completed.ID, "completed"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close() // Without completing crashed, as a crash would
+
+	s, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n, err := s.MarkInterrupted(); err != nil || n != 1 {
+		t.Fatalf("marked %d traces interrupted, want 1 (%v)", n, err)
+	}
+	status := func(id string) string {
+		t.Helper()
+		trace, err := s.GetTrace(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return trace.Status
+	}
+	if status(crashed.ID) != "interrupted" || status(completed.ID) != "completed" {
+		t.Errorf("statuses %s and %s, want interrupted and completed", status(crashed.ID), status(completed.ID))
+	}
+
+	for _, id := range []string{completed.ID, "missing"} {
+		if _, err := s.ResumeTrace(id); err == nil {
+			t.Errorf("resumed trace %s", id)
+		}
+	}
+	resumed, err := s.ResumeTrace(crashed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Status != "running" || status(crashed.ID) != "running" {
+		t.Errorf("resumed trace is %s", status(crashed.ID))
+	}
+	saveMessages(t, s, crashed.ID, &Message{ID: "after", Direction: "request"})
+	if messages, err := s.GetMessages(crashed.ID); err != nil || len(messages) != 2 {
+		t.Errorf("got %d messages in the resumed trace, want 2", len(messages))
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// ExportThread exports the messages of one task as JSON, with the insights
+// on them and the cards of the agents involved. It returns nil if no message
+// refers to the task.
+func (s *Store) ExportThread(traceID, taskID string) ([]byte, error) {
+	return s.ExportThreadContext(context.Background(), traceID, taskID)
+}
+
+// ExportThreadContext exports the messages of one task as JSON, aborting if
+// ctx is cancelled
+func (s *Store) ExportThreadContext(ctx context.Context, traceID, taskID string) ([]byte, error) {
+	trace, err := s.GetTraceContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.threadMessages(ctx, traceID, taskID)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	insights, err := s.GetInsightsContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.GetAgentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inThread := make(map[string]bool, len(messages))
+	hosts := make(map[string]bool)
+	for _, msg := range messages {
+		inThread[msg.ID] = true
+		hosts[msg.ToAgent] = true
+		hosts[msg.FromAgent] = true
+	}
+
+	threadInsights := []*Insight{}
+	for _, insight := range insights {
+		if inThread[insight.MessageID] {
+			threadInsights = append(threadInsights, insight)
+		}
+	}
+	threadAgents := []*Agent{}
+	for _, agent := range agents {
+		if u, err := url.Parse(agent.URL); err == nil && hosts[u.Host] {
+			threadAgents = append(threadAgents, agent)
+		}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"task_id":  taskID,
+		"trace":    trace,
+		"messages": messages,
+		"insights": threadInsights,
+		"agents":   threadAgents,
+	}, "", "  ")
+}
+
+// threadMessages returns the messages of a trace that belong to a task:
+// requests naming it, responses and push notifications carrying it, and the
+// other half of each of their request/response pairs
+func (s *Store) threadMessages(ctx context.Context, traceID, taskID string) ([]*Message, error) {
+	messages, err := s.GetMessagesContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A task id may be only in an offloaded body, and the export should stand
+	// alone anyway
+	inThread := make(map[string]bool)
+	for _, msg := range messages {
+		InlineBody(msg)
+		if msg.Transport == "webhook" && msg.CorrelationID == taskID {
+			inThread[msg.ID] = true
+		} else if bodyTaskID(msg) == taskID {
+			inThread[msg.ID] = true
+		}
+	}
+
+	// Pull in the request a response answers, then the responses to every
+	// request in the thread
+	for _, msg := range messages {
+		if inThread[msg.ID] && msg.Direction == "response" && msg.RequestID != "" {
+			inThread[msg.RequestID] = true
+		}
+	}
+	var thread []*Message
+	for _, msg := range messages {
+		if inThread[msg.ID] || (msg.Direction == "response" && inThread[msg.RequestID]) {
+			thread = append(thread, msg)
+		}
+	}
+	return thread, nil
+}
+
+// bodyTaskID returns the task a message's JSON-RPC body refers to: the id
+// of a tasks/* request, the taskId of a sent message, or the task a result
+// describes. It returns "" if there is none.
+func bodyTaskID(msg *Message) string {
+	var body struct {
+		Params map[string]interface{} `json:"params"`
+		Result map[string]interface{} `json:"result"`
+	}
+	if json.Unmarshal([]byte(msg.Body), &body) != nil {
+		return ""
+	}
+
+	switch msg.Direction {
+	case "request":
+		if strings.HasPrefix(msg.Method, "tasks/") {
+			id, _ := body.Params["id"].(string)
+			return id
+		}
+		if message, ok := body.Params["message"].(map[string]interface{}); ok {
+			id, _ := message["taskId"].(string)
+			return id
+		}
+	case "response":
+		if id, _ := body.Result["taskId"].(string); id != "" {
+			return id
+		}
+		// Only Tasks carry their own id next to a status; messages have ids too
+		if _, ok := body.Result["status"]; ok || body.Result["kind"] == "task" {
+			id, _ := body.Result["id"].(string)
+			return id
+		}
+	}
+	return ""
+}
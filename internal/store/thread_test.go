@@ -0,0 +1,94 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestExportThread(t *testing.T) {
+	s, trace := newTestStore(t)
+	saveMessages(t, s, trace.ID,
+		// Starts task-a; only the response names it
+		&Message{ID: "send", Direction: "request", Method: "message/send", ToAgent: "planner:8080",
+			Body: `{"jsonrpc":"2.0","id":1,"method":"message/send","params":{"message":{"role":"user"}}}`},
+		&Message{ID: "send-resp", Direction: "response", RequestID: "send", ToAgent: "planner:8080",
+			Body: `{"jsonrpc":"2.0","id":1,"result":{"id":"task-a","status":{"state":"working"}}}`},
+		&Message{ID: "get-a", Direction: "request", Method: "tasks/get", ToAgent: "planner:8080",
+			Body: `{"jsonrpc":"2.0","id":2,"method":"tasks/get","params":{"id":"task-a"}}`},
+		&Message{ID: "get-a-resp", Direction: "response", RequestID: "get-a", ToAgent: "planner:8080",
+			Body: `{"jsonrpc":"2.0","id":2,"error":{"code":-32001,"message":"not found"}}`},
+		&Message{ID: "get-b", Direction: "request", Method: "tasks/get", ToAgent: "search:8080",
+			Body: `{"jsonrpc":"2.0","id":3,"method":"tasks/get","params":{"id":"task-b"}}`},
+		&Message{ID: "push", Direction: "request", Transport: "webhook", CorrelationID: "task-a", Body: `{}`},
+	)
+	for _, messageID := range []string{"get-a-resp", "get-b"} {
+		if err := s.SaveInsight(&Insight{TraceID: trace.ID, MessageID: messageID, Category: "error", Timestamp: testTime}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, agent := range []*Agent{{URL: "http://planner:8080", Name: "Planner"}, {URL: "http://search:8080", Name: "Search"}} {
+		if err := s.SaveAgent(agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := s.ExportThread(trace.ID, "task-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		TaskID   string     `json:"task_id"`
+		Trace    *Trace     `json:"trace"`
+		Messages []*Message `json:"messages"`
+		Insights []*Insight `json:"insights"`
+		Agents   []*Agent   `json:"agents"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, msg := range export.Messages {
+		ids = append(ids, msg.ID)
+	}
+	if fmt.Sprint(ids) != "[send send-resp get-a get-a-resp push]" {
+		t.Errorf("exported messages %v, want only task-a's", ids)
+	}
+	if len(export.Insights) != 1 || export.Insights[0].MessageID != "get-a-resp" {
+		t.Errorf("exported %d insights, want the one on get-a-resp", len(export.Insights))
+	}
+	if len(export.Agents) != 1 || export.Agents[0].Name != "Planner" {
+		t.Errorf("exported %d agents, want Planner", len(export.Agents))
+	}
+	if export.TaskID != "task-a" || export.Trace == nil || export.Trace.ID != trace.ID {
+		t.Errorf("export is for task %q of trace %v", export.TaskID, export.Trace)
+	}
+
+	if data, err := s.ExportThread(trace.ID, "task-c"); err != nil || data != nil {
+		t.Errorf("unknown task exported %s, %v; want nil", data, err)
+	}
+}
+
+func TestExportThreadFindsOffloadedTaskID(t *testing.T) {
+	s, trace := newTestStore(t)
+	body := `{"jsonrpc":"2.0","id":1,"result":{"id":"task-a","status":{"state":"completed"}}}`
+	saveMessages(t, s, trace.ID,
+		&Message{ID: "send", Direction: "request", Method: "message/send", Body: `{"jsonrpc":"2.0","id":1,"method":"message/send"}`},
+		&Message{ID: "send-resp", Direction: "response", RequestID: "send", BodyPath: offload(t, body)},
+	)
+
+	data, err := s.ExportThread(trace.ID, "task-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		Messages []*Message `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Messages) != 2 || export.Messages[1].Body != body {
+		t.Errorf("exported %d messages, want the request and its response with the body inline", len(export.Messages))
+	}
+}
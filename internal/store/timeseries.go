@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxTimeSeriesBuckets bounds a series, so a tiny bucket over a long trace
+// can't exhaust memory
+const maxTimeSeriesBuckets = 10000
+
+// ErrTooManyBuckets is returned when a bucket size would split a trace into
+// more than maxTimeSeriesBuckets buckets
+var ErrTooManyBuckets = errors.New("too many buckets")
+
+// GetTimeSeries buckets a trace's requests, responses and latency by time
+func (s *Store) GetTimeSeries(traceID string, bucket time.Duration) ([]*TimeSeriesBucket, error) {
+	return s.GetTimeSeriesContext(context.Background(), traceID, bucket)
+}
+
+// GetTimeSeriesContext buckets a trace's requests, responses and latency by
+// time, aborting if ctx is cancelled. Buckets are aligned to multiples of
+// bucket and run from the first message to the last; intervals without
+// messages are included with zero counts. CORS preflights and redirect hops
+// are left out.
+func (s *Store) GetTimeSeriesContext(ctx context.Context, traceID string, bucket time.Duration) ([]*TimeSeriesBucket, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("bucket must be positive, got %s", bucket)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Timestamps are stored in Go's time format, which SQLite's date
+	// functions can't parse, so rows are bucketed here
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT timestamp, direction, duration_ms, status_code, error
+		FROM messages WHERE trace_id = ? AND preflight = 0 AND redirect = 0`,
+		traceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type point struct {
+		timestamp  time.Time
+		direction  string
+		durationMs int64
+		failed     bool
+	}
+	var points []point
+	for rows.Next() {
+		var p point
+		var statusCode int
+		var errStr *string
+		if err := rows.Scan(&p.timestamp, &p.direction, &p.durationMs, &statusCode, &errStr); err != nil {
+			return nil, err
+		}
+		p.failed = (errStr != nil && *errStr != "") || statusCode >= 400
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	series := []*TimeSeriesBucket{}
+	if len(points) == 0 {
+		return series, nil
+	}
+
+	first, last := points[0].timestamp, points[0].timestamp
+	for _, p := range points[1:] {
+		if p.timestamp.Before(first) {
+			first = p.timestamp
+		}
+		if p.timestamp.After(last) {
+			last = p.timestamp
+		}
+	}
+	first = first.Truncate(bucket)
+	count := int64(last.Sub(first)/bucket) + 1
+	if count > maxTimeSeriesBuckets {
+		return nil, fmt.Errorf("%w: %s buckets would split the trace into %d, more than %d",
+			ErrTooManyBuckets, bucket, count, maxTimeSeriesBuckets)
+	}
+
+	totals := make([]int64, count)
+	for i := range totals {
+		series = append(series, &TimeSeriesBucket{Start: first.Add(time.Duration(i) * bucket)})
+	}
+	for _, p := range points {
+		i := int(p.timestamp.Sub(first) / bucket)
+		b := series[i]
+		switch p.direction {
+		case "request":
+			b.Requests++
+		case "response":
+			b.Responses++
+			totals[i] += p.durationMs
+			if p.failed {
+				b.Errors++
+			}
+		}
+	}
+	for i, b := range series {
+		if b.Responses > 0 {
+			b.AvgDurationMs = totals[i] / int64(b.Responses)
+		}
+	}
+
+	return series, nil
+}
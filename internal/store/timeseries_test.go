@@ -0,0 +1,70 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetTimeSeries(t *testing.T) {
+	s, trace := newTestStore(t)
+	at := func(offset time.Duration) time.Time { return testTime.Add(offset) }
+	saveMessages(t, s, trace.ID,
+		&Message{Direction: "request", Timestamp: at(100 * time.Millisecond)},
+		&Message{Direction: "response", Timestamp: at(300 * time.Millisecond), StatusCode: 200, DurationMs: 100},
+		&Message{Direction: "response", Timestamp: at(900 * time.Millisecond), StatusCode: 500, DurationMs: 300},
+		// Nothing in the second second
+		&Message{Direction: "request", Timestamp: at(2500 * time.Millisecond)},
+		&Message{Direction: "response", Timestamp: at(2600 * time.Millisecond), Preflight: true, DurationMs: 9000},
+		&Message{Direction: "response", Timestamp: at(2700 * time.Millisecond), Error: "timeout", DurationMs: 50},
+	)
+
+	series, err := s.GetTimeSeries(trace.ID, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TimeSeriesBucket{
+		{Start: testTime, Requests: 1, Responses: 2, Errors: 1, AvgDurationMs: 200},
+		{Start: at(time.Second)},
+		{Start: at(2 * time.Second), Requests: 1, Responses: 1, Errors: 1, AvgDurationMs: 50},
+	}
+	if len(series) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(series), len(want))
+	}
+	for i, b := range series {
+		b.Start = b.Start.UTC()
+		if *b != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, *b, want[i])
+		}
+	}
+
+	// Larger buckets hold everything
+	series, err = s.GetTimeSeries(trace.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Requests != 2 || series[0].Responses != 3 {
+		t.Errorf("got %d one-minute buckets, want one with everything", len(series))
+	}
+}
+
+func TestGetTimeSeriesLimits(t *testing.T) {
+	s, trace := newTestStore(t)
+
+	series, err := s.GetTimeSeries(trace.ID, time.Second)
+	if err != nil || len(series) != 0 {
+		t.Errorf("empty trace got %d buckets, %v; want none", len(series), err)
+	}
+
+	if _, err := s.GetTimeSeries(trace.ID, 0); err == nil {
+		t.Error("zero bucket accepted")
+	}
+
+	saveMessages(t, s, trace.ID,
+		&Message{Direction: "request", Timestamp: testTime},
+		&Message{Direction: "request", Timestamp: testTime.Add(time.Hour)},
+	)
+	if _, err := s.GetTimeSeries(trace.ID, time.Millisecond); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("got %v, want ErrTooManyBuckets", err)
+	}
+}
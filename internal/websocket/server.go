@@ -26,13 +26,29 @@ type Client struct {
 	send chan []byte
 }
 
+// maxFrameMessages caps how many queued messages are coalesced into one
+// WebSocket frame, so a burst doesn't build a multi-megabyte frame. The rest
+// go out in the following frames.
+const maxFrameMessages = 64
+
+// shutdownTimeout bounds how long Shutdown waits for clients to be sent
+// their final frames
+const shutdownTimeout = 3 * time.Second
+
 // Hub maintains the set of active clients and broadcasts messages
 type Hub struct {
 	clients    map[*Client]bool
+	streams    map[chan []byte]bool // Event stream subscribers, see Subscribe
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	shutdown   chan [][]byte // Final frames for every client, then stop
+	done       chan struct{} // Closed once Run has stopped
+	writers    sync.WaitGroup
+	once       sync.Once
 	mu         sync.RWMutex
+
+	batchInterval time.Duration // Coalesce broadcasts into batch frames this often (0: send each at once)
 }
 
 // NewHub creates a new Hub instance
@@ -41,14 +57,56 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		shutdown:   make(chan [][]byte),
+		done:       make(chan struct{}),
 		clients:    make(map[*Client]bool),
+		streams:    make(map[chan []byte]bool),
 	}
 }
 
+// SetBatchInterval makes the hub collect broadcasts and send them at most
+// once per interval, as a single batch frame when there are several. Zero
+// sends each broadcast at once. Call it before Run.
+func (h *Hub) SetBatchInterval(interval time.Duration) {
+	h.batchInterval = interval
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	var pending [][]byte
+	var flush <-chan time.Time
+	if h.batchInterval > 0 {
+		ticker := time.NewTicker(h.batchInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
 	for {
 		select {
+		case final := <-h.shutdown:
+			// Updates still waiting for the next batch go out first
+			h.flushBatch(pending)
+			h.mu.Lock()
+			for client := range h.clients {
+				// Queue the final frames; closing send makes writePump
+				// flush them and then send a close frame
+				for _, frame := range final {
+					select {
+					case client.send <- frame:
+					default:
+					}
+				}
+				close(client.send)
+				delete(h.clients, client)
+			}
+			for stream := range h.streams {
+				close(stream)
+				delete(h.streams, stream)
+			}
+			h.mu.Unlock()
+			close(h.done)
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
@@ -65,17 +123,154 @@ func (h *Hub) Run() {
 			log.Printf("WebSocket client disconnected (total: %d)", len(h.clients))
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			// Event streams get every update on its own, never batched
+			h.publish(message)
+			if h.batchInterval > 0 {
+				pending = append(pending, message)
+				continue
+			}
+			h.deliver(message)
+
+		case <-flush:
+			h.flushBatch(pending)
+			pending = nil
+		}
+	}
+}
+
+// deliver queues a frame for every client, dropping clients too far behind
+// to take it
+func (h *Hub) deliver(message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client.send <- message:
+		default:
+			close(client.send)
+			delete(h.clients, client)
+		}
+	}
+}
+
+// publish queues a broadcast frame for every event stream subscriber,
+// dropping subscribers too far behind to take it
+func (h *Hub) publish(message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for stream := range h.streams {
+		select {
+		case stream <- message:
+		default:
+			close(stream)
+			delete(h.streams, stream)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every broadcast frame, and a
+// function to stop receiving them. The channel is closed when the hub shuts
+// down or the subscriber falls too far behind.
+func (h *Hub) Subscribe() (<-chan []byte, func()) {
+	stream := make(chan []byte, 256)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.done:
+		close(stream)
+		return stream, func() {}
+	default:
+	}
+	h.streams[stream] = true
+
+	return stream, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.streams[stream] {
+			close(stream)
+			delete(h.streams, stream)
+		}
+	}
+}
+
+// flushBatch delivers broadcasts collected for a batch, in frames of at most
+// maxFrameMessages
+func (h *Hub) flushBatch(pending [][]byte) {
+	for len(pending) > 0 {
+		n := min(len(pending), maxFrameMessages)
+		h.deliver(batchFrame(pending[:n]))
+		pending = pending[n:]
+	}
+}
+
+// batchFrame combines broadcast frames into one {"type":"batch"} frame whose
+// payload lists them in order. A single frame is sent as it is.
+func batchFrame(frames [][]byte) []byte {
+	if len(frames) == 1 {
+		return frames[0]
+	}
+	batch := []byte(`{"type":"batch","payload":[`)
+	for i, frame := range frames {
+		if i > 0 {
+			batch = append(batch, ',')
+		}
+		batch = append(batch, frame...)
+	}
+	return append(batch, "]}"...)
+}
+
+// Shutdown sends every client a final_summary frame with summary, if it
+// isn't nil, and a final trace_status frame for trace, followed by a normal
+// close, then stops Run. It waits up to shutdownTimeout for the frames to be
+// written. Broadcasts after Shutdown are dropped.
+func (h *Hub) Shutdown(trace *store.Trace, summary map[string]interface{}) {
+	h.once.Do(func() {
+		var final [][]byte
+		if summary != nil {
+			data, err := json.Marshal(store.WebSocketMessage{
+				Type:    "final_summary",
+				Payload: summary,
+			})
+			if err != nil {
+				log.Printf("Failed to marshal final summary: %v", err)
+			} else {
+				final = append(final, data)
 			}
-			h.mu.RUnlock()
 		}
+		data, err := json.Marshal(store.WebSocketMessage{
+			Type:    "trace_status",
+			Payload: trace,
+		})
+		if err != nil {
+			log.Printf("Failed to marshal trace status: %v", err)
+			return
+		}
+		final = append(final, data)
+
+		select {
+		case h.shutdown <- final:
+		case <-time.After(shutdownTimeout):
+			return // Run isn't running
+		}
+
+		flushed := make(chan struct{})
+		go func() {
+			h.writers.Wait()
+			close(flushed)
+		}()
+		select {
+		case <-flushed:
+		case <-time.After(shutdownTimeout):
+		}
+	})
+}
+
+// send queues a frame for broadcast unless the hub has shut down
+func (h *Hub) send(data []byte) {
+	select {
+	case h.broadcast <- data:
+	case <-h.done:
 	}
 }
 
@@ -90,7 +285,7 @@ func (h *Hub) BroadcastMessage(msg *store.Message) {
 		log.Printf("Failed to marshal message: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.send(data)
 }
 
 // BroadcastAgent sends an agent discovery to all connected clients
@@ -104,7 +299,7 @@ func (h *Hub) BroadcastAgent(agent *store.Agent) {
 		log.Printf("Failed to marshal agent: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.send(data)
 }
 
 // BroadcastInsight sends an insight to all connected clients
@@ -118,7 +313,35 @@ func (h *Hub) BroadcastInsight(insight *store.Insight) {
 		log.Printf("Failed to marshal insight: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.send(data)
+}
+
+// BroadcastInsightAck tells all clients an insight was acknowledged
+func (h *Hub) BroadcastInsightAck(insight *store.Insight) {
+	wsMsg := store.WebSocketMessage{
+		Type:    "insight_ack",
+		Payload: insight,
+	}
+	data, err := json.Marshal(wsMsg)
+	if err != nil {
+		log.Printf("Failed to marshal insight: %v", err)
+		return
+	}
+	h.send(data)
+}
+
+// BroadcastAnnotation sends a new timeline marker to all clients
+func (h *Hub) BroadcastAnnotation(annotation *store.Annotation) {
+	wsMsg := store.WebSocketMessage{
+		Type:    "annotation",
+		Payload: annotation,
+	}
+	data, err := json.Marshal(wsMsg)
+	if err != nil {
+		log.Printf("Failed to marshal annotation: %v", err)
+		return
+	}
+	h.send(data)
 }
 
 // BroadcastTraceStatus sends a trace status update to all clients
@@ -132,7 +355,21 @@ func (h *Hub) BroadcastTraceStatus(trace *store.Trace) {
 		log.Printf("Failed to marshal trace status: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.send(data)
+}
+
+// BroadcastRecording tells all clients whether recording is paused
+func (h *Hub) BroadcastRecording(paused bool) {
+	wsMsg := store.WebSocketMessage{
+		Type:    "recording",
+		Payload: map[string]bool{"paused": paused},
+	}
+	data, err := json.Marshal(wsMsg)
+	if err != nil {
+		log.Printf("Failed to marshal recording state: %v", err)
+		return
+	}
+	h.send(data)
 }
 
 // ClientCount returns the number of connected clients
@@ -156,13 +393,21 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		send: make(chan []byte, 256),
 	}
 
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "trace completed"))
+		conn.Close()
+		return
+	}
 
 	// Send initial connection confirmation
 	welcome := []byte(`{"type":"connected","payload":null}`)
 	client.send <- welcome
 
 	// Start goroutines for reading and writing
+	h.writers.Add(1)
 	go client.writePump()
 	go client.readPump()
 }
@@ -170,7 +415,10 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
@@ -186,8 +434,8 @@ func (c *Client) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			// Only log unexpected close errors, not normal closes
-			if websocket.IsUnexpectedCloseError(err, 
-				websocket.CloseGoingAway, 
+			if websocket.IsUnexpectedCloseError(err,
+				websocket.CloseGoingAway,
 				websocket.CloseAbnormalClosure,
 				websocket.CloseNormalClosure,
 				websocket.CloseNoStatusReceived) {
@@ -207,6 +455,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 
 	for {
@@ -214,7 +463,8 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				return
 			}
 
@@ -225,7 +475,7 @@ func (c *Client) writePump() {
 			_, _ = w.Write(message)
 
 			// Add queued messages to the current WebSocket message
-			n := len(c.send)
+			n := min(len(c.send), maxFrameMessages-1)
 			for i := 0; i < n; i++ {
 				_, _ = w.Write([]byte{'\n'})
 				_, _ = w.Write(<-c.send)
@@ -244,6 +494,21 @@ func (c *Client) writePump() {
 	}
 }
 
+// reply queues a response to this client only. The hub closes send when it
+// drops or shuts down a client, so check registration under the hub's lock.
+func (c *Client) reply(data []byte) {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+
+	if !c.hub.clients[c] {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
 // handleMessage processes incoming messages from the UI
 func (c *Client) handleMessage(message []byte) {
 	var msg map[string]interface{}
@@ -260,7 +525,7 @@ func (c *Client) handleMessage(message []byte) {
 	case "ping":
 		// Respond with pong
 		response, _ := json.Marshal(map[string]string{"type": "pong"})
-		c.send <- response
+		c.reply(response)
 
 	case "replay":
 		// Handle replay request (future feature)
@@ -270,4 +535,3 @@ func (c *Client) handleMessage(message []byte) {
 		log.Printf("Unknown message type: %s", msgType)
 	}
 }
-
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// startHub runs a hub behind a test server until the test ends
+func startHub(t *testing.T) (*Hub, *httptest.Server) {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+	return hub, server
+}
+
+// dial connects a WebSocket client to a test server, returning it once the
+// hub has welcomed it
+func dial(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if types := readTypes(t, conn); len(types) != 1 || types[0] != "connected" {
+		t.Fatalf("first frame has %v, want connected", types)
+	}
+	return conn
+}
+
+// readTypes reads one WebSocket message and returns the type of each update
+// in it; queued updates may share a message, one per line
+func readTypes(t *testing.T, conn *websocket.Conn) []string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, line := range strings.Split(string(data), "\n") {
+		var update store.WebSocketMessage
+		if err := json.Unmarshal([]byte(line), &update); err != nil {
+			t.Fatalf("bad frame %q: %v", line, err)
+		}
+		types = append(types, update.Type)
+	}
+	return types
+}
+
+func TestShutdownSendsFinalFramesAndClose(t *testing.T) {
+	hub, server := startHub(t)
+	conn := dial(t, server)
+
+	hub.Shutdown(&store.Trace{ID: "trace-1", Status: "completed"}, map[string]interface{}{"total_messages": 2})
+
+	var types []string
+	for len(types) < 2 {
+		types = append(types, readTypes(t, conn)...)
+	}
+	if strings.Join(types, ",") != "final_summary,trace_status" {
+		t.Errorf("got frames %v, want final_summary then trace_status", types)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("got %v, want a normal close", err)
+	}
+}
+
+func TestClientAfterShutdownIsTurnedAway(t *testing.T) {
+	hub, server := startHub(t)
+	hub.Shutdown(&store.Trace{ID: "trace-1", Status: "completed"}, nil)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("got %v, want a going-away close", err)
+	}
+}
+
+func TestBurstSplitIntoBoundedFrames(t *testing.T) {
+	hub := NewHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// A burst already queued when the writer gets to it
+		client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+		for i := 0; i < 200; i++ {
+			client.send <- []byte(`{"type":"message","payload":null}`)
+		}
+		close(client.send)
+		hub.writers.Add(1)
+		go client.writePump()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var sizes []int
+	total := 0
+	for total < 200 {
+		n := len(readTypes(t, conn))
+		sizes = append(sizes, n)
+		total += n
+	}
+	if total != 200 {
+		t.Errorf("got %d messages, want 200", total)
+	}
+	for _, n := range sizes {
+		if n > maxFrameMessages {
+			t.Errorf("frame sizes %v, want at most %d messages each", sizes, maxFrameMessages)
+			break
+		}
+	}
+}
+
+func TestBatchFramesBounded(t *testing.T) {
+	hub := NewHub()
+	hub.SetBatchInterval(50 * time.Millisecond)
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	defer server.Close()
+	conn := dial(t, server)
+
+	for i := 0; i < 200; i++ {
+		hub.BroadcastMessage(&store.Message{ID: "msg"})
+	}
+
+	total := 0
+	for total < 200 {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			var batch struct {
+				Type    string            `json:"type"`
+				Payload []json.RawMessage `json:"payload"`
+			}
+			if err := json.Unmarshal([]byte(line), &batch); err != nil || batch.Type != "batch" {
+				total++ // A lone update
+				continue
+			}
+			if len(batch.Payload) > maxFrameMessages {
+				t.Fatalf("batch of %d updates, want at most %d", len(batch.Payload), maxFrameMessages)
+			}
+			total += len(batch.Payload)
+		}
+	}
+}
+
+func TestRapidUpdatesArriveAsOneBatch(t *testing.T) {
+	hub := NewHub()
+	hub.SetBatchInterval(300 * time.Millisecond)
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	defer server.Close()
+	conn := dial(t, server)
+
+	hub.BroadcastMessage(&store.Message{ID: "req-1"})
+	hub.BroadcastInsight(&store.Insight{ID: "insight-1"})
+	hub.BroadcastMessage(&store.Message{ID: "resp-1"})
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		Type    string `json:"type"`
+		Payload []struct {
+			Type    string `json:"type"`
+			Payload struct {
+				ID string `json:"id"`
+			} `json:"payload"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &batch); err != nil || batch.Type != "batch" {
+		t.Fatalf("got frame %s, want a batch", data)
+	}
+	var got []string
+	for _, update := range batch.Payload {
+		got = append(got, update.Type+":"+update.Payload.ID)
+	}
+	if strings.Join(got, ",") != "message:req-1,insight:insight-1,message:resp-1" {
+		t.Errorf("batch holds %v, want the three updates in order", got)
+	}
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventStreamKeepAlive is how often an idle event stream gets a comment
+// line, so intermediaries don't close it
+const eventStreamKeepAlive = 30 * time.Second
+
+// HandleEventStream serves the hub's broadcasts as Server-Sent Events, for
+// clients that can't use WebSocket, e.g. curl or EventSource. Each update is
+// an event named after its type, such as "message" or "insight", with the
+// payload as data. ?types=message,insight limits the stream to those types.
+func (h *Hub) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var types map[string]bool
+	if param := r.URL.Query().Get("types"); param != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(param, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	// The stream outlives the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	stream, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case frame, ok := <-stream:
+			if !ok {
+				return
+			}
+			var update struct {
+				Type    string          `json:"type"`
+				Payload json.RawMessage `json:"payload"`
+			}
+			if json.Unmarshal(frame, &update) != nil || (types != nil && !types[update.Type]) {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, update.Payload); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
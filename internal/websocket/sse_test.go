@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+func TestEventStream(t *testing.T) {
+	hub := NewHub()
+	hub.SetBatchInterval(time.Hour) // Event streams aren't batched
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleEventStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?types=message,insight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q, want text/event-stream", ct)
+	}
+
+	// Subscribed before the headers were sent
+	hub.BroadcastAgent(&store.Agent{ID: "agent-1"})
+	hub.BroadcastMessage(&store.Message{ID: "msg-1"})
+	hub.BroadcastInsight(&store.Insight{ID: "insight-1"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < 6 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream ended after %q", got)
+			}
+			got = append(got, line)
+		case <-timeout:
+			t.Fatalf("got %q before timing out", got)
+		}
+	}
+
+	if !strings.HasPrefix(got[1], `data: {"id":"msg-1"`) || !strings.HasPrefix(got[4], `data: {"id":"insight-1"`) {
+		t.Errorf("got events %q, want msg-1 then insight-1", got)
+	}
+	got[1], got[4] = "data", "data"
+	if strings.Join(got, "|") != "event: message|data||event: insight|data|" {
+		t.Errorf("got events %q, want a message then an insight, without the agent", got)
+	}
+}
@@ -67,6 +67,7 @@ for target in "${TARGETS[@]}"; do
     echo "  Building ${OUTPUT_NAME}..."
     
     GOOS=$GOOS GOARCH=$GOARCH go build \
+        -tags ui \
         -ldflags="$LDFLAGS" \
         -o "dist/${OUTPUT_NAME}" \
         ./cmd/a2a-trace
@@ -44,7 +44,7 @@ LDFLAGS="$LDFLAGS -X github.com/harry-kp/a2a-trace/internal/cli.Commit=$COMMIT"
 LDFLAGS="$LDFLAGS -X github.com/harry-kp/a2a-trace/internal/cli.BuildDate=$BUILD_DATE"
 
 # Build for current platform
-go build -ldflags="$LDFLAGS" -o bin/a2a-trace ./cmd/a2a-trace
+go build -tags ui -ldflags="$LDFLAGS" -o bin/a2a-trace ./cmd/a2a-trace
 
 echo ""
 echo -e "${GREEN}✅ Build complete!${NC}"
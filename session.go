@@ -0,0 +1,195 @@
+// Package a2atrace embeds the A2A Trace interception engine in Go programs.
+//
+// A Session wires together the proxy, store and analyzer used by the
+// a2a-trace CLI so test harnesses can route agent calls through it and
+// assert on the captured traffic:
+//
+//	session, err := a2atrace.NewSession(a2atrace.Config{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := session.Start(); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer session.Stop()
+//
+//	client := session.HTTPClient()
+//	// ... call agents with client ...
+//
+//	messages, _ := session.Messages()
+package a2atrace
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/harry-kp/a2a-trace/internal/analyzer"
+	"github.com/harry-kp/a2a-trace/internal/proxy"
+	"github.com/harry-kp/a2a-trace/internal/store"
+)
+
+// Message is an intercepted A2A request or response
+type Message = store.Message
+
+// Insight is an automatically detected issue or pattern
+type Insight = store.Insight
+
+// Agent is a discovered A2A agent
+type Agent = store.Agent
+
+// Trace is a single tracing session
+type Trace = store.Trace
+
+// Config holds session configuration
+type Config struct {
+	Port          int           // Proxy port (default: a free port)
+	DBPath        string        // SQLite database path (default: in-memory)
+	Label         string        // Recorded as the trace command (default: "session")
+	SlowThreshold time.Duration // Slow response threshold (default: 1s)
+	OnMessage     func(*Message)
+	OnInsight     func(*Insight)
+}
+
+// Session is an embedded tracing session
+type Session struct {
+	cfg      Config
+	store    *store.Store
+	analyzer *analyzer.Analyzer
+	proxy    *proxy.Proxy
+	trace    *store.Trace
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewSession creates a session with its own store and trace
+func NewSession(cfg Config) (*Session, error) {
+	if cfg.Label == "" {
+		cfg.Label = "session"
+	}
+
+	dataStore, err := store.New(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	trace, err := dataStore.CreateTrace(cfg.Label)
+	if err != nil {
+		dataStore.Close()
+		return nil, fmt.Errorf("failed to create trace: %w", err)
+	}
+
+	s := &Session{
+		cfg:   cfg,
+		store: dataStore,
+		trace: trace,
+	}
+
+	s.analyzer = analyzer.New(analyzer.Config{
+		Store:         dataStore,
+		TraceID:       trace.ID,
+		SlowThreshold: cfg.SlowThreshold,
+		OnInsight:     cfg.OnInsight,
+	})
+
+	s.proxy = proxy.New(proxy.Config{
+		Port:            cfg.Port,
+		Store:           dataStore,
+		TraceID:         trace.ID,
+		SummaryProvider: s.analyzer,
+		OnMessage: func(msg *store.Message) {
+			s.analyzer.AnalyzeMessage(msg)
+			if cfg.OnMessage != nil {
+				cfg.OnMessage(msg)
+			}
+		},
+	})
+
+	return s, nil
+}
+
+// Start starts the proxy and analyzer; it returns once the proxy is listening
+func (s *Session) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return fmt.Errorf("session already started")
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	s.listener = ln
+
+	go func() {
+		_ = s.proxy.Serve(ln)
+	}()
+	go s.analyzer.Run()
+
+	return nil
+}
+
+// Stop stops the proxy, marks the trace completed and closes the store
+func (s *Session) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		_ = s.proxy.Stop()
+		s.listener.Close()
+	}
+	s.analyzer.Stop()
+
+	_ = s.store.UpdateTraceStatus(s.trace.ID, "completed")
+	return s.store.Close()
+}
+
+// ProxyURL returns the proxy address, e.g. for HTTP_PROXY
+func (s *Session) ProxyURL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// HTTPClient returns a client that routes requests through the session's proxy
+func (s *Session) HTTPClient() *http.Client {
+	proxyURL, _ := url.Parse(s.ProxyURL())
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+	}
+}
+
+// TraceID returns the ID of the session's trace
+func (s *Session) TraceID() string {
+	return s.trace.ID
+}
+
+// Messages returns all messages captured so far
+func (s *Session) Messages() ([]*Message, error) {
+	return s.store.GetMessages(s.trace.ID)
+}
+
+// Insights returns all insights detected so far
+func (s *Session) Insights() ([]*Insight, error) {
+	return s.store.GetInsights(s.trace.ID)
+}
+
+// Agents returns all discovered agents
+func (s *Session) Agents() ([]*Agent, error) {
+	return s.store.GetAgents()
+}
+
+// Summary returns the same statistics as the CLI's end-of-trace summary
+func (s *Session) Summary() map[string]interface{} {
+	return s.analyzer.GetSummary()
+}
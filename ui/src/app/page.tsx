@@ -53,7 +53,7 @@ export default function Home() {
       const [traceRes, messagesRes, agentsRes, insightsRes, summaryRes] =
         await Promise.all([
           fetch(`${baseUrl}/api/trace`),
-          fetch(`${baseUrl}/api/messages`),
+          fetch(`${baseUrl}/api/messages?include_body=true`),
           fetch(`${baseUrl}/api/agents`),
           fetch(`${baseUrl}/api/insights`),
           fetch(`${baseUrl}/api/summary`),
@@ -114,7 +114,9 @@ export default function Home() {
         prev ? { ...prev, total_insights: prev.total_insights + 1 } : null
       );
     },
+    onInsightAck: (insight) => addInsight(insight),
     onTraceStatus: (trace) => setTrace(trace),
+    onFinalSummary: (summary) => setSummary(summary),
   });
 
   // Fetch data on mount
@@ -145,6 +147,8 @@ export default function Home() {
       error_count: 0,
       success_count: 0,
       avg_duration_ms: 0,
+      unique_agents: 0,
+      total_skills: 0,
       method_counts: {},
       agent_error_counts: {},
     });
@@ -12,7 +12,10 @@ interface InsightsPanelProps {
 export function InsightsPanel({ insights }: InsightsPanelProps) {
   const { selectMessage } = useTraceStore();
 
-  if (insights.length === 0) {
+  // Acknowledged insights have been triaged
+  const activeInsights = insights.filter((insight) => !insight.acknowledged);
+
+  if (activeInsights.length === 0) {
     return (
       <div className="flex flex-col items-center justify-center h-32 text-zinc-500">
         <Lightbulb className="w-8 h-8 mb-2 opacity-50" />
@@ -21,7 +24,7 @@ export function InsightsPanel({ insights }: InsightsPanelProps) {
     );
   }
 
-  const sortedInsights = [...insights].sort((a, b) => {
+  const sortedInsights = [...activeInsights].sort((a, b) => {
     const priority = { error: 0, warning: 1, info: 2 };
     return priority[a.type] - priority[b.type];
   });
@@ -93,6 +96,11 @@ function InsightCard({ insight, index, onClick }: InsightCardProps) {
             <span className="text-xs text-zinc-500 px-1.5 py-0.5 rounded bg-zinc-800">
               {insight.category.replace("_", " ")}
             </span>
+            {insight.occurrences > 1 && (
+              <span className="text-xs text-zinc-400 px-1.5 py-0.5 rounded bg-zinc-800">
+                ×{insight.occurrences}
+              </span>
+            )}
           </div>
           
           {parsedDetails ? (
@@ -52,8 +52,11 @@ export function MessageInspector() {
               {selectedMessage.direction === "request" ? "Request" : "Response"}
             </span>
             {selectedMessage.method && (
-              <span className="px-2 py-0.5 rounded bg-zinc-800 text-xs font-mono text-zinc-400">
-                {selectedMessage.method}
+              <span
+                className="px-2 py-0.5 rounded bg-zinc-800 text-xs font-mono text-zinc-400"
+                title={selectedMessage.method_label ? selectedMessage.method : undefined}
+              >
+                {selectedMessage.method_label || selectedMessage.method}
               </span>
             )}
           </div>
@@ -1,13 +1,15 @@
 "use client";
 
 import { useEffect, useRef, useCallback, useState } from "react";
-import type { Message, Agent, Insight, Trace, WebSocketMessage } from "@/lib/types";
+import type { Message, Agent, Insight, Trace, Summary, WebSocketMessage } from "@/lib/types";
 
 interface UseWebSocketOptions {
   onMessage?: (message: Message) => void;
   onAgent?: (agent: Agent) => void;
   onInsight?: (insight: Insight) => void;
+  onInsightAck?: (insight: Insight) => void;
   onTraceStatus?: (trace: Trace) => void;
+  onFinalSummary?: (summary: Summary) => void;
   onConnect?: () => void;
   onDisconnect?: () => void;
 }
@@ -68,28 +70,40 @@ export function useWebSocket(url: string, options: UseWebSocketOptions = {}) {
         console.error("WebSocket error:", error);
       };
 
+      const dispatch = (data: WebSocketMessage) => {
+        switch (data.type) {
+          case "message":
+            optionsRef.current.onMessage?.(data.payload as Message);
+            break;
+          case "agent":
+            optionsRef.current.onAgent?.(data.payload as Agent);
+            break;
+          case "insight":
+            optionsRef.current.onInsight?.(data.payload as Insight);
+            break;
+          case "insight_ack":
+            optionsRef.current.onInsightAck?.(data.payload as Insight);
+            break;
+          case "trace_status":
+            optionsRef.current.onTraceStatus?.(data.payload as Trace);
+            break;
+          case "final_summary":
+            optionsRef.current.onFinalSummary?.(data.payload as Summary);
+            break;
+          case "batch":
+            // Updates coalesced by --ws-batch-ms, in order
+            (data.payload as WebSocketMessage[]).forEach(dispatch);
+            break;
+          case "pong":
+          case "connected":
+            // Heartbeat/connection confirmation
+            break;
+        }
+      };
+
       ws.onmessage = (event) => {
         try {
-          const data: WebSocketMessage = JSON.parse(event.data);
-
-          switch (data.type) {
-            case "message":
-              optionsRef.current.onMessage?.(data.payload as Message);
-              break;
-            case "agent":
-              optionsRef.current.onAgent?.(data.payload as Agent);
-              break;
-            case "insight":
-              optionsRef.current.onInsight?.(data.payload as Insight);
-              break;
-            case "trace_status":
-              optionsRef.current.onTraceStatus?.(data.payload as Trace);
-              break;
-            case "pong":
-            case "connected":
-              // Heartbeat/connection confirmation
-              break;
-          }
+          dispatch(JSON.parse(event.data));
         } catch (error) {
           console.error("Failed to parse WebSocket message:", error);
         }
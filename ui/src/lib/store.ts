@@ -98,7 +98,10 @@ export const useTraceStore = create<TraceStore>((set, get) => ({
   
   addInsight: (insight) =>
     set((state) => ({
-      insights: [...state.insights, insight],
+      // Repeats of a deduplicated insight arrive with the same id
+      insights: state.insights.some((i) => i.id === insight.id)
+        ? state.insights.map((i) => (i.id === insight.id ? insight : i))
+        : [...state.insights, insight],
     })),
     
   setInsights: (insights) => set({ insights }),
@@ -154,8 +157,8 @@ export const useTraceStore = create<TraceStore>((set, get) => ({
           timestamp: new Date(msg.timestamp),
           request: parseMessage(msg),
           response: response ? parseMessage(response) : undefined,
-          fromAgent: fromAgent?.name || msg.from_agent || "Client",
-          toAgent: toAgent?.name || msg.to_agent || "Agent",
+          fromAgent: msg.from_name || fromAgent?.name || msg.from_agent || "Client",
+          toAgent: msg.to_name || toAgent?.name || msg.to_agent || "Agent",
           method: msg.method || "HTTP",
           status,
           duration: response?.duration_ms,
@@ -4,7 +4,11 @@ export interface Trace {
   id: string;
   started_at: string;
   command: string;
-  status: "running" | "completed" | "error";
+  status: "running" | "completed" | "error" | "interrupted";
+  message_count?: number;
+  first_message_at?: string;
+  last_message_at?: string;
+  duration_ms?: number;
 }
 
 export interface Message {
@@ -14,16 +18,36 @@ export interface Message {
   direction: "request" | "response";
   from_agent: string;
   to_agent: string;
+  from_name?: string;
+  to_name?: string;
   method: string;
+  method_label?: string;
   url: string;
   headers: string;
-  body: string;
+  body?: string;
+  body_url?: string;
   duration_ms: number;
   status_code: number;
   error: string;
   request_id: string;
+  id_type?: string;
+  parent_id?: string;
+  client_abandoned?: boolean;
+  redirect?: boolean;
+  http_method?: string;
   content_type: string;
   size: number;
+  transport?: "grpc" | "webhook";
+  retry_of?: string;
+  trailers?: string;
+  correlation_id?: string;
+  fault?: string;
+  body_path?: string;
+  preflight?: boolean;
+  truncated?: boolean;
+  tls_info?: string;
+  ingress_port?: number;
+  timing?: string;
 }
 
 export interface Agent {
@@ -34,6 +58,23 @@ export interface Agent {
   version: string;
   skills: string;
   first_seen: string;
+  health?: AgentHealth;
+}
+
+export interface AgentHealth {
+  score: number;
+  responses: number;
+  error_rate: number;
+  avg_duration_ms: number;
+  violations: number;
+}
+
+export interface Annotation {
+  id: string;
+  trace_id: string;
+  timestamp: string;
+  label: string;
+  note?: string;
 }
 
 export interface Insight {
@@ -42,9 +83,15 @@ export interface Insight {
   message_id: string;
   type: "error" | "warning" | "info";
   category: string;
+  subcategory?: string;
   title: string;
   details: string;
   timestamp: string;
+  severity: number;
+  occurrences: number;
+  acknowledged?: boolean;
+  last_seen: string;
+  fingerprint?: string;
 }
 
 export interface Summary {
@@ -53,13 +100,23 @@ export interface Summary {
   error_count: number;
   success_count: number;
   avg_duration_ms: number;
+  unique_agents: number;
+  total_skills: number;
   method_counts: Record<string, number>;
   agent_error_counts: Record<string, number>;
 }
 
+export interface TimeSeriesBucket {
+  start: string;
+  requests: number;
+  responses: number;
+  errors: number;
+  avg_duration_ms: number;
+}
+
 export interface WebSocketMessage {
-  type: "message" | "agent" | "insight" | "trace_status" | "pong" | "connected";
-  payload: Message | Agent | Insight | Trace | null;
+  type: "message" | "agent" | "insight" | "insight_ack" | "annotation" | "trace_status" | "final_summary" | "batch" | "recording" | "pong" | "connected";
+  payload: Message | Agent | Insight | Annotation | Trace | Summary | WebSocketMessage[] | { paused: boolean } | null;
 }
 
 // Parsed versions of JSON fields